@@ -0,0 +1,101 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import "sync"
+
+// A2Bloom is an active-active buffering filter over a sliding window of the
+// most recent adds. Data is added to the active ring, and tested against both
+// it and the standby ring. Once window adds have gone to the active ring, the
+// standby is reset and the two are swapped. Data added within the last window
+// adds is always found, and data added more than 2*window adds ago never is,
+// bar false positives, in the memory of two rings.
+type A2Bloom struct {
+	window  uint64
+	mutex   sync.RWMutex // guards the fields below
+	active  *Bloom
+	standby *Bloom
+	count   uint64 // adds to the active ring since the last swap
+}
+
+// NewA2 initializes and returns a new A2Bloom over a window of the given
+// number of adds, or an error. Each ring is initialized as Init would for
+// window elements, with half the falsePositive rate and the options, so that
+// a Test against both is within the rate. With WithRandomSeed, the rings
+// share one seed.
+func NewA2(window int, falsePositive float64, opts ...Option) (*A2Bloom, error) {
+	active, err := Init(window, falsePositive/2, opts...)
+	if err != nil {
+		return nil, err
+	}
+	// share any random seed drawn for the active ring
+	standby, err := Init(window, falsePositive/2,
+		append(opts[:len(opts):len(opts)], withHashingOf(active))...)
+	if err != nil {
+		return nil, err
+	}
+	return &A2Bloom{window: uint64(window), active: active, standby: standby}, nil
+}
+
+// Add adds the data to the active ring, swapping the rings first if the
+// active one has had window adds.
+func (a *A2Bloom) Add(data []byte) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.add(a.active.Hash(data))
+}
+
+// Test returns a bool if the data is in either ring. True indicates that the
+// data may have been added within the window, while false indicates that it
+// was not.
+func (a *A2Bloom) Test(data []byte) bool {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	h := a.active.Hash(data)
+	return a.active.TestHash(h) || a.standby.TestHash(h)
+}
+
+// TestAndAdd is equivalent to calling Test(data) then Add(data) atomically,
+// so that of concurrent calls with the same data only one returns false, as
+// replay protection needs. Data already in the active ring is not added
+// again, and data found only in the standby ring is added to the active one,
+// so that it stays in the window.
+func (a *A2Bloom) TestAndAdd(data []byte) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	h := a.active.Hash(data)
+	if a.active.TestHash(h) {
+		return true
+	}
+	present := a.standby.TestHash(h)
+	a.add(h)
+	return present
+}
+
+// Rotate resets the standby ring and swaps it with the active one, as happens
+// once the active ring has had window adds.
+func (a *A2Bloom) Rotate() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.rotate()
+}
+
+// add adds the element of the handle to the active ring, rotating first if
+// it is full. The caller must hold the write lock.
+func (a *A2Bloom) add(h HashHandle) {
+	if a.count >= a.window {
+		a.rotate()
+	}
+	a.active.AddHash(h)
+	a.count++
+}
+
+// rotate resets the standby ring and swaps it with the active one. The
+// caller must hold the write lock.
+func (a *A2Bloom) rotate() {
+	a.standby.Reset()
+	a.active, a.standby = a.standby, a.active
+	a.count = 0
+}
@@ -0,0 +1,65 @@
+package ring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestA2Bloom ensures data added within the window is always found, and data
+// older than two windows is forgotten.
+func TestA2Bloom(t *testing.T) {
+	a, err := NewA2(100, fpRate)
+	require.NoError(t, err)
+	buff := make([]byte, 4)
+	for i := 0; i < 1000; i++ {
+		intToByte(buff, i)
+		a.Add(buff)
+		for j := i - 99; j <= i; j++ {
+			if j >= 0 {
+				intToByte(buff, j)
+				require.True(t, a.Test(buff), "added %d element %d", i, j)
+			}
+		}
+	}
+
+	forgotten := 0
+	for i := 0; i < 800; i++ {
+		intToByte(buff, i)
+		if !a.Test(buff) {
+			forgotten++
+		}
+	}
+	require.True(t, forgotten > 790, "forgotten %d", forgotten)
+
+	_, err = NewA2(0, fpRate)
+	require.Equal(t, errElements, err)
+}
+
+// TestA2Bloom_TestAndAdd ensures replays within the window are detected, and
+// data found only in the standby ring is kept in the window.
+func TestA2Bloom_TestAndAdd(t *testing.T) {
+	a, _ := NewA2(10, fpRate)
+	require.False(t, a.TestAndAdd([]byte("message")))
+	require.True(t, a.TestAndAdd([]byte("message")))
+	require.Equal(t, uint64(1), a.count)
+
+	a.Rotate()
+	require.True(t, a.TestAndAdd([]byte("message")))
+	require.Equal(t, uint64(1), a.count)
+	a.Rotate()
+	require.True(t, a.Test([]byte("message")))
+	a.Rotate()
+	require.False(t, a.TestAndAdd([]byte("message")))
+}
+
+// TestA2Bloom_RandomSeed ensures both rings share one random seed, so that
+// testing absent data does not panic on mismatched hashing.
+func TestA2Bloom_RandomSeed(t *testing.T) {
+	a, err := NewA2(100, 0.01, WithRandomSeed())
+	require.NoError(t, err)
+	a.Add([]byte("present"))
+	require.True(t, a.Test([]byte("present")))
+	require.NotPanics(t, func() { a.Test([]byte("absent")) })
+	require.Equal(t, a.active.hashing, a.standby.hashing)
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"math"
+	"time"
+)
+
+// maxAgingSamples is the number of fill samples kept for AgingReport.
+const maxAgingSamples = 16
+
+// agingSample is the fill of the ring at a point in time.
+type agingSample struct {
+	at  time.Time
+	set uint64 // number of set bits
+}
+
+// FillSample is the fraction of set bits in the ring at a point in time.
+type FillSample struct {
+	At        time.Time
+	FillRatio float64
+}
+
+// AgingReport describes how far a ring has progressed towards saturation.
+type AgingReport struct {
+	Age            time.Duration // time since the ring was initialized
+	TimeSinceReset time.Duration // time since the ring was last reset
+	Generation     uint64        // number of times the ring has been reset
+	FillRatio      float64       // current fraction of set bits
+
+	// Samples contains the fill taken by each AgingReport since the last reset,
+	// oldest first and including this one.
+	Samples []FillSample
+
+	// ProjectedSaturation is the estimated time until the ring holds as many
+	// elements as its parameters are optimal for, at which point half of the
+	// bits are set. It is 0 if that point has been reached and negative if no
+	// growth has been observed to project from.
+	ProjectedSaturation time.Duration
+}
+
+// Age returns the time since the ring was initialized.
+func (r *Bloom) Age() time.Duration {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return time.Since(r.created)
+}
+
+// TimeSinceReset returns the time since the ring was initialized or last reset.
+func (r *Bloom) TimeSinceReset() time.Duration {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return time.Since(r.resetAt)
+}
+
+// AgingReport samples the current fill of the ring and returns it along with
+// the samples taken since the last reset and a projection of when the ring
+// will saturate. The projection assumes elements keep being added at the rate
+// seen since the oldest sample, or since the reset if there is none.
+func (r *Bloom) AgingReport() AgingReport {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	current := agingSample{at: now, set: countBits(r.bits)}
+	base := agingSample{at: r.resetAt}
+	if len(r.samples) > 0 {
+		base = r.samples[0]
+	}
+	if len(r.samples) == maxAgingSamples {
+		copy(r.samples, r.samples[1:])
+		r.samples = r.samples[:maxAgingSamples-1]
+	}
+	r.samples = append(r.samples, current)
+
+	report := AgingReport{
+		Age:                 now.Sub(r.created),
+		TimeSinceReset:      now.Sub(r.resetAt),
+		Generation:          r.generation,
+		FillRatio:           float64(current.set) / float64(r.size),
+		Samples:             make([]FillSample, len(r.samples)),
+		ProjectedSaturation: -1,
+	}
+	for i, sample := range r.samples {
+		report.Samples[i] = FillSample{
+			At:        sample.at,
+			FillRatio: float64(sample.set) / float64(r.size),
+		}
+	}
+
+	// project in elements rather than bits, as elements arrive linearly
+	saturated := float64(r.size) * math.Ln2 / float64(r.hash)
+	elements := estimateElements(r.size, r.hash, current.set)
+	grown := elements - estimateElements(r.size, r.hash, base.set)
+	elapsed := current.at.Sub(base.at)
+	switch {
+	case elements >= saturated:
+		report.ProjectedSaturation = 0
+	case grown > 0 && elapsed > 0:
+		remaining := (saturated - elements) / grown * float64(elapsed)
+		report.ProjectedSaturation = time.Duration(remaining)
+	}
+	return report
+}
@@ -0,0 +1,71 @@
+package ring
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestBloom_Age ensures the age and time since reset are tracked.
+func TestBloom_Age(t *testing.T) {
+	r, err := Init(100, fpRate)
+	require.NoError(t, err)
+	r.created = r.created.Add(-time.Hour)
+	r.resetAt = r.created
+
+	require.True(t, r.Age() >= time.Hour)
+	require.True(t, r.TimeSinceReset() >= time.Hour)
+
+	r.Reset()
+	require.True(t, r.Age() >= time.Hour)
+	require.True(t, r.TimeSinceReset() < time.Hour)
+	require.Equal(t, uint64(1), r.AgingReport().Generation)
+
+	r2 := new(Bloom)
+	out, _ := r.MarshalBinary()
+	require.NoError(t, r2.UnmarshalBinary(out))
+	require.True(t, r2.Age() < time.Hour)
+}
+
+// TestBloom_AgingReport ensures the samples are recorded and the saturation
+// projection follows the rate elements are added at.
+func TestBloom_AgingReport(t *testing.T) {
+	r, err := Init(10000, 0.01)
+	require.NoError(t, err)
+
+	report := r.AgingReport()
+	require.Zero(t, report.FillRatio)
+	require.Len(t, report.Samples, 1)
+	require.True(t, report.ProjectedSaturation < 0)
+
+	// add a quarter of the capacity over a simulated minute
+	r.samples[0].at = r.samples[0].at.Add(-time.Minute)
+	buff := make([]byte, 4)
+	for i := 0; i < 2500; i++ {
+		intToByte(buff, i)
+		r.Add(buff)
+	}
+	report = r.AgingReport()
+	require.Len(t, report.Samples, 2)
+	require.Equal(t, report.FillRatio, report.Samples[1].FillRatio)
+	saturated := float64(r.size) * math.Ln2 / float64(r.hash)
+	expected := (saturated - 2500) / 2500 * float64(time.Minute)
+	require.InDelta(t, expected, report.ProjectedSaturation,
+		float64(5*time.Second))
+
+	for i := 2500; i < 20000; i++ {
+		intToByte(buff, i)
+		r.Add(buff)
+	}
+	require.Zero(t, r.AgingReport().ProjectedSaturation)
+
+	for i := 0; i < 2*maxAgingSamples; i++ {
+		r.AgingReport()
+	}
+	require.Len(t, r.AgingReport().Samples, maxAgingSamples)
+
+	r.Reset()
+	require.Len(t, r.AgingReport().Samples, 1)
+}
@@ -0,0 +1,307 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	errQueueSize = errors.New("error: QueueSize must be greater than 0")
+	errBatchSize = errors.New("error: BatchSize must not be negative")
+)
+
+// sideFalsePositive is the target false positive rate of the side filter used
+// for read-your-writes, sized for a full queue.
+const sideFalsePositive = 0.001
+
+// AsyncConfig contains the parameters of an AsyncBloom.
+type AsyncConfig struct {
+	QueueSize int // maximum number of pending Adds before Add blocks
+	BatchSize int // maximum number of Adds applied per lock, 0 uses QueueSize
+
+	// ReadYourWrites makes Test also consult a small side filter holding the
+	// Adds that have not been applied yet, so data is never reported absent
+	// once Add or a successful TryAdd has returned. The side filter is cleared
+	// whenever the queue is fully applied; under sustained load that never
+	// drains the queue it keeps filling and adds to the false positive rate.
+	ReadYourWrites bool
+}
+
+// AsyncStats contains the queue metrics of an AsyncBloom.
+type AsyncStats struct {
+	QueueDepth    int    // number of Adds waiting in the queue
+	QueueCapacity int    // maximum number of Adds the queue holds
+	Applied       uint64 // number of Adds written to the filter
+	Batches       uint64 // number of batches written to the filter
+	Rejected      uint64 // number of TryAdd calls refused by a full queue
+}
+
+// asyncItem is an entry in the submission queue. It either holds the hashes of
+// an Add or, when flush is set, a marker to be sent the error of the applier,
+// or nil, once every preceding entry has been applied.
+type asyncItem struct {
+	hash  [4]uint64
+	flush chan error
+}
+
+// AsyncBloom wraps a Bloom so that Add only hashes the data and places it on a
+// bounded queue. A background goroutine applies queued Adds to the filter in
+// batches, taking the write lock once per batch. Unless ReadYourWrites is set,
+// Test reads the filter directly, so an Add may not be visible to Test until it
+// has been applied. Data is hashed with the hash family and seed the filter
+// had when the wrapper was created. If the filter is frozen or destroyed, or
+// its hashing is replaced by CopyFrom or UnmarshalBinary, the applier stops
+// writing to it and records the error, which Err, Flush and Close return;
+// later Adds are discarded.
+type AsyncBloom struct {
+	applied  uint64 // accessed atomically
+	batches  uint64 // accessed atomically
+	rejected uint64 // accessed atomically
+
+	bloom     *Bloom
+	hashing   hashing // hash family and seed of the filter at creation
+	queue     chan asyncItem
+	batchSize int
+	done      chan struct{}
+	closeOnce sync.Once
+
+	errMutex sync.Mutex // guards err
+	err      error      // error that stopped the applier, if any
+
+	// read-your-writes state, side is nil if disabled
+	side      *Bloom
+	sideMutex sync.Mutex // guards side and pending
+	pending   int        // number of Adds in side not yet applied
+}
+
+// NewAsyncBloom starts the background applier for the filter and returns the
+// wrapper, or an error if the config is invalid. Close must be called to stop
+// the applier.
+func NewAsyncBloom(r *Bloom, config AsyncConfig) (*AsyncBloom, error) {
+	if config.QueueSize <= 0 {
+		return nil, errQueueSize
+	}
+	if config.BatchSize < 0 {
+		return nil, errBatchSize
+	}
+
+	a := &AsyncBloom{
+		bloom:     r,
+		hashing:   r.currentHashing(),
+		queue:     make(chan asyncItem, config.QueueSize),
+		batchSize: config.BatchSize,
+		done:      make(chan struct{}),
+	}
+	if a.batchSize == 0 {
+		a.batchSize = config.QueueSize
+	}
+	if config.ReadYourWrites {
+		a.side, _ = Init(config.QueueSize, sideFalsePositive)
+	}
+	go a.apply()
+	return a, nil
+}
+
+// Add queues the data to be added to the filter. It blocks while the queue is
+// full. It returns the error that stopped the applier, in which case the data
+// is not added. Add must not be called after Close.
+func (a *AsyncBloom) Add(data []byte) error {
+	if err := a.Err(); err != nil {
+		return err
+	}
+	item := asyncItem{hash: a.hashing.sum(data)}
+	a.addPending(item.hash)
+	a.queue <- item
+	return nil
+}
+
+// TryAdd queues the data to be added to the filter without blocking. It returns
+// false if the queue is full or the applier has stopped, and the data was not
+// queued.
+func (a *AsyncBloom) TryAdd(data []byte) bool {
+	if a.Err() != nil {
+		return false
+	}
+	item := asyncItem{hash: a.hashing.sum(data)}
+	a.addPending(item.hash)
+	select {
+	case a.queue <- item:
+		return true
+	default:
+		// the bits stay in the side filter until it is next cleared
+		a.removePending(1)
+		atomic.AddUint64(&a.rejected, 1)
+		return false
+	}
+}
+
+// Test returns a bool if the data is in the filter. Queued Adds that have not
+// been applied are only seen if ReadYourWrites is set.
+func (a *AsyncBloom) Test(data []byte) bool {
+	hash := a.hashing.sum(data)
+	// the side filter must be checked first, as it is only cleared after its
+	// Adds are in the main filter
+	if a.side != nil {
+		a.sideMutex.Lock()
+		found := a.side.testHash(hash)
+		a.sideMutex.Unlock()
+		if found {
+			return true
+		}
+	}
+	a.bloom.mutex.RLock()
+	defer a.bloom.mutex.RUnlock()
+	a.bloom.mustBeUsable("test")
+	if a.bloom.hashing != a.hashing {
+		hash = a.bloom.hashing.sum(data)
+	}
+	return a.bloom.test(hash)
+}
+
+// Flush blocks until every Add queued before the call has been applied. It
+// returns the error that stopped the applier if it was stopped before all of
+// them were.
+func (a *AsyncBloom) Flush() error {
+	flush := make(chan error, 1)
+	a.queue <- asyncItem{flush: flush}
+	return <-flush
+}
+
+// Close applies the remaining queued Adds and stops the background applier.
+// It returns the error that stopped the applier earlier, if any.
+func (a *AsyncBloom) Close() error {
+	a.closeOnce.Do(func() {
+		close(a.queue)
+	})
+	<-a.done
+	return a.Err()
+}
+
+// Err returns the error that stopped the applier, or nil if it is running.
+func (a *AsyncBloom) Err() error {
+	a.errMutex.Lock()
+	defer a.errMutex.Unlock()
+	return a.err
+}
+
+// Bloom returns the wrapped filter.
+func (a *AsyncBloom) Bloom() *Bloom {
+	return a.bloom
+}
+
+// QueueDepth returns the number of Adds waiting in the queue.
+func (a *AsyncBloom) QueueDepth() int {
+	return len(a.queue)
+}
+
+// Stats returns the current queue metrics.
+func (a *AsyncBloom) Stats() AsyncStats {
+	return AsyncStats{
+		QueueDepth:    len(a.queue),
+		QueueCapacity: cap(a.queue),
+		Applied:       atomic.LoadUint64(&a.applied),
+		Batches:       atomic.LoadUint64(&a.batches),
+		Rejected:      atomic.LoadUint64(&a.rejected),
+	}
+}
+
+// apply drains the queue in batches until it is closed.
+func (a *AsyncBloom) apply() {
+	defer close(a.done)
+	batch := make([]asyncItem, 0, a.batchSize)
+	for item := range a.queue {
+		batch = append(batch[:0], item)
+	fill:
+		for len(batch) < a.batchSize {
+			select {
+			case next, ok := <-a.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, next)
+			default:
+				break fill
+			}
+		}
+		a.applyBatch(batch)
+	}
+}
+
+// applyBatch writes a batch to the filter under a single lock, then releases
+// any flush markers in the batch. If the filter may no longer be modified, it
+// records the error and discards the batch, as it does every batch after.
+func (a *AsyncBloom) applyBatch(batch []asyncItem) {
+	err := a.Err()
+	applied := uint64(0)
+	if err == nil {
+		a.bloom.mutex.Lock()
+		err = a.bloom.checkMutable("add to")
+		if err == nil && a.bloom.hashing != a.hashing {
+			// the queued hashes would set the wrong bits
+			err = errHashing
+		}
+		if err != nil {
+			a.bloom.mutex.Unlock()
+			a.errMutex.Lock()
+			a.err = err
+			a.errMutex.Unlock()
+		} else {
+			for _, item := range batch {
+				if item.flush == nil {
+					a.bloom.add(item.hash)
+					applied++
+				}
+			}
+			a.bloom.writeUnlock()
+			atomic.AddUint64(&a.applied, applied)
+			atomic.AddUint64(&a.batches, 1)
+		}
+	}
+
+	adds := 0
+	for _, item := range batch {
+		if item.flush == nil {
+			adds++
+		}
+	}
+	a.removePending(adds)
+	for _, item := range batch {
+		if item.flush != nil {
+			item.flush <- err
+		}
+	}
+}
+
+// addPending records the hashes in the side filter, if enabled.
+func (a *AsyncBloom) addPending(hash [4]uint64) {
+	if a.side == nil {
+		return
+	}
+	a.sideMutex.Lock()
+	a.side.setHash(hash)
+	a.pending++
+	a.sideMutex.Unlock()
+}
+
+// removePending marks Adds as no longer pending, clearing the side filter once
+// none remain.
+func (a *AsyncBloom) removePending(n int) {
+	if a.side == nil {
+		return
+	}
+	a.sideMutex.Lock()
+	a.pending -= n
+	if a.pending == 0 {
+		for i := range a.side.bits {
+			a.side.bits[i] = 0
+		}
+		a.side.digest = 0
+		a.side.set = 0
+	}
+	a.sideMutex.Unlock()
+}
@@ -0,0 +1,183 @@
+package ring
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestAsyncBloom ensures that queued Adds are applied to the filter.
+func TestAsyncBloom(t *testing.T) {
+	r, err := Init(10000, fpRate)
+	require.NoError(t, err)
+	a, err := NewAsyncBloom(r, AsyncConfig{QueueSize: 64, BatchSize: 16})
+	require.NoError(t, err)
+	require.Equal(t, r, a.Bloom())
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			buff := make([]byte, 4)
+			for i := w * 2500; i < (w+1)*2500; i++ {
+				intToByte(buff, i)
+				a.Add(buff)
+			}
+		}(w)
+	}
+	wg.Wait()
+	a.Flush()
+
+	buff := make([]byte, 4)
+	for i := 0; i < 10000; i++ {
+		intToByte(buff, i)
+		require.True(t, a.Test(buff), "element %d not found", i)
+	}
+
+	stats := a.Stats()
+	require.Equal(t, uint64(10000), stats.Applied)
+	require.Equal(t, 64, stats.QueueCapacity)
+	require.Zero(t, stats.QueueDepth)
+	require.True(t, stats.Batches > 0)
+	a.Close()
+	a.Close()
+}
+
+// TestAsyncBloom_Frozen ensures freezing the filter stops the applier with an
+// error rather than a panic, and no call blocks afterwards.
+func TestAsyncBloom_Frozen(t *testing.T) {
+	r, _ := Init(1000, fpRate)
+	a, err := NewAsyncBloom(r, AsyncConfig{QueueSize: 2, BatchSize: 1})
+	require.NoError(t, err)
+	require.NoError(t, a.Add([]byte("before")))
+	require.NoError(t, a.Flush())
+	require.NoError(t, a.Err())
+
+	require.NoError(t, r.Transition(StateFrozen))
+	require.NoError(t, a.Add([]byte("after")))
+	err = a.Flush()
+	require.IsType(t, &StateError{}, err)
+	require.Equal(t, err, a.Err())
+	require.Equal(t, err, a.Add([]byte("later")))
+	require.False(t, a.TryAdd([]byte("later")))
+	require.True(t, a.Test([]byte("before")))
+	require.False(t, r.Test([]byte("after")))
+	require.Equal(t, err, a.Close())
+}
+
+// TestAsyncBloom_Rehashed ensures replacing the hashing of the filter stops
+// the applier rather than setting bits for the old hashing.
+func TestAsyncBloom_Rehashed(t *testing.T) {
+	r, _ := Init(1000, fpRate)
+	a, _ := NewAsyncBloom(r, AsyncConfig{QueueSize: 4})
+	seeded, _ := Init(1000, fpRate, WithSeed(3))
+	seeded.Add([]byte("seeded"))
+	require.NoError(t, r.CopyFrom(seeded))
+	require.NoError(t, a.Add([]byte("data")))
+	require.Equal(t, errHashing, a.Flush())
+	require.True(t, a.Test([]byte("seeded")))
+	require.Equal(t, errHashing, a.Close())
+}
+
+// TestAsyncBloom_TryAdd ensures TryAdd refuses data once the queue is full and
+// Close applies the remaining queue.
+func TestAsyncBloom_TryAdd(t *testing.T) {
+	r, err := Init(100, fpRate)
+	require.NoError(t, err)
+	a, err := NewAsyncBloom(r, AsyncConfig{QueueSize: 2})
+	require.NoError(t, err)
+
+	// stall the applier so the queue fills
+	r.mutex.Lock()
+	buff := make([]byte, 4)
+	queued := 0
+	for i := 0; ; i++ {
+		intToByte(buff, i)
+		if !a.TryAdd(buff) {
+			break
+		}
+		queued++
+	}
+	require.Equal(t, uint64(1), a.Stats().Rejected)
+	require.Equal(t, 2, a.QueueDepth())
+	r.mutex.Unlock()
+
+	a.Close()
+	require.Equal(t, uint64(queued), a.Stats().Applied)
+	for i := 0; i < queued; i++ {
+		intToByte(buff, i)
+		require.True(t, r.Test(buff))
+	}
+}
+
+// TestNewAsyncBloom_BadConfig ensures that invalid configs return an error.
+func TestNewAsyncBloom_BadConfig(t *testing.T) {
+	r, _ := Init(100, fpRate)
+	_, err := NewAsyncBloom(r, AsyncConfig{})
+	require.Error(t, err)
+	_, err = NewAsyncBloom(r, AsyncConfig{QueueSize: 1, BatchSize: -1})
+	require.Error(t, err)
+}
+
+// TestAsyncBloom_ReadYourWrites ensures that pending Adds are seen by Test and
+// the side filter is cleared once they are applied.
+func TestAsyncBloom_ReadYourWrites(t *testing.T) {
+	r, err := Init(1000, fpRate)
+	require.NoError(t, err)
+	a, err := NewAsyncBloom(r, AsyncConfig{QueueSize: 8, ReadYourWrites: true})
+	require.NoError(t, err)
+
+	// stall the applier so the Adds stay pending
+	r.mutex.Lock()
+	buff := make([]byte, 4)
+	queued := 0
+	for i := 0; a.TryAdd(buff); i++ {
+		queued++
+		intToByte(buff, i+1)
+	}
+	for i := 0; i < queued; i++ {
+		intToByte(buff, i)
+		require.True(t, a.side.Test(buff), "element %d not pending", i)
+	}
+	r.mutex.Unlock()
+
+	a.Flush()
+	require.Zero(t, a.pending)
+	for i := 0; i < queued; i++ {
+		intToByte(buff, i)
+		require.True(t, a.Test(buff))
+		require.False(t, a.side.Test(buff))
+	}
+	a.Close()
+}
+
+// TestAsyncBloom_ReadYourWritesConcurrent ensures Test never misses data once
+// Add has returned while the applier runs.
+func TestAsyncBloom_ReadYourWritesConcurrent(t *testing.T) {
+	r, err := Init(20000, fpRate)
+	require.NoError(t, err)
+	a, err := NewAsyncBloom(r, AsyncConfig{QueueSize: 32, BatchSize: 4,
+		ReadYourWrites: true})
+	require.NoError(t, err)
+	defer a.Close()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			buff := make([]byte, 4)
+			for i := w * 5000; i < (w+1)*5000; i++ {
+				intToByte(buff, i)
+				a.Add(buff)
+				if !a.Test(buff) {
+					t.Errorf("element %d not found after Add", i)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,294 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package bloomier provides Bloomier filters, which map each key of a final
+// set to a small value, such as a routing tag, in a few bits more than the
+// values themselves, without storing the keys. A key not in the set is
+// reported absent except at a false positive rate, when it is given an
+// arbitrary value.
+package bloomier
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+var (
+	errBuild         = errors.New("error: could not build the filter")
+	errValueBits     = errors.New("error: valueBits must be between 1 and 32")
+	errFalsePositive = errors.New("error: falsePositive must be greater than 0 and less than 1")
+	errWidth         = errors.New("error: value and fingerprint bits must total at most 57")
+	errValue         = errors.New("error: a value does not fit in valueBits")
+)
+
+const (
+	// maxAttempts is the number of seeds tried before giving up on a build.
+	maxAttempts = 100
+	// maxWidth is the largest number of bits in a slot, so that a slot read
+	// from any bit offset fits in 64 bits.
+	maxWidth = 57
+	// headerSize is the number of bytes preceding the slots in the output of
+	// MarshalBinary: 1 byte of version, 1 byte each of value and fingerprint
+	// bits, 8 bytes of seed, and 4 bytes of block length.
+	headerSize = 15
+)
+
+// Bloomier is a Bloomier filter. Like an xor filter, a key maps to one slot
+// in each of three blocks, and the XOR of the slots is the fingerprint of the
+// key followed by its value. It is immutable, and so safe for any number of
+// concurrent readers.
+type Bloomier struct {
+	seed        uint64
+	blockLength uint32
+	valueBits   uint
+	fpBits      uint
+	slots       []uint8 // 3 * blockLength slots of valueBits+fpBits, packed
+}
+
+// BuildBloomier returns a Bloomier filter mapping each key of entries to its
+// value, which must fit in valueBits bits, with keys not in entries found at
+// the falsePositive rate, or an error. The keys should be well distributed,
+// for example hashes of the data.
+func BuildBloomier(entries map[uint64]uint32, valueBits uint,
+	falsePositive float64) (*Bloomier, error) {
+	if valueBits == 0 || valueBits > 32 {
+		return nil, errValueBits
+	}
+	if falsePositive <= 0 || falsePositive >= 1 {
+		return nil, errFalsePositive
+	}
+	fpBits := uint(math.Ceil(-math.Log2(falsePositive)))
+	if valueBits+fpBits > maxWidth {
+		return nil, errWidth
+	}
+
+	keys := make([]uint64, 0, len(entries))
+	for key, value := range entries {
+		if uint64(value) > mask(valueBits) {
+			return nil, errValue
+		}
+		keys = append(keys, key)
+	}
+	// the order of keys decides the peeling order, so fix it
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	capacity := 32 + uint32(1.23*float64(len(keys)))
+	f := &Bloomier{
+		blockLength: capacity / 3,
+		valueBits:   valueBits,
+		fpBits:      fpBits,
+	}
+	capacity = 3 * f.blockLength
+
+	type set struct {
+		xorMask uint64
+		count   uint32
+	}
+	type keyIndex struct {
+		key   uint64
+		index uint32
+	}
+	sets := make([]set, capacity)
+	queue := make([]uint32, 0, capacity)
+	stack := make([]keyIndex, 0, len(keys))
+
+	seed := uint64(0xbb67ae8584caa73b)
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		seed = splitmix(seed)
+		f.seed = seed
+		for i := range sets {
+			sets[i] = set{}
+		}
+		// the XOR of the keys of a slot is the key once a single one is left
+		for _, key := range keys {
+			h0, h1, h2 := f.locate(key)
+			for _, i := range [3]uint32{h0, h1, h2} {
+				sets[i].xorMask ^= key
+				sets[i].count++
+			}
+		}
+
+		queue = queue[:0]
+		stack = stack[:0]
+		for i := range sets {
+			if sets[i].count == 1 {
+				queue = append(queue, uint32(i))
+			}
+		}
+		for len(queue) > 0 {
+			index := queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+			if sets[index].count != 1 {
+				continue
+			}
+			key := sets[index].xorMask
+			stack = append(stack, keyIndex{key: key, index: index})
+			h0, h1, h2 := f.locate(key)
+			for _, i := range [3]uint32{h0, h1, h2} {
+				sets[i].xorMask ^= key
+				sets[i].count--
+				if sets[i].count == 1 {
+					queue = append(queue, i)
+				}
+			}
+		}
+		if len(stack) != len(keys) {
+			continue
+		}
+
+		f.slots = make([]uint8, slotsSize(capacity, f.width()))
+		for i := len(stack) - 1; i >= 0; i-- {
+			key := stack[i].key
+			h0, h1, h2 := f.locate(key)
+			// the slot of the key is still 0, so contributes nothing here
+			v := f.entry(key, entries[key]) ^ f.get(h0) ^ f.get(h1) ^ f.get(h2)
+			f.set(stack[i].index, v)
+		}
+		return f, nil
+	}
+	return nil, errBuild
+}
+
+// Get returns the value of the key and true if the key may be in the filter,
+// or false if it is not. A key not in the filter is reported present at the
+// false positive rate, with an arbitrary value.
+func (f *Bloomier) Get(key uint64) (uint32, bool) {
+	h0, h1, h2 := f.locate(key)
+	v := f.get(h0) ^ f.get(h1) ^ f.get(h2)
+	if v>>f.valueBits != f.fingerprint(key) {
+		return 0, false
+	}
+	return uint32(v & mask(f.valueBits)), true
+}
+
+// SizeInBytes returns the size of the slots of the filter.
+func (f *Bloomier) SizeInBytes() int {
+	return len(f.slots)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface. The output
+// is 1 byte of version, 1 byte each of value and fingerprint bits, the seed as
+// 8 bytes, the block length as 4 bytes, then the slots. Integers are big
+// endian.
+func (f *Bloomier) MarshalBinary() ([]byte, error) {
+	out := make([]byte, headerSize+len(f.slots))
+	out[0] = 1
+	out[1] = uint8(f.valueBits)
+	out[2] = uint8(f.fpBits)
+	binary.BigEndian.PutUint64(out[3:11], f.seed)
+	binary.BigEndian.PutUint32(out[11:15], f.blockLength)
+	copy(out[headerSize:], f.slots)
+	return out, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (f *Bloomier) UnmarshalBinary(data []byte) error {
+	if len(data) < headerSize {
+		return fmt.Errorf("incorrect length: %d", len(data))
+	}
+	if data[0] != 1 {
+		return fmt.Errorf("unexpected version: %d", data[0])
+	}
+	valueBits, fpBits := uint(data[1]), uint(data[2])
+	if valueBits == 0 || valueBits > 32 {
+		return errValueBits
+	}
+	if fpBits == 0 || valueBits+fpBits > maxWidth {
+		return errWidth
+	}
+	blockLength := binary.BigEndian.Uint32(data[11:15])
+	if blockLength == 0 || uint64(len(data)-headerSize) !=
+		slotsSize(3*blockLength, valueBits+fpBits) {
+		return fmt.Errorf("incorrect length: %d", len(data))
+	}
+	f.valueBits = valueBits
+	f.fpBits = fpBits
+	f.seed = binary.BigEndian.Uint64(data[3:11])
+	f.blockLength = blockLength
+	f.slots = append([]uint8{}, data[headerSize:]...)
+	return nil
+}
+
+// locate returns the slot of the key in each of the three blocks.
+func (f *Bloomier) locate(key uint64) (uint32, uint32, uint32) {
+	h := mix(key + f.seed)
+	return reduce(uint32(h), f.blockLength),
+		reduce(uint32(rotl(h, 21)), f.blockLength) + f.blockLength,
+		reduce(uint32(rotl(h, 42)), f.blockLength) + 2*f.blockLength
+}
+
+// fingerprint returns the fingerprint of the key.
+func (f *Bloomier) fingerprint(key uint64) uint64 {
+	return mix(key^f.seed) & mask(f.fpBits)
+}
+
+// entry returns the contents of the slots of the key XORed together: its
+// fingerprint followed by its value.
+func (f *Bloomier) entry(key uint64, value uint32) uint64 {
+	return f.fingerprint(key)<<f.valueBits | uint64(value)
+}
+
+// width returns the number of bits in a slot.
+func (f *Bloomier) width() uint {
+	return f.valueBits + f.fpBits
+}
+
+// get returns slot i.
+func (f *Bloomier) get(i uint32) uint64 {
+	bit := uint64(i) * uint64(f.width())
+	var buff [8]byte
+	copy(buff[:], f.slots[bit/8:])
+	return binary.LittleEndian.Uint64(buff[:]) >> (bit % 8) & mask(f.width())
+}
+
+// set stores v in slot i, which must be 0.
+func (f *Bloomier) set(i uint32, v uint64) {
+	bit := uint64(i) * uint64(f.width())
+	word := v << (bit % 8)
+	for j := bit / 8; word != 0; j++ {
+		f.slots[j] |= uint8(word)
+		word >>= 8
+	}
+}
+
+// slotsSize returns the number of bytes holding n slots of width bits.
+func slotsSize(n uint32, width uint) uint64 {
+	return (uint64(n)*uint64(width) + 7) / 8
+}
+
+// mask returns a mask of the low n bits.
+func mask(n uint) uint64 {
+	return 1<<n - 1
+}
+
+// reduce maps x to [0, n) without division.
+func reduce(x, n uint32) uint32 {
+	return uint32(uint64(x) * uint64(n) >> 32)
+}
+
+// rotl rotates x left by r bits.
+func rotl(x uint64, r uint) uint64 {
+	return x<<r | x>>(64-r)
+}
+
+// mix is the 64-bit MurmurHash3 finalizer, spreading the bits of the key.
+func mix(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// splitmix returns the next seed after x.
+func splitmix(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
@@ -0,0 +1,79 @@
+package bloomier
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// entries returns n entries with well distributed keys and values of
+// valueBits bits.
+func entries(n int, valueBits uint) map[uint64]uint32 {
+	out := make(map[uint64]uint32, n)
+	for i := 0; i < n; i++ {
+		out[splitmix(uint64(i))] = uint32(uint64(i) & mask(valueBits))
+	}
+	return out
+}
+
+// TestBuildBloomier ensures every key maps to its value and other keys are
+// found at the false positive rate.
+func TestBuildBloomier(t *testing.T) {
+	in := entries(100000, 6)
+	f, err := BuildBloomier(in, 6, 0.01)
+	require.NoError(t, err)
+	for key, value := range in {
+		got, ok := f.Get(key)
+		require.True(t, ok)
+		require.Equal(t, value, got)
+	}
+
+	positives := 0
+	for i := 100000; i < 1100000; i++ {
+		if _, ok := f.Get(splitmix(uint64(i))); ok {
+			positives++
+		}
+	}
+	require.True(t, float64(positives)/1000000 < 0.01, "false positives %d",
+		positives)
+	require.InDelta(t, 1.23*(6+7), float64(8*f.SizeInBytes())/100000, 0.1)
+}
+
+// TestBuildBloomier_Invalid ensures bad parameters and values are rejected,
+// and that an empty map builds.
+func TestBuildBloomier_Invalid(t *testing.T) {
+	_, err := BuildBloomier(map[uint64]uint32{1: 4}, 2, 0.01)
+	require.Equal(t, errValue, err)
+	_, err = BuildBloomier(nil, 0, 0.01)
+	require.Equal(t, errValueBits, err)
+	_, err = BuildBloomier(nil, 4, 1)
+	require.Equal(t, errFalsePositive, err)
+	_, err = BuildBloomier(nil, 32, 1e-9)
+	require.Equal(t, errWidth, err)
+
+	f, err := BuildBloomier(nil, 4, 0.01)
+	require.NoError(t, err)
+	_, ok := f.Get(1)
+	require.False(t, ok)
+}
+
+// TestBloomier_Marshal ensures the filter round trips and bad data is
+// rejected.
+func TestBloomier_Marshal(t *testing.T) {
+	in := entries(1000, 16)
+	f, _ := BuildBloomier(in, 16, 0.001)
+	out, err := f.MarshalBinary()
+	require.NoError(t, err)
+
+	u := new(Bloomier)
+	require.NoError(t, u.UnmarshalBinary(out))
+	require.Equal(t, f, u)
+
+	require.Error(t, u.UnmarshalBinary(out[:len(out)-1]))
+	require.Error(t, u.UnmarshalBinary(out[:headerSize-1]))
+	bad := append([]byte{}, out...)
+	bad[1] = 33
+	require.Error(t, u.UnmarshalBinary(bad))
+	bad[1], bad[0] = 16, 2
+	require.Error(t, u.UnmarshalBinary(bad))
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var errStaleness = errors.New("error: MaxStaleness must not be negative")
+
+// CacheSource is a filter held elsewhere, such as on a remote node, that a
+// CachedBloom keeps a local snapshot of.
+type CacheSource interface {
+	// Fetch returns the output of MarshalBinary for the current filter and a
+	// generation that increases whenever the filter changes.
+	Fetch(ctx context.Context) (data []byte, generation uint64, err error)
+}
+
+// CacheConfig contains the staleness budget of a CachedBloom. A snapshot is
+// stale once either bound is exceeded; a zero bound is not checked.
+type CacheConfig struct {
+	MaxStaleness     time.Duration // age of the snapshot
+	MaxGenerationLag uint64        // generations the snapshot is behind the source
+}
+
+// CachedBloom fronts a CacheSource with a local snapshot. Test always answers
+// from the snapshot without waiting on the source, starting a refresh in the
+// background once the snapshot is stale, so it has a predictable latency but
+// may miss data added within the staleness budget. TestFresh fetches the
+// filter first and is authoritative.
+type CachedBloom struct {
+	source CacheSource
+	config CacheConfig
+	opts   []Option // supply the key of a keyed filter
+
+	mutex      sync.Mutex // guards the fields below
+	snapshot   *Bloom
+	fetchedAt  time.Time
+	generation uint64 // generation of the snapshot
+	latest     uint64 // highest generation observed from the source
+	refreshing bool
+	lastErr    error
+}
+
+// NewCachedBloom fetches the initial snapshot from the source and returns the
+// cache, or an error if the config is invalid or the fetch fails. Snapshots
+// are decoded into rings given the options, so a keyed filter can be read by
+// passing WithKey.
+func NewCachedBloom(ctx context.Context, source CacheSource,
+	config CacheConfig, opts ...Option) (*CachedBloom, error) {
+	if config.MaxStaleness < 0 {
+		return nil, errStaleness
+	}
+	c := &CachedBloom{source: source, config: config, opts: opts}
+	if err := c.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Test returns a bool if the data is in the snapshot. If the snapshot is stale
+// a refresh is started in the background, which this call does not wait for.
+func (c *CachedBloom) Test(data []byte) bool {
+	c.mutex.Lock()
+	snapshot := c.snapshot
+	if c.staleLocked() && !c.refreshing {
+		c.refreshing = true
+		go c.refreshBackground()
+	}
+	c.mutex.Unlock()
+	return snapshot.Test(data)
+}
+
+// TestFresh refreshes the snapshot and returns a bool if the data is in it. It
+// returns an error if the fetch fails.
+func (c *CachedBloom) TestFresh(ctx context.Context, data []byte) (bool, error) {
+	if err := c.Refresh(ctx); err != nil {
+		return false, err
+	}
+	c.mutex.Lock()
+	snapshot := c.snapshot
+	c.mutex.Unlock()
+	return snapshot.Test(data), nil
+}
+
+// Refresh replaces the snapshot with the current filter of the source. The
+// snapshot is kept if the fetch fails or the data cannot be decoded.
+func (c *CachedBloom) Refresh(ctx context.Context) error {
+	data, generation, err := c.source.Fetch(ctx)
+	if err != nil {
+		return err
+	}
+	snapshot, err := unmarshalWith(data, c.opts)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	// a slower concurrent refresh must not replace a newer snapshot
+	if c.snapshot != nil && generation < c.generation {
+		return nil
+	}
+	c.snapshot = snapshot
+	c.fetchedAt = time.Now()
+	c.generation = generation
+	if generation > c.latest {
+		c.latest = generation
+	}
+	return nil
+}
+
+// ObserveGeneration records that the source has reached the given generation,
+// for example from a change notification, so that Test refreshes once the
+// snapshot lags too far behind.
+func (c *CachedBloom) ObserveGeneration(generation uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if generation > c.latest {
+		c.latest = generation
+	}
+}
+
+// Staleness returns the age of the snapshot and how many generations it is
+// behind the latest one observed.
+func (c *CachedBloom) Staleness() (time.Duration, uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return time.Since(c.fetchedAt), c.latest - c.generation
+}
+
+// LastError returns the error of the most recent background refresh, or nil
+// if it succeeded.
+func (c *CachedBloom) LastError() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.lastErr
+}
+
+// staleLocked returns true if the snapshot has exceeded the staleness budget.
+// The caller must hold the mutex.
+func (c *CachedBloom) staleLocked() bool {
+	if c.config.MaxStaleness > 0 &&
+		time.Since(c.fetchedAt) > c.config.MaxStaleness {
+		return true
+	}
+	return c.config.MaxGenerationLag > 0 &&
+		c.latest-c.generation > c.config.MaxGenerationLag
+}
+
+// refreshBackground refreshes the snapshot and records the outcome.
+func (c *CachedBloom) refreshBackground() {
+	err := c.Refresh(context.Background())
+	c.mutex.Lock()
+	c.refreshing = false
+	c.lastErr = err
+	c.mutex.Unlock()
+}
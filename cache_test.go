@@ -0,0 +1,128 @@
+package ring
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testSource is a CacheSource serving a local ring.
+type testSource struct {
+	mutex      sync.Mutex
+	r          *Bloom
+	generation uint64
+	fetches    int
+	err        error
+}
+
+// Fetch implements CacheSource.
+func (s *testSource) Fetch(context.Context) ([]byte, uint64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.fetches++
+	if s.err != nil {
+		return nil, 0, s.err
+	}
+	data, err := s.r.MarshalBinary()
+	return data, s.generation, err
+}
+
+// add adds the data to the ring and advances the generation.
+func (s *testSource) add(data []byte) uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.r.Add(data)
+	s.generation++
+	return s.generation
+}
+
+// TestCachedBloom_Generation ensures Test answers from the snapshot until it
+// lags too many generations, and TestFresh is always current.
+func TestCachedBloom_Generation(t *testing.T) {
+	r, _ := Init(1000, 0.01)
+	src := &testSource{r: r}
+	c, err := NewCachedBloom(context.Background(), src,
+		CacheConfig{MaxGenerationLag: 1})
+	require.NoError(t, err)
+
+	src.add([]byte("a"))
+	require.False(t, c.Test([]byte("a")))
+	ok, err := c.TestFresh(context.Background(), []byte("a"))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	c.ObserveGeneration(src.add([]byte("b")))
+	require.False(t, c.Test([]byte("b")))
+	_, lag := c.Staleness()
+	require.Equal(t, uint64(1), lag)
+
+	// exceeding the lag starts a background refresh
+	c.ObserveGeneration(src.add([]byte("c")))
+	require.Eventually(t, func() bool { return c.Test([]byte("c")) },
+		time.Second, time.Millisecond)
+	require.True(t, c.Test([]byte("b")))
+	require.NoError(t, c.LastError())
+}
+
+// TestCachedBloom_Age ensures Test refreshes once the snapshot is too old.
+func TestCachedBloom_Age(t *testing.T) {
+	r, _ := Init(1000, 0.01)
+	src := &testSource{r: r}
+	c, err := NewCachedBloom(context.Background(), src,
+		CacheConfig{MaxStaleness: 10 * time.Millisecond})
+	require.NoError(t, err)
+
+	src.add([]byte("a"))
+	require.False(t, c.Test([]byte("a")))
+	time.Sleep(20 * time.Millisecond)
+	require.Eventually(t, func() bool { return c.Test([]byte("a")) },
+		time.Second, time.Millisecond)
+}
+
+// TestCachedBloom_FetchError ensures failed fetches keep the snapshot and are
+// reported.
+func TestCachedBloom_FetchError(t *testing.T) {
+	r, _ := Init(1000, 0.01)
+	r.Add([]byte("a"))
+	src := &testSource{r: r, err: errors.New("unavailable")}
+	_, err := NewCachedBloom(context.Background(), src, CacheConfig{})
+	require.Error(t, err)
+	_, err = NewCachedBloom(context.Background(), src,
+		CacheConfig{MaxStaleness: -1})
+	require.Error(t, err)
+
+	src.err = nil
+	c, err := NewCachedBloom(context.Background(), src,
+		CacheConfig{MaxGenerationLag: 1})
+	require.NoError(t, err)
+	src.err = errors.New("unavailable")
+	_, err = c.TestFresh(context.Background(), []byte("a"))
+	require.Error(t, err)
+
+	c.ObserveGeneration(5)
+	require.True(t, c.Test([]byte("a")))
+	require.Eventually(t, func() bool { return c.LastError() != nil },
+		time.Second, time.Millisecond)
+	require.True(t, c.Test([]byte("a")))
+}
+
+// TestCachedBloom_Keyed ensures a keyed filter is read given its key.
+func TestCachedBloom_Keyed(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	r, _ := Init(1000, 0.01, WithKey(key))
+	src := &testSource{r: r}
+	_, err := NewCachedBloom(context.Background(), src, CacheConfig{})
+	require.Equal(t, errNoKey, err)
+
+	c, err := NewCachedBloom(context.Background(), src, CacheConfig{},
+		WithKey(key))
+	require.NoError(t, err)
+	src.add([]byte("a"))
+	ok, err := c.TestFresh(context.Background(), []byte("a"))
+	require.NoError(t, err)
+	require.True(t, ok)
+}
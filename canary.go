@@ -0,0 +1,70 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+var (
+	errCanaries   = errors.New("error: canaries must be greater than 0")
+	errNoCanaries = errors.New("error: the ring was not created WithCanaries")
+)
+
+// canaryKeySize is the size of a canary key, random so that it is never
+// added by chance.
+const canaryKeySize = 16
+
+// WithCanaries reserves the given number of random canary keys, which are
+// never added to the ring, for ObservedFPRate to test. The keys are not
+// marshaled or copied with the ring. It returns an error from the constructor
+// if n is not positive or randomness cannot be read.
+func WithCanaries(n int) Option {
+	return func(r *Bloom) error {
+		return r.setCanaries(n, rand.Reader)
+	}
+}
+
+// setCanaries reserves n canary keys drawn from rng.
+func (r *Bloom) setCanaries(n int, rng io.Reader) error {
+	if n <= 0 {
+		return errCanaries
+	}
+	r.canaries = make([][canaryKeySize]byte, n)
+	for i := range r.canaries {
+		if _, err := io.ReadFull(rng, r.canaries[i][:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ObservedFPRate returns the fraction of the canary keys reserved by
+// WithCanaries that the ring reports present. As none were added, each is a
+// false positive, so this measures the false positive rate of the ring as it
+// is, with the hash function and data it actually has, rather than predicting
+// it as CurrentFalsePositiveRate does. Its precision is limited by the number
+// of canaries: measuring a rate of p to within 10% takes about 100/p of them.
+// It returns an error if the ring was not created WithCanaries, or a
+// *StateError if it has been destroyed.
+func (r *Bloom) ObservedFPRate() (float64, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if err := r.checkUsable("test"); err != nil {
+		return 0, err
+	}
+	if len(r.canaries) == 0 {
+		return 0, errNoCanaries
+	}
+	positives := 0
+	for i := range r.canaries {
+		if r.testHash(r.hashing.sum(r.canaries[i][:])) {
+			positives++
+		}
+	}
+	return float64(positives) / float64(len(r.canaries)), nil
+}
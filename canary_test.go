@@ -0,0 +1,46 @@
+package ring
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestBloom_ObservedFPRate ensures the canaries measure the false positive
+// rate of the ring as it fills.
+func TestBloom_ObservedFPRate(t *testing.T) {
+	r, err := Init(1000, 0.01, WithCanaries(20000))
+	require.NoError(t, err)
+	rate, err := r.ObservedFPRate()
+	require.NoError(t, err)
+	require.Equal(t, float64(0), rate)
+
+	fillRange(r, 0, 1000)
+	rate, err = r.ObservedFPRate()
+	require.NoError(t, err)
+	require.InDelta(t, r.CurrentFalsePositiveRate(), rate, 0.003)
+
+	fillRange(r, 1000, 3000)
+	rate, err = r.ObservedFPRate()
+	require.NoError(t, err)
+	require.InDelta(t, r.CurrentFalsePositiveRate(), rate, 0.02)
+	require.True(t, rate > 0.1)
+}
+
+// TestBloom_ObservedFPRateErrors ensures rings without canaries, bad counts,
+// failed randomness and destroyed rings are reported.
+func TestBloom_ObservedFPRateErrors(t *testing.T) {
+	r, _ := Init(100, fpRate)
+	_, err := r.ObservedFPRate()
+	require.Equal(t, errNoCanaries, err)
+
+	_, err = Init(100, fpRate, WithCanaries(0))
+	require.Equal(t, errCanaries, err)
+	require.Error(t, r.setCanaries(2, bytes.NewReader(make([]byte, canaryKeySize))))
+
+	c, _ := Init(100, fpRate, WithCanaries(10))
+	require.NoError(t, c.Transition(StateDestroyed))
+	_, err = c.ObservedFPRate()
+	require.IsType(t, &StateError{}, err)
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var errOverlap = errors.New("error: the positive and negative sets must not intersect")
+
+// cascadeFalsePositive is the false positive rate of every level of a cascade
+// after the first, which minimizes its total size.
+const cascadeFalsePositive = 0.5
+
+// Cascade is a filter cascade, a stack of rings that has no false positives
+// or negatives against a known universe of data. The first level holds the
+// positive set, the second the negatives that are false positives of the
+// first, the third the positives that are false positives of the second, and
+// so on until a level has no false positives. Data is positive if the first
+// level it is not in is even, counting from 1, or if it is in every level and
+// there is an odd number of them. Data outside the universe is reported
+// positive with about the false positive rate of the first level.
+type Cascade struct {
+	levels []*Bloom
+}
+
+// BuildCascade builds and returns the cascade of the positive set against the
+// negative set, or an error. The first level has the falsePositive rate, and
+// later ones a rate of 1/2, as in CRLite. It returns an error if the sets
+// intersect. Each level is hashed with a seed of its depth, so that its false
+// positives are independent of the level before.
+func BuildCascade(positives, negatives [][]byte, falsePositive float64) (*Cascade, error) {
+	if falsePositive <= 0 || falsePositive >= 1 {
+		return nil, errFalsePositive
+	}
+	members := make(map[string]struct{}, len(positives))
+	for _, data := range positives {
+		members[string(data)] = struct{}{}
+	}
+	for _, data := range negatives {
+		if _, ok := members[string(data)]; ok {
+			return nil, errOverlap
+		}
+	}
+
+	c := &Cascade{}
+	include, exclude := positives, negatives
+	for rate := falsePositive; len(include) > 0; rate = cascadeFalsePositive {
+		level, err := Init(len(include), rate, WithSeed(uint32(len(c.levels))))
+		if err != nil {
+			return nil, err
+		}
+		level.AddMany(include)
+		c.levels = append(c.levels, level)
+
+		var falsePositives [][]byte
+		for _, data := range exclude {
+			if level.Test(data) {
+				falsePositives = append(falsePositives, data)
+			}
+		}
+		include, exclude = falsePositives, include
+	}
+	return c, nil
+}
+
+// Test returns a bool if the data is in the positive set. For data in the
+// universe the cascade was built against it is exact, while other data may be
+// reported positive within the false positive rate of the first level.
+func (c *Cascade) Test(data []byte) bool {
+	for i, level := range c.levels {
+		if !level.Test(data) {
+			return i%2 == 1
+		}
+	}
+	return len(c.levels)%2 == 1
+}
+
+// Levels returns the number of levels in the cascade.
+func (c *Cascade) Levels() int {
+	return len(c.levels)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface. The output
+// is 1 byte of version and the number of levels as 4 bytes, then the output
+// of MarshalBinary for each level, preceded by its length as 4 bytes.
+// Integers are big endian.
+func (c *Cascade) MarshalBinary() ([]byte, error) {
+	out := make([]byte, 5)
+	out[0] = 1
+	binary.BigEndian.PutUint32(out[1:5], uint32(len(c.levels)))
+	for _, level := range c.levels {
+		data, err := level.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		var length [4]byte
+		binary.BigEndian.PutUint32(length[:], uint32(len(data)))
+		out = append(append(out, length[:]...), data...)
+	}
+	return out, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface. Keyed
+// levels, which BuildCascade never makes, are rejected, as no key is given.
+func (c *Cascade) UnmarshalBinary(data []byte) error {
+	if len(data) < 5 {
+		return fmt.Errorf("incorrect length: %d", len(data))
+	}
+	if data[0] != 1 {
+		return fmt.Errorf("unexpected version: %d", data[0])
+	}
+	count := binary.BigEndian.Uint32(data[1:5])
+	// every level takes at least its length and a header
+	if uint64(count) > uint64(len(data)-5)/(4+headerSize) {
+		return fmt.Errorf("incorrect length: %d", len(data))
+	}
+
+	levels := make([]*Bloom, count)
+	rest := data[5:]
+	for i := range levels {
+		if len(rest) < 4 {
+			return fmt.Errorf("incorrect length: %d", len(data))
+		}
+		length := binary.BigEndian.Uint32(rest)
+		rest = rest[4:]
+		if uint64(length) > uint64(len(rest)) {
+			return fmt.Errorf("incorrect length: %d", len(data))
+		}
+		levels[i] = new(Bloom)
+		if err := levels[i].UnmarshalBinary(rest[:length]); err != nil {
+			return err
+		}
+		rest = rest[length:]
+	}
+	if len(rest) != 0 {
+		return fmt.Errorf("incorrect length: %d", len(data))
+	}
+	c.levels = levels
+	return nil
+}
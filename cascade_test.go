@@ -0,0 +1,73 @@
+package ring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestCascade ensures the cascade is exact against its universe, both built
+// and after a round trip, and within the rate outside it.
+func TestCascade(t *testing.T) {
+	var positives, negatives [][]byte
+	for i := 0; i < 20000; i++ {
+		buff := make([]byte, 4)
+		intToByte(buff, i)
+		if i%20 == 0 {
+			positives = append(positives, buff)
+		} else {
+			negatives = append(negatives, buff)
+		}
+	}
+	c, err := BuildCascade(positives, negatives, 0.01)
+	require.NoError(t, err)
+	require.True(t, c.Levels() > 1)
+
+	out, err := c.MarshalBinary()
+	require.NoError(t, err)
+	u := new(Cascade)
+	require.NoError(t, u.UnmarshalBinary(out))
+	require.Equal(t, c.Levels(), u.Levels())
+	for _, cascade := range []*Cascade{c, u} {
+		for _, data := range positives {
+			require.True(t, cascade.Test(data))
+		}
+		for _, data := range negatives {
+			require.False(t, cascade.Test(data))
+		}
+	}
+
+	positive := 0
+	buff := make([]byte, 4)
+	for i := 20000; i < 120000; i++ {
+		intToByte(buff, i)
+		if c.Test(buff) {
+			positive++
+		}
+	}
+	require.True(t, positive < 1500, "positives %d", positive)
+
+	require.Error(t, u.UnmarshalBinary(out[:len(out)-1]))
+	require.Error(t, u.UnmarshalBinary(append(out, 0)))
+	bad := append([]byte{}, out...)
+	bad[0] = 2
+	require.Error(t, u.UnmarshalBinary(bad))
+}
+
+// TestCascade_Edges ensures empty sets and intersecting sets are handled.
+func TestCascade_Edges(t *testing.T) {
+	c, err := BuildCascade(nil, [][]byte{[]byte("negative")}, 0.01)
+	require.NoError(t, err)
+	require.Equal(t, 0, c.Levels())
+	require.False(t, c.Test([]byte("negative")))
+
+	c, err = BuildCascade([][]byte{[]byte("positive")}, nil, 0.01)
+	require.NoError(t, err)
+	require.Equal(t, 1, c.Levels())
+	require.True(t, c.Test([]byte("positive")))
+
+	_, err = BuildCascade([][]byte{[]byte("both")}, [][]byte{[]byte("both")}, 0.01)
+	require.Equal(t, errOverlap, err)
+	_, err = BuildCascade(nil, nil, 1)
+	require.Equal(t, errFalsePositive, err)
+}
@@ -0,0 +1,232 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	errCounterBits = errors.New("error: counterBits must be 4 or 8")
+	errNotPresent  = errors.New("error: the data is not in the filter")
+)
+
+// countingHeaderSize is the number of bytes preceding the counters in the
+// output of CountingBloom.MarshalBinary: 1 byte of version, 1 byte of counter
+// width, 8 bytes of size, and 8 bytes of hash.
+const countingHeaderSize = 18
+
+// CountingBloom is a bloom filter with a small counter in place of each bit,
+// so that data can be removed as well as added. Counters saturate at their
+// maximum, after which they are never decremented, so heavy overflow only
+// costs accuracy, never false negatives.
+type CountingBloom struct {
+	size        uint64  // number of counters
+	hash        uint64  // number of hash rounds
+	counterBits uint8   // width of each counter, 4 or 8
+	counters    []uint8 // counters, packed two per byte if 4 bits wide
+	mutex       *sync.RWMutex
+}
+
+// InitCounting initializes and returns a new counting filter, or an error. It
+// has the same number of counters and hash rounds as Init would give a ring
+// for the elements and falsePositive rate, with counters of counterBits each,
+// which must be 4 or 8.
+func InitCounting(elements int, falsePositive float64, counterBits int) (*CountingBloom, error) {
+	if elements <= 0 {
+		return nil, errElements
+	}
+	if falsePositive <= 0 || falsePositive >= 1 {
+		return nil, errFalsePositive
+	}
+	if counterBits != 4 && counterBits != 8 {
+		return nil, errCounterBits
+	}
+
+	c := &CountingBloom{counterBits: uint8(counterBits), mutex: &sync.RWMutex{}}
+	c.size, c.hash = optimalParameters(elements, falsePositive)
+	c.counters = make([]uint8, countersSize(c.size, c.counterBits))
+	return c, nil
+}
+
+// countersSize returns the number of bytes holding size counters.
+func countersSize(size uint64, counterBits uint8) uint64 {
+	if counterBits == 4 {
+		return size/2 + size%2
+	}
+	return size
+}
+
+// Add adds the data to the filter.
+func (c *CountingBloom) Add(data []byte) {
+	hash := generateMultiHash(data, 0)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i := uint64(0); i < c.hash; i++ {
+		index := getRound(hash, i) % c.size
+		if v := c.get(index); v < c.max() {
+			c.set(index, v+1)
+		}
+	}
+}
+
+// Test returns a bool if the data is in the filter. True indicates that the
+// data may be in the filter, while false indicates that it is not.
+func (c *CountingBloom) Test(data []byte) bool {
+	hash := generateMultiHash(data, 0)
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.testHash(hash)
+}
+
+// EstimateCount returns an estimate of the number of times the data has been
+// added, less the times it was removed, as the smallest of its counters, which
+// is the spectral bloom filter's minimum selection. The estimate is never
+// below the true count unless counters have saturated, in which case it is
+// the saturation value, 15 for 4-bit counters and 255 for 8-bit. It is 0 only
+// if the data is not in the filter.
+func (c *CountingBloom) EstimateCount(data []byte) uint64 {
+	hash := generateMultiHash(data, 0)
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	min := c.max()
+	for i := uint64(0); i < c.hash && min > 0; i++ {
+		if v := c.get(getRound(hash, i) % c.size); v < min {
+			min = v
+		}
+	}
+	return uint64(min)
+}
+
+// Remove removes the data from the filter. It returns an error, leaving the
+// filter unchanged, if the data is not in the filter. Removing data that was
+// never added, but is reported present as a false positive, corrupts the
+// filter and may cause false negatives for other data.
+func (c *CountingBloom) Remove(data []byte) error {
+	hash := generateMultiHash(data, 0)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.testHash(hash) {
+		return errNotPresent
+	}
+	for i := uint64(0); i < c.hash; i++ {
+		index := getRound(hash, i) % c.size
+		// saturated counters no longer know their count
+		if v := c.get(index); v < c.max() {
+			c.set(index, v-1)
+		}
+	}
+	return nil
+}
+
+// ToBloom returns a ring with a bit set for each counter of at least
+// threshold, which is treated as 1 if it is 0. With a threshold of 1, the
+// ring reports the same membership as the filter; higher thresholds keep only
+// the bits shared by several elements.
+func (c *CountingBloom) ToBloom(threshold uint8) *Bloom {
+	if threshold == 0 {
+		threshold = 1
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	r := &Bloom{mutex: &sync.RWMutex{}, size: c.size, hash: c.hash}
+	r.created = time.Now()
+	r.resetAt = r.created
+	r.bits = make([]uint8, getBuffSize(c.size))
+	for index := uint64(0); index < c.size; index++ {
+		if c.get(index) >= threshold {
+			r.orByte(index/8, 1<<(index%8))
+		}
+	}
+	r.unrecorded = 0
+	return r
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (c *CountingBloom) MarshalBinary() ([]byte, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	out := make([]byte, countingHeaderSize+len(c.counters))
+	out[0] = 1
+	out[1] = c.counterBits
+	binary.BigEndian.PutUint64(out[2:10], c.size)
+	binary.BigEndian.PutUint64(out[10:18], c.hash)
+	copy(out[countingHeaderSize:], c.counters)
+	return out, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+// Unlike Bloom.UnmarshalBinary, it requires the data to hold every counter.
+func (c *CountingBloom) UnmarshalBinary(data []byte) error {
+	if len(data) < countingHeaderSize {
+		return fmt.Errorf("incorrect length: %d", len(data))
+	}
+	if data[0] != 1 {
+		return fmt.Errorf("unexpected version: %d", data[0])
+	}
+	counterBits := data[1]
+	if counterBits != 4 && counterBits != 8 {
+		return errCounterBits
+	}
+	size := binary.BigEndian.Uint64(data[2:10])
+	hash := binary.BigEndian.Uint64(data[10:18])
+	if size == 0 || hash == 0 ||
+		uint64(len(data)-countingHeaderSize) < countersSize(size, counterBits) {
+		return fmt.Errorf("incorrect length: %d", len(data))
+	}
+	if hash > maxValidHash {
+		return fmt.Errorf("invalid hash rounds: %d", hash)
+	}
+
+	if c.mutex == nil {
+		c.mutex = new(sync.RWMutex)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.size = size
+	c.hash = hash
+	c.counterBits = counterBits
+	c.counters = make([]uint8, countersSize(size, counterBits))
+	copy(c.counters, data[countingHeaderSize:])
+	return nil
+}
+
+// testHash returns true if all counters for the pre-generated hashes are
+// non-zero. The caller must hold the read lock.
+func (c *CountingBloom) testHash(hash [4]uint64) bool {
+	for i := uint64(0); i < c.hash; i++ {
+		if c.get(getRound(hash, i)%c.size) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// max returns the saturation value of a counter.
+func (c *CountingBloom) max() uint8 {
+	return uint8(1<<c.counterBits - 1)
+}
+
+// get returns the counter at index.
+func (c *CountingBloom) get(index uint64) uint8 {
+	if c.counterBits == 8 {
+		return c.counters[index]
+	}
+	return c.counters[index/2] >> (4 * (index % 2)) & 0x0f
+}
+
+// set replaces the counter at index with v, which must fit in a counter.
+func (c *CountingBloom) set(index uint64, v uint8) {
+	if c.counterBits == 8 {
+		c.counters[index] = v
+		return
+	}
+	shift := 4 * (index % 2)
+	c.counters[index/2] = c.counters[index/2]&^(0x0f<<shift) | v<<shift
+}
@@ -0,0 +1,144 @@
+package ring
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestCountingBloom ensures added data is found until it is removed, for both
+// counter widths.
+func TestCountingBloom(t *testing.T) {
+	for _, width := range []int{4, 8} {
+		c, err := InitCounting(1000, 0.01, width)
+		require.NoError(t, err)
+		buff := make([]byte, 4)
+		for i := 0; i < 1000; i++ {
+			intToByte(buff, i)
+			c.Add(buff)
+		}
+		for i := 0; i < 500; i++ {
+			intToByte(buff, i)
+			require.NoError(t, c.Remove(buff), "width %d element %d", width, i)
+		}
+		for i := 500; i < 1000; i++ {
+			intToByte(buff, i)
+			require.True(t, c.Test(buff), "width %d element %d", width, i)
+		}
+		removed := 0
+		for i := 0; i < 500; i++ {
+			intToByte(buff, i)
+			if !c.Test(buff) {
+				removed++
+			}
+		}
+		// the removed elements are mostly gone, bar false positives
+		require.True(t, removed > 450, "width %d removed %d", width, removed)
+		require.Equal(t, errNotPresent, c.Remove([]byte("never added")))
+	}
+}
+
+// TestCountingBloom_Saturation ensures saturated counters are never
+// decremented, so removing data cannot cause false negatives.
+func TestCountingBloom_Saturation(t *testing.T) {
+	c, _ := InitCounting(10, 0.1, 4)
+	for i := 0; i < 20; i++ {
+		c.Add([]byte("hot"))
+	}
+	c.Add([]byte("cold"))
+	for i := 0; i < 20; i++ {
+		c.Remove([]byte("hot"))
+	}
+	require.True(t, c.Test([]byte("hot")))
+	require.True(t, c.Test([]byte("cold")))
+}
+
+// TestCountingBloom_ToBloom ensures the converted ring reports the same
+// membership and matches a ring built directly.
+func TestCountingBloom_ToBloom(t *testing.T) {
+	c, _ := InitCounting(1000, 0.01, 4)
+	r, _ := Init(1000, 0.01)
+	buff := make([]byte, 4)
+	for i := 0; i < 500; i++ {
+		intToByte(buff, i)
+		c.Add(buff)
+		r.Add(buff)
+	}
+	converted := c.ToBloom(0)
+	require.True(t, r.Equal(converted))
+	require.Equal(t, r.Digest(), converted.Digest())
+	require.True(t, countBits(c.ToBloom(2).bits) < countBits(converted.bits))
+}
+
+// TestCountingBloom_Marshal ensures the filter round trips and bad data is
+// rejected.
+func TestCountingBloom_Marshal(t *testing.T) {
+	c, _ := InitCounting(1000, 0.01, 4)
+	c.Add([]byte("data"))
+	out, err := c.MarshalBinary()
+	require.NoError(t, err)
+
+	u := new(CountingBloom)
+	require.NoError(t, u.UnmarshalBinary(out))
+	require.True(t, u.Test([]byte("data")))
+	require.NoError(t, u.Remove([]byte("data")))
+	require.False(t, u.Test([]byte("data")))
+
+	require.Error(t, u.UnmarshalBinary(out[:len(out)-1]))
+	bad := append([]byte{}, out...)
+	bad[1] = 5
+	require.Error(t, u.UnmarshalBinary(bad))
+	bad[0], bad[1] = 2, 4
+	require.Error(t, u.UnmarshalBinary(bad))
+	bad = append([]byte{}, out...)
+	binary.BigEndian.PutUint64(bad[10:18], 1<<60)
+	require.Error(t, u.UnmarshalBinary(bad))
+
+	// a size whose counter count would wrap to zero
+	huge := []byte{1, 4}
+	huge = append(huge, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff)
+	huge = append(huge, 0, 0, 0, 0, 0, 0, 0, 1)
+	require.Error(t, u.UnmarshalBinary(huge))
+
+	_, err = InitCounting(10, 0.01, 3)
+	require.Equal(t, errCounterBits, err)
+	_, err = InitCounting(0, 0.01, 4)
+	require.Error(t, err)
+	_, err = InitCounting(10, 0, 4)
+	require.Error(t, err)
+}
+
+// TestCountingBloom_EstimateCount ensures counts are estimated from below
+// only by saturation, and are 0 for absent data.
+func TestCountingBloom_EstimateCount(t *testing.T) {
+	c, _ := InitCounting(1000, 0.001, 8)
+	buff := make([]byte, 4)
+	for i := 0; i < 100; i++ {
+		intToByte(buff, i)
+		for n := 0; n <= i%10; n++ {
+			c.Add(buff)
+		}
+	}
+	exact := 0
+	for i := 0; i < 100; i++ {
+		intToByte(buff, i)
+		count := c.EstimateCount(buff)
+		require.True(t, count >= uint64(i%10+1), "element %d count %d", i, count)
+		if count == uint64(i%10+1) {
+			exact++
+		}
+	}
+	require.True(t, exact > 95, "exact %d", exact)
+	require.Equal(t, uint64(0), c.EstimateCount([]byte("absent")))
+
+	intToByte(buff, 9)
+	require.NoError(t, c.Remove(buff))
+	require.Equal(t, uint64(9), c.EstimateCount(buff))
+
+	small, _ := InitCounting(10, 0.1, 4)
+	for i := 0; i < 20; i++ {
+		small.Add([]byte("hot"))
+	}
+	require.Equal(t, uint64(15), small.EstimateCount([]byte("hot")))
+}
@@ -0,0 +1,189 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+)
+
+var (
+	errEpsilon     = errors.New("error: epsilon must be greater than 0 and less than 1")
+	errDelta       = errors.New("error: delta must be greater than 0 and less than 1")
+	errDimensions  = errors.New("error: width and depth must be greater than 0")
+	errSketchShape = errors.New("sketches must have the same width and depth")
+)
+
+// countMinHeaderSize is the number of bytes preceding the counters in the
+// output of CountMinSketch.MarshalBinary: 1 byte of version, and 8 bytes each
+// of width, depth and total.
+const countMinHeaderSize = 25
+
+// CountMinSketch estimates how many times each element of a stream has been
+// added, in a fixed amount of memory. It has depth rows of width counters,
+// and each element increments one counter in every row, chosen by the same
+// hash rounds as a ring. The estimate of an element is its smallest counter,
+// which is never below its true count, and above it by at most epsilon times
+// the total count with probability 1-delta.
+type CountMinSketch struct {
+	width    uint64   // counters per row
+	depth    uint64   // number of rows
+	total    uint64   // sum of the counts added
+	counters []uint64 // rows of counters, one after the other
+	mutex    *sync.RWMutex
+}
+
+// InitCountMin initializes and returns a new sketch, or an error. Estimates
+// exceed the true count by at most epsilon times the total count, with
+// probability 1-delta, which takes e/epsilon counters in each of ln(1/delta)
+// rows.
+func InitCountMin(epsilon, delta float64) (*CountMinSketch, error) {
+	if epsilon <= 0 || epsilon >= 1 {
+		return nil, errEpsilon
+	}
+	if delta <= 0 || delta >= 1 {
+		return nil, errDelta
+	}
+	width := uint64(math.Ceil(math.E / epsilon))
+	depth := uint64(math.Ceil(math.Log(1 / delta)))
+	return InitCountMinByParameters(width, depth)
+}
+
+// InitCountMinByParameters initializes and returns a new sketch with depth
+// rows of width counters, or an error if either is 0.
+func InitCountMinByParameters(width, depth uint64) (*CountMinSketch, error) {
+	if width == 0 || depth == 0 {
+		return nil, errDimensions
+	}
+	return &CountMinSketch{
+		width:    width,
+		depth:    depth,
+		counters: make([]uint64, width*depth),
+		mutex:    &sync.RWMutex{},
+	}, nil
+}
+
+// Add adds one occurrence of the data to the sketch.
+func (c *CountMinSketch) Add(data []byte) {
+	c.AddCount(data, 1)
+}
+
+// AddCount adds count occurrences of the data to the sketch. Counters
+// saturate rather than overflow.
+func (c *CountMinSketch) AddCount(data []byte, count uint64) {
+	hash := generateMultiHash(data, 0)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i := uint64(0); i < c.depth; i++ {
+		index := i*c.width + getRound(hash, i)%c.width
+		c.counters[index] = saturatingAdd(c.counters[index], count)
+	}
+	c.total = saturatingAdd(c.total, count)
+}
+
+// EstimateCount returns an estimate of the number of times the data has been
+// added, which is never below the true count.
+func (c *CountMinSketch) EstimateCount(data []byte) uint64 {
+	hash := generateMultiHash(data, 0)
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	min := uint64(math.MaxUint64)
+	for i := uint64(0); i < c.depth; i++ {
+		if v := c.counters[i*c.width+getRound(hash, i)%c.width]; v < min {
+			min = v
+		}
+	}
+	return min
+}
+
+// Total returns the sum of the counts added to the sketch.
+func (c *CountMinSketch) Total() uint64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.total
+}
+
+// Merge adds the counts of other to the sketch, so that it estimates the
+// counts of both streams. The sketches must have the same width and depth.
+// Other is read under its lock before the sketch is locked.
+func (c *CountMinSketch) Merge(other *CountMinSketch) error {
+	other.mutex.RLock()
+	width, depth, total := other.width, other.depth, other.total
+	counters := append([]uint64{}, other.counters...)
+	other.mutex.RUnlock()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.width != width || c.depth != depth {
+		return errSketchShape
+	}
+	for i, v := range counters {
+		c.counters[i] = saturatingAdd(c.counters[i], v)
+	}
+	c.total = saturatingAdd(c.total, total)
+	return nil
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface. The output
+// is 1 byte of version, the width, depth and total as 8 bytes each, then the
+// counters row by row as 8 bytes each. Integers are big endian.
+func (c *CountMinSketch) MarshalBinary() ([]byte, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	out := make([]byte, countMinHeaderSize+8*len(c.counters))
+	out[0] = 1
+	binary.BigEndian.PutUint64(out[1:9], c.width)
+	binary.BigEndian.PutUint64(out[9:17], c.depth)
+	binary.BigEndian.PutUint64(out[17:25], c.total)
+	for i, v := range c.counters {
+		binary.BigEndian.PutUint64(out[countMinHeaderSize+8*i:], v)
+	}
+	return out, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (c *CountMinSketch) UnmarshalBinary(data []byte) error {
+	if len(data) < countMinHeaderSize {
+		return fmt.Errorf("incorrect length: %d", len(data))
+	}
+	if data[0] != 1 {
+		return fmt.Errorf("unexpected version: %d", data[0])
+	}
+	width := binary.BigEndian.Uint64(data[1:9])
+	depth := binary.BigEndian.Uint64(data[9:17])
+	if width == 0 || depth == 0 {
+		return errDimensions
+	}
+	counters := uint64(len(data)-countMinHeaderSize) / 8
+	if counters/width != depth || counters%width != 0 ||
+		(len(data)-countMinHeaderSize)%8 != 0 {
+		return fmt.Errorf("incorrect length: %d", len(data))
+	}
+
+	if c.mutex == nil {
+		c.mutex = new(sync.RWMutex)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.width = width
+	c.depth = depth
+	c.total = binary.BigEndian.Uint64(data[17:25])
+	c.counters = make([]uint64, counters)
+	for i := range c.counters {
+		c.counters[i] = binary.BigEndian.Uint64(data[countMinHeaderSize+8*i:])
+	}
+	return nil
+}
+
+// saturatingAdd returns a+b, or the largest uint64 if the sum overflows.
+func saturatingAdd(a, b uint64) uint64 {
+	if sum := a + b; sum >= a {
+		return sum
+	}
+	return math.MaxUint64
+}
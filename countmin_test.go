@@ -0,0 +1,91 @@
+package ring
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestCountMinSketch ensures estimates are never below the true count, and
+// are within the error bound for nearly every element.
+func TestCountMinSketch(t *testing.T) {
+	c, err := InitCountMin(0.001, 0.01)
+	require.NoError(t, err)
+	buff := make([]byte, 4)
+	total := uint64(0)
+	for i := 0; i < 10000; i++ {
+		intToByte(buff, i)
+		c.AddCount(buff, uint64(i%7+1))
+		total += uint64(i%7 + 1)
+	}
+	require.Equal(t, total, c.Total())
+
+	bound := uint64(0.001 * float64(c.Total()))
+	over := 0
+	for i := 0; i < 10000; i++ {
+		intToByte(buff, i)
+		estimate := c.EstimateCount(buff)
+		require.True(t, estimate >= uint64(i%7+1), "element %d", i)
+		if estimate > uint64(i%7+1)+bound {
+			over++
+		}
+	}
+	require.True(t, over < 100, "over %d", over)
+	require.True(t, c.EstimateCount([]byte("absent")) <= bound)
+
+	_, err = InitCountMin(0, 0.01)
+	require.Equal(t, errEpsilon, err)
+	_, err = InitCountMin(0.01, 1)
+	require.Equal(t, errDelta, err)
+	_, err = InitCountMinByParameters(0, 4)
+	require.Equal(t, errDimensions, err)
+}
+
+// TestCountMinSketch_Merge ensures merged sketches estimate the sum of both
+// streams, and sketches of different shapes are rejected.
+func TestCountMinSketch_Merge(t *testing.T) {
+	a, _ := InitCountMinByParameters(1000, 4)
+	b, _ := InitCountMinByParameters(1000, 4)
+	a.AddCount([]byte("data"), 3)
+	b.Add([]byte("data"))
+	b.Add([]byte("other"))
+	require.NoError(t, a.Merge(b))
+	require.Equal(t, uint64(4), a.EstimateCount([]byte("data")))
+	require.Equal(t, uint64(1), a.EstimateCount([]byte("other")))
+	require.Equal(t, uint64(5), a.Total())
+
+	require.NoError(t, a.Merge(a))
+	require.Equal(t, uint64(8), a.EstimateCount([]byte("data")))
+
+	c, _ := InitCountMinByParameters(1000, 5)
+	require.Equal(t, errSketchShape, a.Merge(c))
+
+	a.AddCount([]byte("data"), math.MaxUint64)
+	require.Equal(t, uint64(math.MaxUint64), a.EstimateCount([]byte("data")))
+	require.Equal(t, uint64(math.MaxUint64), a.Total())
+}
+
+// TestCountMinSketch_Marshal ensures the sketch round trips and bad data is
+// rejected.
+func TestCountMinSketch_Marshal(t *testing.T) {
+	c, _ := InitCountMinByParameters(100, 3)
+	c.AddCount([]byte("data"), 5)
+	out, err := c.MarshalBinary()
+	require.NoError(t, err)
+
+	u := new(CountMinSketch)
+	require.NoError(t, u.UnmarshalBinary(out))
+	require.Equal(t, uint64(5), u.EstimateCount([]byte("data")))
+	require.Equal(t, uint64(5), u.Total())
+	require.NoError(t, u.Merge(c))
+
+	require.Error(t, u.UnmarshalBinary(out[:len(out)-1]))
+	require.Error(t, u.UnmarshalBinary(out[:len(out)-8]))
+	require.Error(t, u.UnmarshalBinary(out[:countMinHeaderSize-1]))
+	bad := append([]byte{}, out...)
+	bad[0] = 2
+	require.Error(t, u.UnmarshalBinary(bad))
+	bad[0], bad[8] = 1, 0
+	require.Error(t, u.UnmarshalBinary(bad))
+}
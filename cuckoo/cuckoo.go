@@ -0,0 +1,330 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package cuckoo provides a thread safe cuckoo filter, which unlike a bloom
+// filter supports deleting data, and uses less space at low false positive
+// rates.
+package cuckoo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	ring "gitlab.com/elixxir/bloomfilter"
+)
+
+var (
+	errCapacity      = errors.New("error: capacity must be greater than 0")
+	errFalsePositive = errors.New("error: falsePositive must be at least 0.000123 and less than 1")
+	errFull          = errors.New("error: the filter is full")
+)
+
+const (
+	// bucketSize is the number of fingerprints in a bucket.
+	bucketSize = 4
+	// loadFactor is the fraction of slots that can be filled before inserts
+	// start to fail.
+	loadFactor = 0.95
+	// maxKicks is the number of fingerprints relocated by an insert before
+	// the filter is considered full.
+	maxKicks = 500
+	// headerSize is the number of bytes preceding the slots in the output of
+	// MarshalBinary: 1 byte of version, 8 bytes of bucket count, 8 bytes of
+	// count, 1 byte of fingerprint width, and 11 bytes of victim.
+	headerSize = 29
+)
+
+// CuckooFilter is a cuckoo filter. Each element is stored as a fingerprint in
+// one of two buckets, the second found from the first and the fingerprint
+// alone, so that fingerprints can be moved between their buckets to make
+// room without knowing the element.
+type CuckooFilter struct {
+	buckets uint64  // number of buckets, a power of two
+	fpBytes int     // bytes per fingerprint, 1 or 2
+	slots   []uint8 // fingerprints, 0 for an empty slot
+	count   uint64  // number of fingerprints, including the victim
+	victim  victim  // fingerprint evicted by a failed insert
+	kick    uint64  // state for choosing the fingerprint to relocate
+	mutex   *sync.RWMutex
+}
+
+// victim is the fingerprint left without a slot when an insert fails. It is
+// kept so that no element is lost, and the filter is full while it is held.
+type victim struct {
+	used  bool
+	index uint64
+	fp    uint16
+}
+
+// Init initializes and returns a new cuckoo filter, or an error. Given a
+// capacity, it accurately states if data is not added. Within a falsePositive
+// rate, it will indicate if the data has been added. Fingerprints are 8 bits
+// if that meets the rate, otherwise 16 bits, so the rate must be at least
+// 2*bucketSize/65535.
+func Init(capacity int, falsePositive float64) (*CuckooFilter, error) {
+	if capacity <= 0 {
+		return nil, errCapacity
+	}
+	// a lookup compares 2*bucketSize fingerprints, each colliding with
+	// probability 1/(2^f - 1)
+	fpBits := math.Ceil(math.Log2(2*bucketSize/falsePositive + 1))
+	if falsePositive <= 0 || falsePositive >= 1 || fpBits > 16 {
+		return nil, errFalsePositive
+	}
+	fpBytes := 1
+	if fpBits > 8 {
+		fpBytes = 2
+	}
+
+	buckets := uint64(1)
+	for float64(buckets*bucketSize)*loadFactor < float64(capacity) {
+		buckets <<= 1
+	}
+	return &CuckooFilter{
+		buckets: buckets,
+		fpBytes: fpBytes,
+		slots:   make([]uint8, buckets*bucketSize*uint64(fpBytes)),
+		mutex:   &sync.RWMutex{},
+	}, nil
+}
+
+// Add adds the data to the filter. It returns an error if the filter is full,
+// in which case the data may or may not have been added. Data added more than
+// once is stored more than once, and can be deleted as many times.
+func (f *CuckooFilter) Add(data []byte) error {
+	i1, fp := f.locate(data)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.victim.used {
+		return errFull
+	}
+	i2 := f.altIndex(i1, fp)
+	if f.insert(i1, fp) || f.insert(i2, fp) {
+		f.count++
+		return nil
+	}
+
+	// relocate fingerprints until one finds a free slot
+	index := i1
+	if f.nextKick()&1 == 1 {
+		index = i2
+	}
+	for n := 0; n < maxKicks; n++ {
+		slot := int(f.nextKick() % bucketSize)
+		evicted := f.get(index, slot)
+		f.set(index, slot, fp)
+		fp = evicted
+		index = f.altIndex(index, fp)
+		if f.insert(index, fp) {
+			f.count++
+			return nil
+		}
+	}
+	f.victim = victim{used: true, index: index, fp: fp}
+	f.count++
+	return errFull
+}
+
+// Test returns a bool if the data is in the filter. True indicates that the
+// data may be in the filter, while false indicates that it is not.
+func (f *CuckooFilter) Test(data []byte) bool {
+	i1, fp := f.locate(data)
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	i2 := f.altIndex(i1, fp)
+	if f.victim.used && f.victim.fp == fp &&
+		(f.victim.index == i1 || f.victim.index == i2) {
+		return true
+	}
+	return f.find(i1, fp) >= 0 || f.find(i2, fp) >= 0
+}
+
+// Delete removes the data from the filter and returns true, or returns false
+// if the data is not in the filter. Deleting data that was never added, but is
+// reported present as a false positive, removes another element.
+func (f *CuckooFilter) Delete(data []byte) bool {
+	i1, fp := f.locate(data)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	i2 := f.altIndex(i1, fp)
+	if f.victim.used && f.victim.fp == fp &&
+		(f.victim.index == i1 || f.victim.index == i2) {
+		f.victim = victim{}
+		f.count--
+		return true
+	}
+	for _, index := range [2]uint64{i1, i2} {
+		if slot := f.find(index, fp); slot >= 0 {
+			f.set(index, slot, 0)
+			f.count--
+			f.reinsertVictim()
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns the number of elements in the filter.
+func (f *CuckooFilter) Count() uint64 {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.count
+}
+
+// Capacity returns the number of slots in the filter, which bounds the number
+// of elements it can hold.
+func (f *CuckooFilter) Capacity() uint64 {
+	return f.buckets * bucketSize
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface. The output
+// is 1 byte of version, the number of buckets and count as 8 bytes each, 1
+// byte of fingerprint width, 1 byte flagging a victim, its index as 8 bytes
+// and fingerprint as 2 bytes, then the slots. Integers are big endian, and
+// 2 byte fingerprints are stored little endian in their slots.
+func (f *CuckooFilter) MarshalBinary() ([]byte, error) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	out := make([]byte, headerSize+len(f.slots))
+	out[0] = 1
+	binary.BigEndian.PutUint64(out[1:9], f.buckets)
+	binary.BigEndian.PutUint64(out[9:17], f.count)
+	out[17] = uint8(f.fpBytes * 8)
+	if f.victim.used {
+		out[18] = 1
+		binary.BigEndian.PutUint64(out[19:27], f.victim.index)
+		binary.BigEndian.PutUint16(out[27:29], f.victim.fp)
+	}
+	copy(out[headerSize:], f.slots)
+	return out, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (f *CuckooFilter) UnmarshalBinary(data []byte) error {
+	if len(data) < headerSize {
+		return fmt.Errorf("incorrect length: %d", len(data))
+	}
+	if data[0] != 1 {
+		return fmt.Errorf("unexpected version: %d", data[0])
+	}
+	buckets := binary.BigEndian.Uint64(data[1:9])
+	if buckets == 0 || buckets&(buckets-1) != 0 {
+		return fmt.Errorf("invalid bucket count: %d", buckets)
+	}
+	if data[17] != 8 && data[17] != 16 {
+		return fmt.Errorf("invalid fingerprint width: %d", data[17])
+	}
+	fpBytes := int(data[17] / 8)
+	if uint64(len(data)-headerSize)/bucketSize/uint64(fpBytes) != buckets ||
+		uint64(len(data)-headerSize)%(bucketSize*uint64(fpBytes)) != 0 {
+		return fmt.Errorf("incorrect length: %d", len(data))
+	}
+	if data[18] > 1 {
+		return fmt.Errorf("invalid victim flag: %d", data[18])
+	}
+
+	if f.mutex == nil {
+		f.mutex = new(sync.RWMutex)
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.buckets = buckets
+	f.fpBytes = fpBytes
+	f.count = binary.BigEndian.Uint64(data[9:17])
+	f.victim = victim{}
+	if data[18] == 1 {
+		f.victim = victim{
+			used:  true,
+			index: binary.BigEndian.Uint64(data[19:27]) & (buckets - 1),
+			fp:    binary.BigEndian.Uint16(data[27:29]),
+		}
+	}
+	f.slots = append([]uint8{}, data[headerSize:]...)
+	return nil
+}
+
+// locate returns the first bucket and the fingerprint of the data. The
+// fingerprint is never 0, which marks an empty slot.
+func (f *CuckooFilter) locate(data []byte) (uint64, uint16) {
+	h1, h2 := ring.Sum128(data, 0)
+	max := uint64(1)<<(8*uint(f.fpBytes)) - 1
+	return h1 & (f.buckets - 1), uint16(h2%max + 1)
+}
+
+// altIndex returns the other bucket of a fingerprint in the bucket at index.
+// Applying it twice gives back index.
+func (f *CuckooFilter) altIndex(index uint64, fp uint16) uint64 {
+	return (index ^ uint64(fp)*0x5bd1e995) & (f.buckets - 1)
+}
+
+// insert stores the fingerprint in a free slot of the bucket at index and
+// returns true, or returns false if the bucket is full.
+func (f *CuckooFilter) insert(index uint64, fp uint16) bool {
+	return f.replace(index, 0, fp)
+}
+
+// find returns the slot of the bucket at index holding the fingerprint, or -1.
+func (f *CuckooFilter) find(index uint64, fp uint16) int {
+	for slot := 0; slot < bucketSize; slot++ {
+		if f.get(index, slot) == fp {
+			return slot
+		}
+	}
+	return -1
+}
+
+// replace stores new in the first slot of the bucket at index holding old and
+// returns true, or returns false if no slot holds old.
+func (f *CuckooFilter) replace(index uint64, old, new uint16) bool {
+	if slot := f.find(index, old); slot >= 0 {
+		f.set(index, slot, new)
+		return true
+	}
+	return false
+}
+
+// reinsertVictim moves the victim into a slot, if one is now free.
+func (f *CuckooFilter) reinsertVictim() {
+	if !f.victim.used {
+		return
+	}
+	v := f.victim
+	if f.insert(v.index, v.fp) || f.insert(f.altIndex(v.index, v.fp), v.fp) {
+		f.victim = victim{}
+	}
+}
+
+// get returns the fingerprint in a slot of the bucket at index.
+func (f *CuckooFilter) get(index uint64, slot int) uint16 {
+	i := (index*bucketSize + uint64(slot)) * uint64(f.fpBytes)
+	if f.fpBytes == 1 {
+		return uint16(f.slots[i])
+	}
+	return binary.LittleEndian.Uint16(f.slots[i:])
+}
+
+// set stores the fingerprint in a slot of the bucket at index.
+func (f *CuckooFilter) set(index uint64, slot int, fp uint16) {
+	i := (index*bucketSize + uint64(slot)) * uint64(f.fpBytes)
+	if f.fpBytes == 1 {
+		f.slots[i] = uint8(fp)
+		return
+	}
+	binary.LittleEndian.PutUint16(f.slots[i:], fp)
+}
+
+// nextKick returns the next value of a xorshift generator used to choose
+// which fingerprint to relocate, so that inserts are deterministic.
+func (f *CuckooFilter) nextKick() uint64 {
+	if f.kick == 0 {
+		f.kick = 0x9e3779b97f4a7c15
+	}
+	f.kick ^= f.kick << 13
+	f.kick ^= f.kick >> 7
+	f.kick ^= f.kick << 17
+	return f.kick
+}
@@ -0,0 +1,110 @@
+package cuckoo
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func key(i int) []byte {
+	buff := make([]byte, 4)
+	binary.BigEndian.PutUint32(buff, uint32(i))
+	return buff
+}
+
+// TestCuckooFilter ensures added data is found until it is deleted, and that
+// the false positive rate is within the target, for both fingerprint widths.
+func TestCuckooFilter(t *testing.T) {
+	for _, fp := range []float64{0.01, 0.001} {
+		f, err := Init(10000, fp)
+		require.NoError(t, err)
+		for i := 0; i < 10000; i++ {
+			require.NoError(t, f.Add(key(i)), "fp %v element %d", fp, i)
+		}
+		require.Equal(t, uint64(10000), f.Count())
+		for i := 0; i < 10000; i++ {
+			require.True(t, f.Test(key(i)), "fp %v element %d", fp, i)
+		}
+
+		positives := 0
+		for i := 10000; i < 110000; i++ {
+			if f.Test(key(i)) {
+				positives++
+			}
+		}
+		require.True(t, float64(positives)/100000 < fp,
+			"fp %v false positives %d", fp, positives)
+
+		for i := 0; i < 5000; i++ {
+			require.True(t, f.Delete(key(i)), "fp %v element %d", fp, i)
+		}
+		require.Equal(t, uint64(5000), f.Count())
+		for i := 5000; i < 10000; i++ {
+			require.True(t, f.Test(key(i)), "fp %v element %d", fp, i)
+		}
+	}
+}
+
+// TestCuckooFilter_Full ensures a full filter reports an error without losing
+// any element, and accepts inserts again once an element is deleted.
+func TestCuckooFilter_Full(t *testing.T) {
+	f, _ := Init(100, 0.001)
+	var err error
+	n := 0
+	for ; err == nil; n++ {
+		err = f.Add(key(n))
+	}
+	require.Equal(t, errFull, err)
+	require.True(t, uint64(n) > f.Capacity()/2)
+	require.Equal(t, errFull, f.Add(key(n)))
+	for i := 0; i < n; i++ {
+		require.True(t, f.Test(key(i)), "element %d", i)
+	}
+
+	// the victim moves into a slot once one of its buckets has room
+	deleted := 0
+	for ; f.victim.used; deleted++ {
+		require.True(t, f.Delete(key(deleted)))
+	}
+	require.NoError(t, f.Add(key(0)))
+	require.Equal(t, uint64(n-deleted+1), f.Count())
+	for i := deleted; i < n; i++ {
+		require.True(t, f.Test(key(i)), "element %d", i)
+	}
+}
+
+// TestCuckooFilter_Marshal ensures the filter round trips, including a victim,
+// and bad data is rejected.
+func TestCuckooFilter_Marshal(t *testing.T) {
+	f, _ := Init(100, 0.01)
+	n := 0
+	for f.Add(key(n)) == nil {
+		n++
+	}
+	out, err := f.MarshalBinary()
+	require.NoError(t, err)
+
+	u := new(CuckooFilter)
+	require.NoError(t, u.UnmarshalBinary(out))
+	require.Equal(t, f.Count(), u.Count())
+	require.Equal(t, f.victim, u.victim)
+	for i := 0; i <= n; i++ {
+		require.True(t, u.Test(key(i)), "element %d", i)
+	}
+
+	require.Error(t, u.UnmarshalBinary(out[:len(out)-1]))
+	require.Error(t, u.UnmarshalBinary(out[:headerSize-1]))
+	bad := append([]byte{}, out...)
+	bad[17] = 12
+	require.Error(t, u.UnmarshalBinary(bad))
+	bad[17], bad[0] = 8, 2
+	require.Error(t, u.UnmarshalBinary(bad))
+
+	_, err = Init(0, 0.01)
+	require.Equal(t, errCapacity, err)
+	_, err = Init(10, 0.0001)
+	require.Equal(t, errFalsePositive, err)
+	_, err = Init(10, 1)
+	require.Equal(t, errFalsePositive, err)
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+var errHalfLife = errors.New("error: halfLife must be greater than 0")
+
+const (
+	// decayThreshold is the weight at which Test stops reporting data, so
+	// that data added once is remembered for one half-life.
+	decayThreshold = 0.5
+	// maxDecayHalfLives is the number of half-lives after which the weights
+	// are rescaled, well within the range of a float32.
+	maxDecayHalfLives = 32
+)
+
+// DecayingBloom is a bloom filter with a weight in place of each bit, which
+// halves every half-life. Adding data adds 1 to each of its weights, and Test
+// reports data whose smallest weight is at least 1/2, so data is remembered
+// for a half-life after it is added, and data added n times for log2(2n)
+// half-lives, while data added recently is unaffected by older data fading.
+//
+// Weights are not decayed one by one. Each is stored scaled up by the decay
+// since an epoch, and scaled down by it when read, so that only the epoch
+// moves as time passes.
+type DecayingBloom struct {
+	size     uint64 // number of weights
+	hash     uint64 // number of hash rounds
+	halfLife time.Duration
+	weights  []float32 // weights, scaled by the decay since the epoch
+	epoch    time.Time // time the scale of the weights is relative to
+	mutex    *sync.RWMutex
+}
+
+// InitDecaying initializes and returns a new decaying filter, or an error. It
+// has the same number of weights and hash rounds as Init would give a ring
+// for the elements and falsePositive rate, where elements counts the data
+// added in about a half-life.
+func InitDecaying(elements int, falsePositive float64, halfLife time.Duration) (*DecayingBloom, error) {
+	if elements <= 0 {
+		return nil, errElements
+	}
+	if falsePositive <= 0 || falsePositive >= 1 {
+		return nil, errFalsePositive
+	}
+	if halfLife <= 0 {
+		return nil, errHalfLife
+	}
+
+	d := &DecayingBloom{halfLife: halfLife, epoch: time.Now(), mutex: &sync.RWMutex{}}
+	d.size, d.hash = optimalParameters(elements, falsePositive)
+	d.weights = make([]float32, d.size)
+	return d, nil
+}
+
+// Add adds the data to the filter with a weight of 1.
+func (d *DecayingBloom) Add(data []byte) {
+	hash := generateMultiHash(data, 0)
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	now := time.Now()
+	if d.halfLives(now) > maxDecayHalfLives {
+		d.rescale(now)
+	}
+	weight := float32(math.Exp2(d.halfLives(now)))
+	for i := uint64(0); i < d.hash; i++ {
+		d.weights[getRound(hash, i)%d.size] += weight
+	}
+}
+
+// Test returns a bool if the data is in the filter. True indicates that the
+// data may have been added within about a half-life, or earlier if it was
+// added repeatedly, while false indicates that it was not.
+func (d *DecayingBloom) Test(data []byte) bool {
+	return d.Weight(data) >= decayThreshold
+}
+
+// Weight returns the weight of the data: the number of times it has been
+// added, each halved for every half-life since, or more if other data shares
+// its weights. It is 0 if the data has not been added, or only long enough
+// ago for its weight to decay below the precision of a float32.
+func (d *DecayingBloom) Weight(data []byte) float64 {
+	hash := generateMultiHash(data, 0)
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	min := float32(math.Inf(1))
+	for i := uint64(0); i < d.hash; i++ {
+		if w := d.weights[getRound(hash, i)%d.size]; w < min {
+			min = w
+		}
+	}
+	return float64(min) * math.Exp2(-d.halfLives(time.Now()))
+}
+
+// Reset clears the filter.
+func (d *DecayingBloom) Reset() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.weights = make([]float32, d.size)
+	d.epoch = time.Now()
+}
+
+// halfLives returns the number of half-lives from the epoch to now. The
+// caller must hold the lock.
+func (d *DecayingBloom) halfLives(now time.Time) float64 {
+	return float64(now.Sub(d.epoch)) / float64(d.halfLife)
+}
+
+// rescale moves the epoch forward to a whole number of half-lives before now,
+// scaling the weights down to match. Weights that decay below the smallest
+// float32 become 0. The caller must hold the write lock.
+func (d *DecayingBloom) rescale(now time.Time) {
+	n := math.Floor(d.halfLives(now))
+	scale := float32(math.Exp2(-n))
+	for i := range d.weights {
+		d.weights[i] *= scale
+	}
+	d.epoch = d.epoch.Add(time.Duration(n * float64(d.halfLife)))
+}
@@ -0,0 +1,64 @@
+package ring
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestDecayingBloom ensures data fades after a half-life for each doubling of
+// the times it was added, while recently added data is kept.
+func TestDecayingBloom(t *testing.T) {
+	d, err := InitDecaying(1000, 0.001, time.Hour)
+	require.NoError(t, err)
+	d.Add([]byte("once"))
+	for i := 0; i < 4; i++ {
+		d.Add([]byte("four times"))
+	}
+	require.InDelta(t, 1, d.Weight([]byte("once")), 0.01)
+	require.InDelta(t, 4, d.Weight([]byte("four times")), 0.01)
+	require.Equal(t, float64(0), d.Weight([]byte("absent")))
+
+	d.epoch = d.epoch.Add(-90 * time.Minute)
+	d.Add([]byte("recent"))
+	require.False(t, d.Test([]byte("once")))
+	require.True(t, d.Test([]byte("four times")))
+	require.True(t, d.Test([]byte("recent")))
+	require.InDelta(t, 4/math.Pow(2, 1.5), d.Weight([]byte("four times")), 0.01)
+
+	d.epoch = d.epoch.Add(-2 * time.Hour)
+	d.Add([]byte("fresh"))
+	require.False(t, d.Test([]byte("four times")))
+	require.False(t, d.Test([]byte("recent")))
+	require.True(t, d.Test([]byte("fresh")))
+
+	d.Reset()
+	require.False(t, d.Test([]byte("fresh")))
+
+	_, err = InitDecaying(0, 0.01, time.Hour)
+	require.Equal(t, errElements, err)
+	_, err = InitDecaying(10, 1, time.Hour)
+	require.Equal(t, errFalsePositive, err)
+	_, err = InitDecaying(10, 0.01, 0)
+	require.Equal(t, errHalfLife, err)
+}
+
+// TestDecayingBloom_Rescale ensures weights keep their value when the epoch
+// is moved forward, and that long idle periods decay everything.
+func TestDecayingBloom_Rescale(t *testing.T) {
+	d, _ := InitDecaying(100, 0.01, time.Minute)
+	d.Add([]byte("data"))
+	d.epoch = d.epoch.Add(-(maxDecayHalfLives + 2) * time.Minute)
+	before := d.Weight([]byte("data"))
+	d.Add([]byte("other"))
+	require.True(t, time.Since(d.epoch) < time.Minute)
+	require.InDelta(t, before, d.Weight([]byte("data")), before/1000)
+	require.InDelta(t, 1, d.Weight([]byte("other")), 0.01)
+
+	d.epoch = d.epoch.Add(-1000 * time.Minute)
+	d.Add([]byte("new"))
+	require.Equal(t, float64(0), d.Weight([]byte("other")))
+	require.True(t, d.Test([]byte("new")))
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import "math/bits"
+
+// The digest of a ring is the XOR of a mixed value for every non-zero byte of
+// its bit array. As each byte contributes independently, a mutation updates
+// the digest by removing the old contribution of the changed byte and adding
+// the new one, so the digest never needs a full pass over the bits.
+
+// Digest returns a 64-bit digest of the bit array in O(1). Rings with equal bit
+// arrays have equal digests, making it a cheap check of whether two replicas
+// are in sync. It is not a cryptographic hash and must not be relied on to
+// detect deliberate tampering.
+func (r *Bloom) Digest() uint64 {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.digest
+}
+
+// orByte sets the bits of v in the byte at index i, updating the digest and
+// write heat if the byte changes. The caller must hold the write lock.
+func (r *Bloom) orByte(i uint64, v uint8) {
+	old := r.bits[i]
+	if updated := old | v; updated != old {
+		r.bits[i] = updated
+		r.digest ^= byteDigest(i, old) ^ byteDigest(i, updated)
+		r.unrecorded += uint64(bits.OnesCount8(updated ^ old))
+		r.set += uint64(bits.OnesCount8(updated ^ old))
+	}
+}
+
+// andByte clears the bits not in v in the byte at index i, updating the digest
+// and write heat if the byte changes. The caller must hold the write lock.
+func (r *Bloom) andByte(i uint64, v uint8) {
+	old := r.bits[i]
+	if updated := old & v; updated != old {
+		r.bits[i] = updated
+		r.digest ^= byteDigest(i, old) ^ byteDigest(i, updated)
+		r.unrecorded += uint64(bits.OnesCount8(updated ^ old))
+		r.set -= uint64(bits.OnesCount8(updated ^ old))
+	}
+}
+
+// byteDigest returns the contribution of the byte at index i with value v to
+// the digest. Zero bytes do not contribute, so an empty ring has digest 0.
+func byteDigest(i uint64, v uint8) uint64 {
+	if v == 0 {
+		return 0
+	}
+	return fmix(i<<8 | uint64(v))
+}
+
+// computeDigest returns the digest of the bit array with a full pass.
+func computeDigest(bits []uint8) uint64 {
+	var digest uint64
+	for i, v := range bits {
+		digest ^= byteDigest(uint64(i), v)
+	}
+	return digest
+}
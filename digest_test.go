@@ -0,0 +1,76 @@
+package ring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestBloom_Digest ensures the rolling digest always matches a full pass over
+// the bits after every kind of mutation.
+func TestBloom_Digest(t *testing.T) {
+	r, err := Init(1000, 0.01)
+	require.NoError(t, err)
+	require.Zero(t, r.Digest())
+
+	buff := make([]byte, 4)
+	for i := 0; i < 500; i++ {
+		intToByte(buff, i)
+		r.Add(buff)
+		require.Equal(t, computeDigest(r.bits), r.Digest())
+	}
+	require.NotZero(t, r.Digest())
+
+	// adding data again does not change the digest
+	before := r.Digest()
+	intToByte(buff, 0)
+	r.Add(buff)
+	require.Equal(t, before, r.Digest())
+
+	m, _ := Init(1000, 0.01)
+	for i := 500; i < 1000; i++ {
+		intToByte(buff, i)
+		m.Add(buff)
+	}
+	require.NoError(t, r.Merge(m))
+	require.Equal(t, computeDigest(r.bits), r.Digest())
+
+	out, _ := r.MarshalBinary()
+	r2 := new(Bloom)
+	require.NoError(t, r2.UnmarshalBinary(out))
+	require.Equal(t, r.Digest(), r2.Digest())
+
+	stored, _ := r.MarshalStorage()
+	r3, _ := Init(1000, 0.01)
+	require.NoError(t, r3.UnmarshalStorage(stored))
+	require.Equal(t, r.Digest(), r3.Digest())
+
+	r.Reset()
+	require.Zero(t, r.Digest())
+}
+
+// TestBloom_DigestOrder ensures the digest does not depend on the order data
+// is added in.
+func TestBloom_DigestOrder(t *testing.T) {
+	r1, _ := Init(1000, 0.01)
+	r2, _ := Init(1000, 0.01)
+	buff := make([]byte, 4)
+	for i := 0; i < 100; i++ {
+		intToByte(buff, i)
+		r1.Add(buff)
+		intToByte(buff, 99-i)
+		r2.Add(buff)
+	}
+	require.Equal(t, r1.Digest(), r2.Digest())
+
+	intToByte(buff, 100)
+	r2.Add(buff)
+	require.NotEqual(t, r1.Digest(), r2.Digest())
+}
+
+func BenchmarkBloom_Digest(b *testing.B) {
+	r, _ := Init(tests, fpRate)
+	for i := 0; i < b.N; i++ {
+		r.Digest()
+	}
+}
@@ -0,0 +1,314 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package dleft provides a thread safe d-left counting filter, which supports
+// deleting data like a counting bloom filter, in about half the space, and
+// counts each element exactly unless its fingerprint is shared.
+package dleft
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	ring "gitlab.com/elixxir/bloomfilter"
+)
+
+var (
+	errCapacity      = errors.New("error: capacity must be greater than 0")
+	errFalsePositive = errors.New("error: falsePositive must be at least 2^-27 and less than 1")
+	errFull          = errors.New("error: the filter is full")
+)
+
+const (
+	// tables is the number of subtables, each holding one candidate bucket of
+	// an element.
+	tables = 4
+	// bucketSize is the number of cells in a bucket.
+	bucketSize = 8
+	// maxLoad is the fraction of cells Init sizes the filter to fill. Loads
+	// are so even across the least loaded of four buckets that few inserts
+	// fail below it.
+	maxLoad = 0.75
+	// counterBits is the width of the counter of a cell. A counter of 0
+	// marks an empty cell, and a saturated counter is never decremented.
+	counterBits = 4
+	// maxRemainder is the widest remainder supported.
+	maxRemainder = 32
+	// headerSize is the number of bytes preceding the table in the output of
+	// MarshalBinary: 1 byte of version, 1 byte each of bucket and remainder
+	// bits, and 8 bytes of count.
+	headerSize = 11
+)
+
+// multipliers are the odd constants of the permutations of the subtables.
+var multipliers = [tables]uint64{
+	0x9e3779b97f4a7c15, 0xbf58476d1ce4e5b9, 0x94d049bb133111eb, 0xff51afd7ed558ccd,
+}
+
+// DLeftFilter is a d-left counting filter. Each element has a fingerprint of
+// b+r bits, which a different permutation in each of four subtables splits
+// into a bucket of b bits and a remainder of r bits. The remainder is stored,
+// with a counter, in the least loaded of the four buckets, or its counter is
+// incremented if it is already in one of them. Because the permutations are
+// invertible, a remainder found in a bucket can only belong to one
+// fingerprint, so deleting an element never removes another.
+type DLeftFilter struct {
+	b, r  uint     // bits of bucket and remainder
+	count uint64   // number of elements added less those deleted
+	table []uint64 // 4 subtables of 2^b buckets of 8 cells of r+4 bits, packed
+	mutex *sync.RWMutex
+}
+
+// Init initializes and returns a new d-left counting filter, or an error.
+// Given a capacity, it accurately states if data is not added. Within a
+// falsePositive rate, it will indicate if the data has been added.
+func Init(capacity int, falsePositive float64) (*DLeftFilter, error) {
+	if capacity <= 0 {
+		return nil, errCapacity
+	}
+	// a lookup compares the remainders of the cells of four buckets, each
+	// colliding with probability 2^-r
+	r := uint(math.Ceil(math.Log2(tables * bucketSize * maxLoad / falsePositive)))
+	if falsePositive <= 0 || falsePositive >= 1 || r > maxRemainder {
+		return nil, errFalsePositive
+	}
+	b := uint(0)
+	for float64(uint64(tables*bucketSize)<<b)*maxLoad < float64(capacity) {
+		b++
+	}
+	if b+r > 64 {
+		return nil, errCapacity
+	}
+	return &DLeftFilter{
+		b:     b,
+		r:     r,
+		table: make([]uint64, tableWords(b, r)),
+		mutex: &sync.RWMutex{},
+	}, nil
+}
+
+// Add adds the data to the filter. It returns an error, leaving the filter
+// unchanged, if the four buckets of the data are full. Data added more than
+// once is counted, and can be deleted as many times.
+func (f *DLeftFilter) Add(data []byte) error {
+	h, _ := ring.Sum128(data, 0)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	fp := h & mask(f.b+f.r)
+	if t, bucket, slot := f.find(fp); slot >= 0 {
+		if c := f.get(t, bucket, slot); c&mask(counterBits) < mask(counterBits) {
+			f.set(t, bucket, slot, c+1)
+		}
+		f.count++
+		return nil
+	}
+
+	// the leftmost of the least loaded buckets takes the remainder
+	best, bestLoad, bestSlot := -1, bucketSize+1, 0
+	for t := 0; t < tables; t++ {
+		bucket, _ := f.split(t, fp)
+		load, free := 0, -1
+		for slot := 0; slot < bucketSize; slot++ {
+			if f.get(t, bucket, slot) != 0 {
+				load++
+			} else if free < 0 {
+				free = slot
+			}
+		}
+		if load < bestLoad && free >= 0 {
+			best, bestLoad, bestSlot = t, load, free
+		}
+	}
+	if best < 0 {
+		return errFull
+	}
+	bucket, rem := f.split(best, fp)
+	f.set(best, bucket, bestSlot, rem<<counterBits|1)
+	f.count++
+	return nil
+}
+
+// Test returns a bool if the data is in the filter. True indicates that the
+// data may be in the filter, while false indicates that it is not.
+func (f *DLeftFilter) Test(data []byte) bool {
+	h, _ := ring.Sum128(data, 0)
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	_, _, slot := f.find(h & mask(f.b+f.r))
+	return slot >= 0
+}
+
+// TestCount returns an estimate of the number of times the data has been
+// added, less the times it was deleted. It is exact unless other data shares
+// the fingerprint, or the counter has saturated at 15.
+func (f *DLeftFilter) TestCount(data []byte) uint64 {
+	h, _ := ring.Sum128(data, 0)
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	t, bucket, slot := f.find(h & mask(f.b+f.r))
+	if slot < 0 {
+		return 0
+	}
+	return f.get(t, bucket, slot) & mask(counterBits)
+}
+
+// Delete removes the data from the filter and returns true, or returns false
+// if the data is not in the filter. Deleting data that was never added, but is
+// reported present as a false positive, removes another element.
+func (f *DLeftFilter) Delete(data []byte) bool {
+	h, _ := ring.Sum128(data, 0)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	t, bucket, slot := f.find(h & mask(f.b+f.r))
+	if slot < 0 {
+		return false
+	}
+	c := f.get(t, bucket, slot)
+	switch c & mask(counterBits) {
+	case 1:
+		f.set(t, bucket, slot, 0)
+	case mask(counterBits):
+		// saturated counters no longer know their count
+	default:
+		f.set(t, bucket, slot, c-1)
+	}
+	f.count--
+	return true
+}
+
+// Count returns the number of elements added to the filter, less those
+// deleted.
+func (f *DLeftFilter) Count() uint64 {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.count
+}
+
+// Capacity returns the number of cells in the filter, which bounds the number
+// of distinct elements it can hold.
+func (f *DLeftFilter) Capacity() uint64 {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return tables * bucketSize << f.b
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface. The output
+// is 1 byte of version, 1 byte each of bucket and remainder bits, the count as
+// 8 bytes, then the packed table as 8-byte words. Integers are big endian.
+func (f *DLeftFilter) MarshalBinary() ([]byte, error) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	out := make([]byte, headerSize+8*len(f.table))
+	out[0] = 1
+	out[1] = uint8(f.b)
+	out[2] = uint8(f.r)
+	binary.BigEndian.PutUint64(out[3:11], f.count)
+	for i, w := range f.table {
+		binary.BigEndian.PutUint64(out[headerSize+8*i:], w)
+	}
+	return out, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (f *DLeftFilter) UnmarshalBinary(data []byte) error {
+	if len(data) < headerSize {
+		return fmt.Errorf("incorrect length: %d", len(data))
+	}
+	if data[0] != 1 {
+		return fmt.Errorf("unexpected version: %d", data[0])
+	}
+	b, r := uint(data[1]), uint(data[2])
+	if r == 0 || r > maxRemainder || b > 40 || b+r > 64 {
+		return fmt.Errorf("invalid fingerprint: %d bucket bits, %d remainder bits", b, r)
+	}
+	if uint64(len(data)-headerSize) != 8*tableWords(b, r) {
+		return fmt.Errorf("incorrect length: %d", len(data))
+	}
+
+	if f.mutex == nil {
+		f.mutex = new(sync.RWMutex)
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.b = b
+	f.r = r
+	f.count = binary.BigEndian.Uint64(data[3:11])
+	f.table = make([]uint64, tableWords(b, r))
+	for i := range f.table {
+		f.table[i] = binary.BigEndian.Uint64(data[headerSize+8*i:])
+	}
+	return nil
+}
+
+// split returns the bucket and remainder of the fingerprint in subtable t,
+// from a permutation of the b+r bit fingerprints: a multiplication by an odd
+// constant, then a xorshift, both invertible.
+func (f *DLeftFilter) split(t int, fp uint64) (uint64, uint64) {
+	width := f.b + f.r
+	x := fp * multipliers[t] & mask(width)
+	x ^= x >> (width/2 + 1)
+	return x >> f.r, x & mask(f.r)
+}
+
+// find returns the subtable, bucket and slot holding the remainder of the
+// fingerprint, or a slot of -1. There is at most one, as Add only stores a
+// remainder if it is not already held.
+func (f *DLeftFilter) find(fp uint64) (int, uint64, int) {
+	for t := 0; t < tables; t++ {
+		bucket, rem := f.split(t, fp)
+		for slot := 0; slot < bucketSize; slot++ {
+			c := f.get(t, bucket, slot)
+			if c != 0 && c>>counterBits == rem {
+				return t, bucket, slot
+			}
+		}
+	}
+	return 0, 0, -1
+}
+
+// get returns the contents of a cell, its remainder above its counter.
+func (f *DLeftFilter) get(t int, bucket uint64, slot int) uint64 {
+	width := f.r + counterBits
+	bit := f.cell(t, bucket, slot) * uint64(width)
+	word, off := bit/64, uint(bit%64)
+	v := f.table[word] >> off
+	if off+width > 64 {
+		v |= f.table[word+1] << (64 - off)
+	}
+	return v & mask(width)
+}
+
+// set replaces the contents of a cell with v.
+func (f *DLeftFilter) set(t int, bucket uint64, slot int, v uint64) {
+	width := f.r + counterBits
+	bit := f.cell(t, bucket, slot) * uint64(width)
+	word, off := bit/64, uint(bit%64)
+	f.table[word] = f.table[word]&^(mask(width)<<off) | v<<off
+	if off+width > 64 {
+		rest := off + width - 64
+		f.table[word+1] = f.table[word+1]&^mask(rest) | v>>(64-off)
+	}
+}
+
+// cell returns the index of a cell in the table.
+func (f *DLeftFilter) cell(t int, bucket uint64, slot int) uint64 {
+	return (uint64(t)<<f.b+bucket)*bucketSize + uint64(slot)
+}
+
+// tableWords returns the number of words holding the cells of 4 subtables of
+// 2^b buckets, with r bits of remainder.
+func tableWords(b, r uint) uint64 {
+	return (tables*bucketSize<<b*uint64(r+counterBits) + 63) / 64
+}
+
+// mask returns a mask of the low n bits.
+func mask(n uint) uint64 {
+	if n >= 64 {
+		return math.MaxUint64
+	}
+	return 1<<n - 1
+}
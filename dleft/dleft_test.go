@@ -0,0 +1,144 @@
+package dleft
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func key(i int) []byte {
+	buff := make([]byte, 4)
+	binary.BigEndian.PutUint32(buff, uint32(i))
+	return buff
+}
+
+// TestDLeftFilter ensures added data is found until it is deleted, and that
+// the false positive rate is within the target.
+func TestDLeftFilter(t *testing.T) {
+	for _, fp := range []float64{0.01, 0.001} {
+		f, err := Init(10000, fp)
+		require.NoError(t, err)
+		for i := 0; i < 10000; i++ {
+			require.NoError(t, f.Add(key(i)), "fp %v element %d", fp, i)
+		}
+		require.Equal(t, uint64(10000), f.Count())
+		for i := 0; i < 10000; i++ {
+			require.True(t, f.Test(key(i)), "fp %v element %d", fp, i)
+		}
+
+		positives := 0
+		for i := 10000; i < 210000; i++ {
+			if f.Test(key(i)) {
+				positives++
+			}
+		}
+		require.True(t, float64(positives)/200000 < fp,
+			"fp %v false positives %d", fp, positives)
+
+		for i := 0; i < 5000; i++ {
+			require.True(t, f.Delete(key(i)), "fp %v element %d", fp, i)
+		}
+		require.Equal(t, uint64(5000), f.Count())
+		for i := 5000; i < 10000; i++ {
+			require.True(t, f.Test(key(i)), "fp %v element %d", fp, i)
+		}
+		require.False(t, f.Delete([]byte("never added")))
+	}
+
+	_, err := Init(0, 0.01)
+	require.Equal(t, errCapacity, err)
+	_, err = Init(10, 0)
+	require.Equal(t, errFalsePositive, err)
+	_, err = Init(10, 1e-10)
+	require.Equal(t, errFalsePositive, err)
+}
+
+// TestDLeftFilter_Permutation ensures the permutation of each subtable maps
+// every fingerprint to a distinct bucket and remainder.
+func TestDLeftFilter_Permutation(t *testing.T) {
+	f := &DLeftFilter{b: 5, r: 6}
+	for table := 0; table < tables; table++ {
+		seen := map[[2]uint64]bool{}
+		for fp := uint64(0); fp < 1<<11; fp++ {
+			bucket, rem := f.split(table, fp)
+			require.True(t, bucket < 1<<5 && rem < 1<<6)
+			seen[[2]uint64{bucket, rem}] = true
+		}
+		require.Len(t, seen, 1<<11, "table %d", table)
+	}
+}
+
+// TestDLeftFilter_Counts ensures repeated data is counted, deleted as many
+// times as it was added, and saturates without false negatives.
+func TestDLeftFilter_Counts(t *testing.T) {
+	f, _ := Init(100, 0.001)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, f.Add([]byte("three")))
+	}
+	require.Equal(t, uint64(3), f.TestCount([]byte("three")))
+	require.Equal(t, uint64(0), f.TestCount([]byte("absent")))
+	for i := 0; i < 3; i++ {
+		require.True(t, f.Test([]byte("three")))
+		require.True(t, f.Delete([]byte("three")))
+	}
+	require.False(t, f.Test([]byte("three")))
+	require.Equal(t, uint64(0), f.Count())
+
+	for i := 0; i < 20; i++ {
+		require.NoError(t, f.Add([]byte("hot")))
+	}
+	require.Equal(t, uint64(15), f.TestCount([]byte("hot")))
+	for i := 0; i < 20; i++ {
+		require.True(t, f.Delete([]byte("hot")))
+	}
+	require.True(t, f.Test([]byte("hot")))
+}
+
+// TestDLeftFilter_Full ensures a full filter reports an error and is left
+// unchanged, and accepts inserts again once an element is deleted.
+func TestDLeftFilter_Full(t *testing.T) {
+	f, _ := Init(10, 0.01)
+	var err error
+	n := 0
+	for ; err == nil; n++ {
+		err = f.Add(key(n))
+	}
+	require.Equal(t, errFull, err)
+	require.True(t, uint64(n) > f.Capacity()*3/4, "%d of %d", n, f.Capacity())
+	require.Equal(t, uint64(n-1), f.Count())
+	require.False(t, f.Test(key(n-1)))
+	for i := 0; i < n-1; i++ {
+		require.True(t, f.Test(key(i)), "element %d", i)
+	}
+	require.True(t, f.Delete(key(0)))
+	require.NoError(t, f.Add(key(0)))
+}
+
+// TestDLeftFilter_Marshal ensures the filter round trips and bad data is
+// rejected.
+func TestDLeftFilter_Marshal(t *testing.T) {
+	f, _ := Init(100, 0.01)
+	f.Add([]byte("data"))
+	f.Add([]byte("data"))
+	out, err := f.MarshalBinary()
+	require.NoError(t, err)
+
+	u := new(DLeftFilter)
+	require.NoError(t, u.UnmarshalBinary(out))
+	require.Equal(t, uint64(2), u.Count())
+	require.Equal(t, uint64(2), u.TestCount([]byte("data")))
+	require.True(t, u.Delete([]byte("data")))
+	require.True(t, u.Test([]byte("data")))
+	require.True(t, f.Test([]byte("data")))
+
+	require.Error(t, u.UnmarshalBinary(out[:len(out)-1]))
+	require.Error(t, u.UnmarshalBinary(out[:headerSize-1]))
+	bad := append([]byte{}, out...)
+	bad[0] = 2
+	require.Error(t, u.UnmarshalBinary(bad))
+	bad[0], bad[2] = 1, 0
+	require.Error(t, u.UnmarshalBinary(bad))
+	bad[2] = 33
+	require.Error(t, u.UnmarshalBinary(bad))
+}
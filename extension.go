@@ -0,0 +1,177 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// A ring with optional metadata, such as provenance, is marshaled in version 2
+// of the format, which places an extension section between the header and the
+// bit array:
+//
+//	[2][size 8][hash 8][section length 4][extensions][bits]
+//
+// Each extension is [type 1][flags 1][payload length 4][payload]. A reader
+// skips extensions of an unknown type unless they are flagged critical, in
+// which case the ring cannot be decoded correctly without understanding them.
+// Rings without metadata are marshaled in version 1, so their output is
+// unchanged.
+
+const (
+	// extensionLengthSize is the number of bytes of the section length
+	// following the header in version 2.
+	extensionLengthSize = 4
+	// extensionHeaderSize is the number of bytes preceding each payload.
+	extensionHeaderSize = 6
+)
+
+// extensionCritical is the flag for an extension that may not be skipped.
+const extensionCritical = 1 << 0
+
+// Extension types.
+const (
+	extensionProvenance = 1
+	extensionHashing    = 2
+	extensionLayout     = 3
+)
+
+var (
+	errExtension = errors.New("error: malformed extension section")
+	errVersion   = errors.New("error: unsupported format version")
+)
+
+// extension is an entry in the extension section.
+type extension struct {
+	kind     uint8
+	critical bool
+	payload  []byte
+}
+
+// extensions returns the extensions the ring carries when marshaled. The
+// caller must hold the read lock.
+func (r *Bloom) extensions() []extension {
+	var exts []extension
+	if r.hashing != (hashing{}) {
+		// data cannot be tested without the hash family and seed
+		exts = append(exts, extension{
+			kind:     extensionHashing,
+			critical: true,
+			payload:  r.hashing.encode(),
+		})
+	}
+	if r.layout != LayoutStandard {
+		// data cannot be tested without knowing where its bits are
+		exts = append(exts, extension{
+			kind:     extensionLayout,
+			critical: true,
+			payload:  []byte{uint8(r.layout)},
+		})
+	}
+	if !r.provenance.empty() {
+		exts = append(exts, extension{
+			kind:    extensionProvenance,
+			payload: r.provenance.encode(),
+		})
+	}
+	return exts
+}
+
+// extensionSection returns the encoded extension section of the ring, or nil
+// if it has no metadata to carry. The caller must hold the read lock.
+func (r *Bloom) extensionSection() []byte {
+	return encodeExtensions(r.extensions())
+}
+
+// encodeExtensions returns the extension section holding exts, or nil if
+// there are none.
+func encodeExtensions(exts []extension) []byte {
+	var out []byte
+	for _, ext := range exts {
+		var head [extensionHeaderSize]byte
+		head[0] = ext.kind
+		if ext.critical {
+			head[1] |= extensionCritical
+		}
+		binary.BigEndian.PutUint32(head[2:], uint32(len(ext.payload)))
+		out = append(out, head[:]...)
+		out = append(out, ext.payload...)
+	}
+	return out
+}
+
+// bitsOffset returns the offset of the bit array in the marshaled ring given
+// its extension section.
+func bitsOffset(section []byte) int {
+	if section == nil {
+		return headerSize
+	}
+	return headerSize + extensionLengthSize + len(section)
+}
+
+// decodeExtensions splits an extension section into its extensions.
+func decodeExtensions(section []byte) ([]extension, error) {
+	var exts []extension
+	for len(section) > 0 {
+		if len(section) < extensionHeaderSize {
+			return nil, errExtension
+		}
+		ext := extension{
+			kind:     section[0],
+			critical: section[1]&extensionCritical != 0,
+		}
+		length := binary.BigEndian.Uint32(section[2:extensionHeaderSize])
+		section = section[extensionHeaderSize:]
+		if uint64(length) > uint64(len(section)) {
+			return nil, errExtension
+		}
+		ext.payload = section[:length]
+		exts = append(exts, ext)
+		section = section[length:]
+	}
+	return exts, nil
+}
+
+// unknownExtensionError returns the error for a critical extension of an
+// unknown type.
+func unknownExtensionError(kind uint8) error {
+	return fmt.Errorf("error: unsupported critical extension: %d", kind)
+}
+
+// MarshalForVersion is MarshalBinary producing the given format version, so
+// that rings can be sent to readers that predate the current one during a
+// rolling upgrade. Extensions that the version cannot carry are dropped if
+// they are optional; if any is critical, the reader would misinterpret the
+// ring without it and an error is returned instead. Version 2 is produced even
+// if the ring has no extensions. It returns an error if the version is not
+// 1 or 2.
+func (r *Bloom) MarshalForVersion(v uint8) ([]byte, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if err := r.checkUsable("marshal"); err != nil {
+		return nil, err
+	}
+	exts := r.extensions()
+	switch v {
+	case 1:
+		for _, ext := range exts {
+			if ext.critical {
+				return nil, fmt.Errorf("error: critical extension %d "+
+					"cannot be carried by version 1", ext.kind)
+			}
+		}
+		return r.marshal(nil, headerSize+len(r.bits)), nil
+	case 2:
+		section := encodeExtensions(exts)
+		if section == nil {
+			section = []byte{}
+		}
+		return r.marshal(section, bitsOffset(section)+len(r.bits)), nil
+	default:
+		return nil, errVersion
+	}
+}
@@ -0,0 +1,115 @@
+package ring
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// withExtension returns the marshaled ring with ext appended to its extension
+// section.
+func withExtension(r *Bloom, ext []byte) []byte {
+	r.mutex.RLock()
+	section := append(r.extensionSection(), ext...)
+	out := r.marshal(section, bitsOffset(section)+len(r.bits))
+	r.mutex.RUnlock()
+	return out
+}
+
+// TestBloom_UnmarshalExtensions ensures unknown extensions are skipped unless
+// they are critical.
+func TestBloom_UnmarshalExtensions(t *testing.T) {
+	r, _ := Init(1000, 0.01)
+	r.Add([]byte("data"))
+	r.AddContributor([]byte("node"))
+
+	unknown := []byte{200, 0, 0, 0, 0, 3, 1, 2, 3}
+	out := withExtension(r, unknown)
+	r2 := new(Bloom)
+	require.NoError(t, r2.UnmarshalBinary(out))
+	require.True(t, r.Equal(r2))
+	require.Equal(t, r.Provenance(), r2.Provenance())
+
+	unknown[1] = extensionCritical
+	out = withExtension(r, unknown)
+	r3, _ := Init(10, 0.01)
+	size := r3.GetSize()
+	require.Error(t, r3.UnmarshalBinary(out))
+	require.Equal(t, size, r3.GetSize())
+	require.True(t, r3.IsEmpty())
+}
+
+// TestBloom_UnmarshalExtensionsMalformed ensures a section that overruns the
+// data is rejected.
+func TestBloom_UnmarshalExtensionsMalformed(t *testing.T) {
+	r, _ := Init(100, 0.01)
+	out := withExtension(r, []byte{200, 0, 0, 0, 0, 3, 1, 2, 3})
+	binary.BigEndian.PutUint32(out[headerSize:], uint32(len(out)))
+	require.Error(t, new(Bloom).UnmarshalBinary(out))
+
+	out = withExtension(r, []byte{200, 0, 0, 0, 0, 4, 1, 2, 3})
+	require.Error(t, new(Bloom).UnmarshalBinary(out))
+	require.Error(t, new(Bloom).UnmarshalBinary(out[:headerSize+2]))
+}
+
+// TestBloom_MarshalPaddedExtensions ensures padded and obfuscated output keeps
+// the extension section intact.
+func TestBloom_MarshalPaddedExtensions(t *testing.T) {
+	r, _ := Init(100, 0.01)
+	r.Add([]byte("data"))
+	r.AddContributor([]byte("node"))
+
+	outs := make([][]byte, 0, 3)
+	out, err := r.MarshalBinaryPadded(512)
+	require.NoError(t, err)
+	outs = append(outs, out)
+	out, err = r.MarshalBinaryAligned(64)
+	require.NoError(t, err)
+	require.Zero(t, len(out)%64)
+	outs = append(outs, out)
+	out, err = r.MarshalObfuscated(512, 0)
+	require.NoError(t, err)
+	outs = append(outs, out)
+
+	for _, out := range outs {
+		r2 := new(Bloom)
+		require.NoError(t, r2.UnmarshalBinary(out))
+		require.True(t, r.Equal(r2))
+		require.Equal(t, r.Provenance(), r2.Provenance())
+	}
+}
+
+// TestBloom_MarshalForVersion ensures each version round trips and optional
+// extensions are dropped for version 1.
+func TestBloom_MarshalForVersion(t *testing.T) {
+	r, _ := Init(1000, 0.01)
+	r.Add([]byte("data"))
+
+	current, _ := r.MarshalBinary()
+	out, err := r.MarshalForVersion(1)
+	require.NoError(t, err)
+	require.Equal(t, current, out)
+
+	out, err = r.MarshalForVersion(2)
+	require.NoError(t, err)
+	require.Equal(t, uint8(2), out[0])
+	require.Len(t, out, headerSize+extensionLengthSize+r.BufferSize())
+	r2 := new(Bloom)
+	require.NoError(t, r2.UnmarshalBinary(out))
+	require.True(t, r.Equal(r2))
+
+	r.AddContributor([]byte("node"))
+	out, err = r.MarshalForVersion(1)
+	require.NoError(t, err)
+	require.Equal(t, current, out)
+	out, err = r.MarshalForVersion(2)
+	require.NoError(t, err)
+	current, _ = r.MarshalBinary()
+	require.Equal(t, current, out)
+
+	_, err = r.MarshalForVersion(3)
+	require.Error(t, err)
+	_, err = r.MarshalForVersion(0)
+	require.Error(t, err)
+}
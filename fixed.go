@@ -0,0 +1,98 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+// FixedBloom128 is a filter held entirely in a 128-byte (1024-bit) array, for
+// embedding in fixed-size structures such as message headers. It contains no
+// pointers and never allocates. Unlike Bloom it is not safe for concurrent use
+// while being modified.
+type FixedBloom128 struct {
+	Hash uint8      // number of hash rounds
+	Bits [128]uint8 // bit array
+}
+
+// NewFixedBloom128 returns an empty filter using the given number of hash
+// rounds, or an error if it is not greater than 0.
+func NewFixedBloom128(hash uint8) (FixedBloom128, error) {
+	if hash == 0 {
+		return FixedBloom128{}, errHash
+	}
+	return FixedBloom128{Hash: hash}, nil
+}
+
+// Add adds the data to the filter.
+func (f *FixedBloom128) Add(data []byte) {
+	fixedAdd(f.Bits[:], f.Hash, data)
+}
+
+// Test returns a bool if the data is in the filter. True indicates that the
+// data may be in the filter, while false indicates that it is not.
+func (f *FixedBloom128) Test(data []byte) bool {
+	return fixedTest(f.Bits[:], f.Hash, data)
+}
+
+// Reset clears the filter.
+func (f *FixedBloom128) Reset() {
+	f.Bits = [128]uint8{}
+}
+
+// FixedBloom256 is a filter held entirely in a 256-byte (2048-bit) array, for
+// embedding in fixed-size structures such as message headers. It contains no
+// pointers and never allocates. Unlike Bloom it is not safe for concurrent use
+// while being modified.
+type FixedBloom256 struct {
+	Hash uint8      // number of hash rounds
+	Bits [256]uint8 // bit array
+}
+
+// NewFixedBloom256 returns an empty filter using the given number of hash
+// rounds, or an error if it is not greater than 0.
+func NewFixedBloom256(hash uint8) (FixedBloom256, error) {
+	if hash == 0 {
+		return FixedBloom256{}, errHash
+	}
+	return FixedBloom256{Hash: hash}, nil
+}
+
+// Add adds the data to the filter.
+func (f *FixedBloom256) Add(data []byte) {
+	fixedAdd(f.Bits[:], f.Hash, data)
+}
+
+// Test returns a bool if the data is in the filter. True indicates that the
+// data may be in the filter, while false indicates that it is not.
+func (f *FixedBloom256) Test(data []byte) bool {
+	return fixedTest(f.Bits[:], f.Hash, data)
+}
+
+// Reset clears the filter.
+func (f *FixedBloom256) Reset() {
+	f.Bits = [256]uint8{}
+}
+
+// fixedAdd sets the bits for the data in a fixed-size bit array, probing it
+// exactly as a Bloom of the same size and hash rounds would.
+func fixedAdd(bits []uint8, hash uint8, data []byte) {
+	h := generateMultiHash(data, 0)
+	size := uint64(len(bits)) * 8
+	for i := uint64(0); i < uint64(hash); i++ {
+		index := getRound(h, i) % size
+		bits[index/8] |= 1 << (index % 8)
+	}
+}
+
+// fixedTest returns true if all bits for the data are set in a fixed-size bit
+// array.
+func fixedTest(bits []uint8, hash uint8, data []byte) bool {
+	h := generateMultiHash(data, 0)
+	size := uint64(len(bits)) * 8
+	for i := uint64(0); i < uint64(hash); i++ {
+		index := getRound(h, i) % size
+		if bits[index/8]&(1<<(index%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package ring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestFixedBloom128 ensures the fixed filter matches a Bloom of the same
+// parameters and does not allocate.
+func TestFixedBloom128(t *testing.T) {
+	f, err := NewFixedBloom128(4)
+	require.NoError(t, err)
+	r, _ := InitByParameters(1024, 4)
+
+	buff := make([]byte, 4)
+	for i := 0; i < 100; i++ {
+		intToByte(buff, i)
+		f.Add(buff)
+		r.Add(buff)
+		require.True(t, f.Test(buff))
+	}
+	require.Equal(t, r.bits, f.Bits[:])
+
+	allocs := testing.AllocsPerRun(100, func() {
+		f.Add(buff)
+		f.Test(buff)
+	})
+	require.Zero(t, allocs)
+
+	f.Reset()
+	require.Equal(t, [128]uint8{}, f.Bits)
+
+	_, err = NewFixedBloom128(0)
+	require.Error(t, err)
+}
+
+// TestFixedBloom256 ensures the fixed filter matches a Bloom of the same
+// parameters.
+func TestFixedBloom256(t *testing.T) {
+	f, err := NewFixedBloom256(3)
+	require.NoError(t, err)
+	r, _ := InitByParameters(2048, 3)
+
+	buff := make([]byte, 4)
+	for i := 0; i < 200; i++ {
+		intToByte(buff, i)
+		f.Add(buff)
+		r.Add(buff)
+	}
+	require.Equal(t, r.bits, f.Bits[:])
+	intToByte(buff, 0)
+	require.True(t, f.Test(buff))
+
+	f.Reset()
+	require.False(t, f.Test(buff))
+
+	_, err = NewFixedBloom256(0)
+	require.Error(t, err)
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+// Frozen is an immutable view of a ring, returned by Freeze. It only exposes
+// reads, so the type system prevents it from being modified, and as nothing
+// can modify it, it takes no locks and is safe for any number of concurrent
+// readers. It stays valid even if the ring it was frozen from is destroyed.
+type Frozen struct {
+	r *Bloom // private snapshot that is never written
+}
+
+// Freeze moves the ring to StateFrozen, if it is not already, and returns an
+// immutable view of it. The view shares the bit array of the ring, which can
+// no longer change. It returns a *StateError if the ring has been destroyed.
+func (r *Bloom) Freeze() (*Frozen, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err := r.checkUsable("freeze"); err != nil {
+		return nil, err
+	}
+	r.state = StateFrozen
+	r.shared = true
+
+	snapshot := *r
+	snapshot.mutex = noopLocker{}
+	snapshot.pool = nil
+	snapshot.samples = nil
+	snapshot.provenance = r.provenance.clone()
+	return &Frozen{r: &snapshot}, nil
+}
+
+// Test returns a bool if the data is in the ring, as Bloom.Test would.
+func (f *Frozen) Test(data []byte) bool {
+	return f.r.testHash(f.r.hashing.sum(data))
+}
+
+// TestHash returns a bool if the element the handle was computed from is in
+// the ring, as Bloom.TestHash would. It panics if the handle was computed
+// with a different hash family or seed than the ring's.
+func (f *Frozen) TestHash(h HashHandle) bool {
+	f.r.mustMatch(h)
+	return f.r.testHash(h.hash)
+}
+
+// Hash hashes the data with the hash family and seed of the ring, as
+// Bloom.Hash would.
+func (f *Frozen) Hash(data []byte) HashHandle {
+	return f.r.hashing.handle(data)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface, producing
+// the same output as the ring it was frozen from.
+func (f *Frozen) MarshalBinary() ([]byte, error) {
+	return f.r.MarshalBinary()
+}
+
+// Digest returns the digest of the bit array, as Bloom.Digest would.
+func (f *Frozen) Digest() uint64 {
+	return f.r.digest
+}
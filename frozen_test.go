@@ -0,0 +1,54 @@
+package ring
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestBloom_Freeze ensures the view answers as the ring did, the ring can no
+// longer be modified, and the view survives the ring being destroyed.
+func TestBloom_Freeze(t *testing.T) {
+	r, _ := Init(1000, 0.01, WithSeed(7))
+	buff := make([]byte, 4)
+	for i := 0; i < 100; i++ {
+		intToByte(buff, i)
+		r.Add(buff)
+	}
+	expected, _ := r.MarshalBinary()
+
+	f, err := r.Freeze()
+	require.NoError(t, err)
+	require.Equal(t, StateFrozen, r.State())
+	require.Panics(t, func() { r.Add(buff) })
+	again, err := r.Freeze()
+	require.NoError(t, err)
+
+	require.NoError(t, r.Transition(StateDestroyed))
+	_, err = r.Freeze()
+	require.IsType(t, &StateError{}, err)
+
+	out, err := f.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, expected, out)
+	require.Equal(t, f.Digest(), again.Digest())
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buff := make([]byte, 4)
+			for i := 0; i < 100; i++ {
+				intToByte(buff, i)
+				if !f.Test(buff) || !f.TestHash(f.Hash(buff)) {
+					t.Errorf("element %d not found", i)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	require.Panics(t, func() { f.TestHash(Hash(buff)) })
+}
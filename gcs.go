@@ -0,0 +1,271 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"sort"
+	"sync"
+)
+
+var (
+	errGCS       = errors.New("error: malformed golomb-coded set")
+	errRiceParam = errors.New("error: the rice parameter must be less than 64")
+	errGCSRange  = errors.New("error: the values are too large to code with the rice parameter")
+	errGCSSize   = errors.New("error: rings of more than 2^32 bits cannot be golomb-coded")
+)
+
+const (
+	// gcsHeaderSize is the number of bytes preceding the coded values in the
+	// output of EncodeGCS: 1 byte of rice parameter and 8 bytes of count.
+	gcsHeaderSize = 9
+	// gcsMaxUnary is the most unary bits EncodeGCS writes per value on
+	// average, beyond an allowance of gcsMinUnary bits for small sets. The
+	// unary quotients sum to at most the largest value over 2^p, which is
+	// about 2 per value for values reduced as EncodeGCS describes.
+	gcsMaxUnary = 64
+	gcsMinUnary = 1 << 16
+	// maxGCSBits is the largest ring ToGCS codes and FromGCS decodes, as the
+	// coded size says nothing of the size of the bit array it expands to.
+	maxGCSBits = 1 << 32
+)
+
+// A Golomb-compressed set codes a sorted set of values as the gaps between
+// them, each split into a quotient, written in unary, and the low p bits of
+// the remainder, written as is. For gaps averaging about 2^p, this takes
+// about p+1.5 bits per value; for larger gaps the unary quotients grow
+// without bound.
+
+// EncodeGCS returns the Golomb-Rice coding of the distinct values with the
+// rice parameter p, which should be near the log2 of the average gap between
+// sorted values. For hashed keys reduced to [0, n*2^p), for n keys, it gives
+// a set with a false positive rate of 2^-p in about p+1.5 bits per key. The
+// output is 1 byte of p and the number of values as 8 bytes, big endian, then
+// the coded values. It returns an error if p is not less than 64, or the
+// values are too large for p, taking more than 64 bits per value of unary
+// quotient, as raw 64-bit hashes do for any p much below 58.
+func EncodeGCS(values []uint64, p uint) ([]byte, error) {
+	if p >= 64 {
+		return nil, errRiceParam
+	}
+	sorted := append([]uint64{}, values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	n := 0
+	for i, v := range sorted {
+		if i == 0 || v != sorted[n-1] {
+			sorted[n] = v
+			n++
+		}
+	}
+	if n > 0 && sorted[n-1]>>p > gcsMinUnary+gcsMaxUnary*uint64(n) {
+		return nil, errGCSRange
+	}
+	return encodeGCS(sorted[:n], p), nil
+}
+
+// DecodeGCS returns the values coded by EncodeGCS, in increasing order.
+func DecodeGCS(data []byte) ([]uint64, error) {
+	values, _, err := decodeGCS(data, math.MaxUint64)
+	return values, err
+}
+
+// ToGCS returns the ring with its bit array coded as the Golomb-compressed set
+// of the positions of its set bits, which is smaller than MarshalBinary for a
+// sparse ring, less than about a tenth full. The output is the output of
+// MarshalBinary with the bit array replaced by the output of EncodeGCS. It
+// returns a *StateError if the ring has been destroyed, and an error if it has
+// more than 2^32 bits.
+func (r *Bloom) ToGCS() ([]byte, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if err := r.checkUsable("marshal"); err != nil {
+		return nil, err
+	}
+	if r.size > maxGCSBits {
+		return nil, errGCSSize
+	}
+
+	var positions []uint64
+	for i, b := range r.bits {
+		for ; b != 0; b &= b - 1 {
+			positions = append(positions, uint64(i)*8+uint64(bits.TrailingZeros8(b)))
+		}
+	}
+	// the gaps between set bits average size/set
+	p := uint(0)
+	if len(positions) > 0 && r.size/uint64(len(positions)) > 1 {
+		p = uint(bits.Len64(r.size/uint64(len(positions)))) - 1
+	}
+
+	section := r.extensionSection()
+	header := r.marshal(section, bitsOffset(section))
+	return append(header, encodeGCS(positions, p)...), nil
+}
+
+// FromGCS returns the ring coded by ToGCS. Like ToGCS, it returns an error for
+// a ring of more than 2^32 bits, rather than allocate whatever the header asks
+// for. The options are applied before decoding, so a keyed ring can be decoded
+// by passing WithKey.
+func FromGCS(data []byte, opts ...Option) (*Bloom, error) {
+	d, err := decodeBinary(data)
+	if err != nil {
+		return nil, err
+	}
+	if d.size == 0 {
+		return nil, errGCS
+	}
+	if d.size > maxGCSBits {
+		return nil, errGCSSize
+	}
+	positions, n, err := decodeGCS(data[d.offset:], d.size-1)
+	if err != nil {
+		return nil, err
+	}
+	if d.offset+n != len(data) {
+		return nil, errGCS
+	}
+
+	bits := make([]uint8, getBuffSize(d.size))
+	for _, p := range positions {
+		bits[p/8] |= 1 << (p % 8)
+	}
+	r := &Bloom{mutex: &sync.RWMutex{}}
+	for _, opt := range opts {
+		if err := opt(r); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.adoptKey(&d); err != nil {
+		return nil, err
+	}
+	r.load(d, bits)
+	return r, nil
+}
+
+// encodeGCS returns the Golomb-Rice coding of the values, which must be sorted
+// and distinct.
+func encodeGCS(values []uint64, p uint) []byte {
+	out := make([]byte, gcsHeaderSize, gcsHeaderSize+len(values)*int(p+2)/8+1)
+	out[0] = uint8(p)
+	binary.BigEndian.PutUint64(out[1:], uint64(len(values)))
+	w := bitWriter{out: out}
+	for i, v := range values {
+		gap := v
+		if i > 0 {
+			gap = v - values[i-1] - 1
+		}
+		for q := gap >> p; q > 0; q-- {
+			w.write(1, 1)
+		}
+		w.write(0, 1)
+		w.write(gap, p)
+	}
+	return w.out
+}
+
+// decodeGCS returns the values coded by encodeGCS, each at most max, and the
+// number of bytes read.
+func decodeGCS(data []byte, max uint64) ([]uint64, int, error) {
+	if len(data) < gcsHeaderSize {
+		return nil, 0, errGCS
+	}
+	p := uint(data[0])
+	if p >= 64 {
+		return nil, 0, errRiceParam
+	}
+	count := binary.BigEndian.Uint64(data[1:gcsHeaderSize])
+	// every value takes at least p+1 bits
+	if count > uint64(len(data)-gcsHeaderSize)*8/uint64(p+1) {
+		return nil, 0, errGCS
+	}
+
+	values := make([]uint64, count)
+	rd := bitReader{data: data[gcsHeaderSize:]}
+	next := uint64(0)
+	for i := range values {
+		if i > 0 && values[i-1] >= max {
+			return nil, 0, errGCS
+		}
+		var q uint64
+		for {
+			b, ok := rd.read(1)
+			if !ok {
+				return nil, 0, errGCS
+			}
+			if b == 0 {
+				break
+			}
+			q++
+		}
+		rem, ok := rd.read(p)
+		if !ok || q > (max-next)>>p {
+			return nil, 0, errGCS
+		}
+		gap := q<<p | rem
+		if gap > max-next {
+			return nil, 0, errGCS
+		}
+		values[i] = next + gap
+		next = values[i] + 1
+	}
+	return values, gcsHeaderSize + rd.bytes(), nil
+}
+
+// bitWriter appends bits to a byte slice, most significant bit first.
+type bitWriter struct {
+	out  []byte
+	used uint // bits used in the last byte, 0 if it is full
+}
+
+// write appends the low n bits of v.
+func (w *bitWriter) write(v uint64, n uint) {
+	for n > 0 {
+		if w.used == 0 {
+			w.out = append(w.out, 0)
+		}
+		take := 8 - w.used
+		if take > n {
+			take = n
+		}
+		chunk := uint8(v>>(n-take)) & uint8(1<<take-1)
+		w.out[len(w.out)-1] |= chunk << (8 - w.used - take)
+		w.used = (w.used + take) % 8
+		n -= take
+	}
+}
+
+// bitReader reads bits from a byte slice, most significant bit first.
+type bitReader struct {
+	data []byte
+	pos  uint64 // bits read
+}
+
+// read returns the next n bits, or false if there are not enough.
+func (rd *bitReader) read(n uint) (uint64, bool) {
+	if rd.pos+uint64(n) > uint64(len(rd.data))*8 {
+		return 0, false
+	}
+	var v uint64
+	for n > 0 {
+		off := uint(rd.pos % 8)
+		take := 8 - off
+		if take > n {
+			take = n
+		}
+		chunk := rd.data[rd.pos/8] >> (8 - off - take) & uint8(1<<take-1)
+		v = v<<take | uint64(chunk)
+		rd.pos += uint64(take)
+		n -= take
+	}
+	return v, true
+}
+
+// bytes returns the number of bytes read from, including a partial byte.
+func (rd *bitReader) bytes() int {
+	return int((rd.pos + 7) / 8)
+}
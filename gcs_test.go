@@ -0,0 +1,152 @@
+package ring
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestEncodeGCS ensures values round trip sorted and distinct, for rice
+// parameters from 0 up, and bad data is rejected.
+func TestEncodeGCS(t *testing.T) {
+	small := []uint64{900, 3, 3, 0, 77, 78}
+	large := append([]uint64{math.MaxUint64, 1 << 40}, small...)
+	tests := []struct {
+		values []uint64
+		want   []uint64
+		p      uint
+	}{
+		{small, []uint64{0, 3, 77, 78, 900}, 0},
+		{small, []uint64{0, 3, 77, 78, 900}, 1},
+		{small, []uint64{0, 3, 77, 78, 900}, 7},
+		{large, []uint64{0, 3, 77, 78, 900, 1 << 40, math.MaxUint64}, 58},
+		{large, []uint64{0, 3, 77, 78, 900, 1 << 40, math.MaxUint64}, 63},
+	}
+	for _, test := range tests {
+		out, err := EncodeGCS(test.values, test.p)
+		require.NoError(t, err)
+		decoded, err := DecodeGCS(out)
+		require.NoError(t, err, "p %d", test.p)
+		require.Equal(t, test.want, decoded, "p %d", test.p)
+
+		_, err = DecodeGCS(out[:len(out)-1])
+		require.Error(t, err, "p %d", test.p)
+	}
+
+	empty, err := EncodeGCS(nil, 4)
+	require.NoError(t, err)
+	decoded, err := DecodeGCS(empty)
+	require.NoError(t, err)
+	require.Empty(t, decoded)
+
+	_, err = EncodeGCS(small, 64)
+	require.Equal(t, errRiceParam, err)
+	// raw 64-bit hashes would take about 2^55 unary bits each
+	hashes := make([]uint64, 1000)
+	for i := range hashes {
+		hashes[i], _ = murmur128([]byte{byte(i), byte(i >> 8)}, 0)
+	}
+	_, err = EncodeGCS(hashes, 8)
+	require.Equal(t, errGCSRange, err)
+	_, err = EncodeGCS(large, 40)
+	require.Equal(t, errGCSRange, err)
+	_, err = DecodeGCS(empty[:gcsHeaderSize-1])
+	require.Error(t, err)
+	bad := append([]byte{}, empty...)
+	bad[8] = 1
+	_, err = DecodeGCS(bad)
+	require.Error(t, err)
+}
+
+// TestBloom_ToGCS ensures a sparse ring round trips through a smaller
+// encoding than MarshalBinary, whatever its layout.
+func TestBloom_ToGCS(t *testing.T) {
+	for _, layout := range []Layout{LayoutStandard, LayoutBlocked, LayoutPartitioned} {
+		r, _ := Init(1000, fpRate, WithSeed(7), WithLayout(layout))
+		buff := make([]byte, 4)
+		for i := 0; i < 50; i++ {
+			intToByte(buff, i)
+			r.Add(buff)
+		}
+		out, err := r.ToGCS()
+		require.NoError(t, err)
+		full, _ := r.MarshalBinary()
+		require.True(t, len(out) < len(full)/4, "layout %s: %d of %d", layout, len(out), len(full))
+
+		u, err := FromGCS(out)
+		require.NoError(t, err)
+		require.True(t, r.Equal(u), "layout %s", layout)
+		require.Equal(t, r.Digest(), u.Digest())
+		for i := 0; i < 50; i++ {
+			intToByte(buff, i)
+			require.True(t, u.Test(buff))
+		}
+
+		_, err = FromGCS(append(out, 0))
+		require.Error(t, err)
+		_, err = FromGCS(out[:len(out)-1])
+		require.Error(t, err)
+	}
+
+	empty, _ := Init(1000, fpRate)
+	out, err := empty.ToGCS()
+	require.NoError(t, err)
+	u, err := FromGCS(out)
+	require.NoError(t, err)
+	require.True(t, u.IsEmpty())
+}
+
+// TestBloom_FromGCSBounds ensures positions beyond the ring are rejected.
+func TestBloom_FromGCSBounds(t *testing.T) {
+	r, _ := InitByParameters(64, 3)
+	section := r.extensionSection()
+	header := r.marshal(section, bitsOffset(section))
+	for _, positions := range [][]uint64{{63}, {0, 64}} {
+		data := append(append([]byte{}, header...), encodeGCS(positions, 2)...)
+		_, err := FromGCS(data)
+		if positions[len(positions)-1] < 64 {
+			require.NoError(t, err)
+		} else {
+			require.Error(t, err)
+		}
+	}
+}
+
+// TestBloom_FromGCSSize ensures a header claiming a huge ring is rejected
+// before its bit array is allocated.
+func TestBloom_FromGCSSize(t *testing.T) {
+	r, _ := InitByParameters(64, 3)
+	out, err := r.ToGCS()
+	require.NoError(t, err)
+	binary.BigEndian.PutUint64(out[1:9], 1<<62)
+	_, err = FromGCS(out)
+	require.Equal(t, errGCSSize, err)
+	binary.BigEndian.PutUint64(out[1:9], maxGCSBits+1)
+	_, err = FromGCS(out)
+	require.Equal(t, errGCSSize, err)
+}
+
+// TestBloom_ToGCSDestroyed ensures a destroyed ring cannot be encoded.
+func TestBloom_ToGCSDestroyed(t *testing.T) {
+	r, _ := Init(100, fpRate)
+	require.NoError(t, r.Transition(StateDestroyed))
+	_, err := r.ToGCS()
+	require.IsType(t, &StateError{}, err)
+}
+
+// TestBloom_FromGCSKeyed ensures a keyed ring is decoded given its key.
+func TestBloom_FromGCSKeyed(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	r, _ := Init(1000, 0.01, WithKey(key))
+	r.Add([]byte("a"))
+	out, err := r.ToGCS()
+	require.NoError(t, err)
+
+	_, err = FromGCS(out)
+	require.Equal(t, errNoKey, err)
+	u, err := FromGCS(out, WithKey(key))
+	require.NoError(t, err)
+	require.True(t, r.Equal(u))
+}
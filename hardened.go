@@ -0,0 +1,64 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import "errors"
+
+var errSalt = errors.New("error: the salt must be at least 16 bytes")
+
+// hardenedKeySize is the size of the key scrypt derives from each element.
+const hardenedKeySize = 32
+
+// HardenedBloom is a ring whose elements are first run through scrypt with a
+// salt, for rings of low-entropy members such as phone numbers or email
+// addresses. Anyone holding a plain ring can enumerate its members by testing
+// every candidate, which takes moments for a phone number; the cost of scrypt
+// makes each candidate as slow to test as it is to add, and the salt prevents
+// sharing that work between rings. The salt is not secret and must be given
+// to whoever tests the ring, with the same parameters, but it should be
+// unique to each ring.
+type HardenedBloom struct {
+	r      *Bloom
+	salt   []byte
+	params ScryptParams
+}
+
+// NewHardened returns a HardenedBloom adding to and testing r, which must not
+// be used directly. It returns an error if the salt is shorter than 16 bytes
+// or the parameters are invalid.
+func NewHardened(r *Bloom, salt []byte, params ScryptParams) (*HardenedBloom, error) {
+	if len(salt) < 16 {
+		return nil, errSalt
+	}
+	if !params.valid() {
+		return nil, errScryptParams
+	}
+	return &HardenedBloom{
+		r:      r,
+		salt:   append([]byte{}, salt...),
+		params: params,
+	}, nil
+}
+
+// Add adds the data to the ring.
+func (h *HardenedBloom) Add(data []byte) {
+	h.r.Add(h.derive(data))
+}
+
+// Test returns a bool if the data is in the ring. True indicates that the data
+// may be in the ring, while false indicates that the data is not in the ring.
+func (h *HardenedBloom) Test(data []byte) bool {
+	return h.r.Test(h.derive(data))
+}
+
+// Bloom returns the ring, for marshaling or inspection.
+func (h *HardenedBloom) Bloom() *Bloom {
+	return h.r
+}
+
+// derive returns the key scrypt derives from the data.
+func (h *HardenedBloom) derive(data []byte) []byte {
+	return scrypt(data, h.salt, h.params, hardenedKeySize)
+}
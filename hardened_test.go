@@ -0,0 +1,34 @@
+package ring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestHardenedBloom ensures a hardened ring holds its data, places it by the
+// salt, and rejects bad parameters.
+func TestHardenedBloom(t *testing.T) {
+	params := ScryptParams{N: 16, R: 1, P: 1}
+	salt := []byte("0123456789abcdef")
+	r, _ := Init(100, fpRate)
+	h, err := NewHardened(r, salt, params)
+	require.NoError(t, err)
+	h.Add([]byte("+15555550100"))
+	require.True(t, h.Test([]byte("+15555550100")))
+	require.False(t, h.Test([]byte("+15555550101")))
+	require.False(t, r.Test([]byte("+15555550100")))
+	require.Equal(t, r, h.Bloom())
+
+	other, _ := NewHardened(r.Clone(), []byte("fedcba9876543210"), params)
+	require.False(t, other.Test([]byte("+15555550100")))
+
+	_, err = NewHardened(r, salt[:15], params)
+	require.Equal(t, errSalt, err)
+	for _, bad := range []ScryptParams{{N: 0, R: 1, P: 1}, {N: 15, R: 1, P: 1},
+		{N: 16, R: 0, P: 1}, {N: 16, R: 1, P: 0}, {N: 1 << 25, R: 1, P: 1}} {
+		_, err = NewHardened(r, salt, bad)
+		require.Equal(t, errScryptParams, err, "%+v", bad)
+	}
+	require.True(t, DefaultScryptParams.valid())
+}
@@ -4,6 +4,8 @@
 
 package ring
 
+import "unsafe"
+
 const (
 	// 128-bit MurmurHash3 constants
 	murmur64c1 uint64 = 0x87c37b91114253d5
@@ -17,11 +19,45 @@ const (
 	single byte = byte(1)
 )
 
+// murmurState is the running state of a 128-bit MurmurHash3 hash. Unlike the
+// reference implementation, k1 and k2 carry over from the last block into the
+// tail, which changes the output for inputs of 16 or more bytes that are not a
+// multiple of 16. This must be kept for compatibility with existing filters.
+type murmurState struct {
+	h1, h2, k1, k2 uint64
+}
+
 // murmur128 returns two 64-bit outputs of a 128-bit MurmurHash3 hash.
-func murmur128(data []byte) (uint64, uint64) {
-	var h1, h2, k1, k2 uint64
-	length := len(data)
-	blocks := length / 16
+func murmur128(data []byte, seed uint32) (uint64, uint64) {
+	blocks := len(data) / 16
+	s := murmurState{h1: uint64(seed), h2: uint64(seed)}
+	s.blocks(data[:blocks*16])
+	return s.tail(data[blocks*16:], len(data))
+}
+
+// murmur128Suffix returns murmur128 of the data followed by the suffix byte,
+// without copying the data.
+func murmur128Suffix(data []byte, seed uint32, suffix byte) (uint64, uint64) {
+	blocks := len(data) / 16
+	s := murmurState{h1: uint64(seed), h2: uint64(seed)}
+	s.blocks(data[:blocks*16])
+
+	var tail [16]byte
+	n := copy(tail[:], data[blocks*16:])
+	tail[n] = suffix
+	n++
+	// the suffix may complete a final block
+	if n == 16 {
+		s.blocks(tail[:])
+		n = 0
+	}
+	return s.tail(tail[:n], len(data)+1)
+}
+
+// blocks mixes each 16-byte block of data into the state.
+func (s *murmurState) blocks(data []byte) {
+	h1, h2, k1, k2 := s.h1, s.h2, s.k1, s.k2
+	blocks := len(data) / 16
 
 	for i := 0; i < blocks; i++ {
 		k1 = bytesToUint64(data[i*16:])
@@ -45,29 +81,34 @@ func murmur128(data []byte) (uint64, uint64) {
 		h2 += h1
 		h2 = h2*5 + murmur64c4
 	}
+	s.h1, s.h2, s.k1, s.k2 = h1, h2, k1, k2
+}
 
-	tail := blocks * 16
-	switch length & 15 {
+// tail mixes the final partial block of fewer than 16 bytes into the state and
+// returns the finalized hash, given the total length hashed.
+func (s *murmurState) tail(tail []byte, length int) (uint64, uint64) {
+	h1, h2, k1, k2 := s.h1, s.h2, s.k1, s.k2
+	switch len(tail) {
 	case 15:
-		k2 ^= uint64(data[tail+14]) << 48
+		k2 ^= uint64(tail[14]) << 48
 		fallthrough
 	case 14:
-		k2 ^= uint64(data[tail+13]) << 40
+		k2 ^= uint64(tail[13]) << 40
 		fallthrough
 	case 13:
-		k2 ^= uint64(data[tail+12]) << 32
+		k2 ^= uint64(tail[12]) << 32
 		fallthrough
 	case 12:
-		k2 ^= uint64(data[tail+11]) << 24
+		k2 ^= uint64(tail[11]) << 24
 		fallthrough
 	case 11:
-		k2 ^= uint64(data[tail+10]) << 16
+		k2 ^= uint64(tail[10]) << 16
 		fallthrough
 	case 10:
-		k2 ^= uint64(data[tail+9]) << 8
+		k2 ^= uint64(tail[9]) << 8
 		fallthrough
 	case 9:
-		k2 ^= uint64(data[tail+8])
+		k2 ^= uint64(tail[8])
 		k2 *= murmur64c2
 		k2 = (k2 << 33) | (k2 >> (64 - 33))
 		k2 *= murmur64c1
@@ -75,28 +116,28 @@ func murmur128(data []byte) (uint64, uint64) {
 		fallthrough
 
 	case 8:
-		k1 ^= uint64(data[tail+7]) << 56
+		k1 ^= uint64(tail[7]) << 56
 		fallthrough
 	case 7:
-		k1 ^= uint64(data[tail+6]) << 48
+		k1 ^= uint64(tail[6]) << 48
 		fallthrough
 	case 6:
-		k1 ^= uint64(data[tail+5]) << 40
+		k1 ^= uint64(tail[5]) << 40
 		fallthrough
 	case 5:
-		k1 ^= uint64(data[tail+4]) << 32
+		k1 ^= uint64(tail[4]) << 32
 		fallthrough
 	case 4:
-		k1 ^= uint64(data[tail+3]) << 24
+		k1 ^= uint64(tail[3]) << 24
 		fallthrough
 	case 3:
-		k1 ^= uint64(data[tail+2]) << 16
+		k1 ^= uint64(tail[2]) << 16
 		fallthrough
 	case 2:
-		k1 ^= uint64(data[tail+1]) << 8
+		k1 ^= uint64(tail[1]) << 8
 		fallthrough
 	case 1:
-		k1 ^= uint64(data[tail])
+		k1 ^= uint64(tail[0])
 		k1 *= murmur64c1
 		k1 = (k1 << 31) | (k1 >> (64 - 31))
 		k1 *= murmur64c2
@@ -137,12 +178,9 @@ func bytesToUint64(b []byte) uint64 {
 }
 
 // generateMultihash returns 4 64-bit (2 x 128-bit) MurmurHash3 hashes.
-func generateMultiHash(data []byte) [4]uint64 {
-	h1, h2 := murmur128(data)
-	buff := make([]byte, len(data)+1)
-	copy(buff, data)
-	buff[len(data)] = single
-	h3, h4 := murmur128(buff)
+func generateMultiHash(data []byte, seed uint32) [4]uint64 {
+	h1, h2 := murmur128(data, seed)
+	h3, h4 := murmur128Suffix(data, seed, single)
 	return [4]uint64{h1, h2, h3, h4}
 }
 
@@ -154,3 +192,61 @@ func getRound(hash [4]uint64, n uint64) uint64 {
 	post := n * hash[index]
 	return pre + post
 }
+
+// ShardFor returns the shard in [0, shards) that the data belongs to, so that
+// producers and consumers agree on where an element lives. The shard is taken
+// from the second half of the hash, mixed, rather than the first probe of the
+// filters, so that the elements of a shard still probe every residue of its
+// ring. It panics if shards is not greater than 0.
+func ShardFor(data []byte, shards int) int {
+	if shards <= 0 {
+		panic("error: shards must be greater than 0")
+	}
+	_, h2 := murmur128(data, 0)
+	return int(fmix(h2) % uint64(shards))
+}
+
+// ProbePositions returns the k bit positions in a filter of m bits that data
+// is set at, in probe order. It is the reference for ports of the filter:
+//
+//	h1, h2 = MurmurHash3_x64_128(data, seed)
+//	h3, h4 = MurmurHash3_x64_128(data || 0x01, seed)
+//	position(n) = (h[n%2] + n*h[2+(((n+(n%2))%4)/2)]) mod m
+//
+// Note that MurmurHash3_x64_128 here does not reset k1 and k2 to 0 before
+// mixing the tail, instead continuing from their values after the last 16-byte
+// block. All arithmetic is on unsigned 64-bit integers and wraps on overflow.
+// Bit p of the filter is bit p%8 of byte p/8 of the bit array. The vectors in
+// testdata/vectors.json are generated from this function and MarshalBinary.
+// It panics if m is not greater than 0.
+func ProbePositions(seed uint32, k, m uint64, data []byte) []uint64 {
+	if m == 0 {
+		panic("error: m must be greater than 0")
+	}
+	hash := generateMultiHash(data, seed)
+	positions := make([]uint64, k)
+	for i := uint64(0); i < k; i++ {
+		positions[i] = getRound(hash, i) % m
+	}
+	return positions
+}
+
+// Sum128 returns the two 64-bit outputs of the MurmurHash3 hash of the data
+// that rings derive their first probe positions from, as described by
+// ProbePositions. It lets the filters of the subpackages hash data the same
+// way as rings do.
+func Sum128(data []byte, seed uint32) (uint64, uint64) {
+	return murmur128(data, seed)
+}
+
+// stringBytes returns the bytes of s without copying them. The result must not
+// be modified or retained.
+func stringBytes(s string) []byte {
+	if len(s) == 0 {
+		return nil
+	}
+	return *(*[]byte)(unsafe.Pointer(&struct {
+		string
+		int
+	}{s, len(s)}))
+}
@@ -1,13 +1,33 @@
 package ring
 
-import "testing"
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
 
 func BenchmarkGenerateMultiHash(b *testing.B) {
 	data := []byte{0x00, 0x12, 0x34, 0x56, 0x78, 0x00}
 	buff := make([]byte, len(data))
 	for i := 0; i < b.N; i++ {
 		copy(buff, data)
-		generateMultiHash(buff[1:5])
+		generateMultiHash(buff[1:5], 0)
+	}
+}
+
+func BenchmarkHashFamily(b *testing.B) {
+	data := []byte("an element of typical length")
+	for _, family := range []HashFamily{HashMurmur3, HashSipHash, HashWyhash} {
+		h := hashing{family: family}
+		b.Run(family.String(), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				h.sum(data)
+			}
+		})
 	}
 }
 
@@ -27,7 +47,7 @@ func TestGenerateMultiHash(t *testing.T) {
 	}
 	buff := make([]byte, len(data))
 	copy(buff, data)
-	generateMultiHash(buff[1:20])
+	generateMultiHash(buff[1:20], 0)
 
 	for i := range data {
 		if data[i] != buff[i] {
@@ -35,3 +55,203 @@ func TestGenerateMultiHash(t *testing.T) {
 		}
 	}
 }
+
+func TestShardFor(t *testing.T) {
+	data := []byte{0x00, 0x12, 0x34, 0x56, 0x78, 0x00}
+	counts := make([]int, 7)
+	buff := make([]byte, 4)
+	for i := 0; i < 7000; i++ {
+		intToByte(buff, i)
+		shard := ShardFor(buff, len(counts))
+		if shard < 0 || shard >= len(counts) {
+			t.Fatalf("shard out of range: %v", shard)
+		}
+		counts[shard]++
+	}
+	for i, count := range counts {
+		if count < 800 || count > 1200 {
+			t.Errorf("shard %v is unbalanced: %v", i, count)
+		}
+	}
+
+	if ShardFor(data, 13) != ShardFor(data, 13) {
+		t.Fatal("shard assignment is not deterministic")
+	}
+	if ShardFor(data, 1) != 0 {
+		t.Fatal("single shard must always be 0")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("shards <= 0 not captured")
+		}
+	}()
+	ShardFor(data, 0)
+}
+
+// TestShardFor_Independent ensures the elements of a shard spread their first
+// probe over every residue of a ring whose size shares a factor with the
+// number of shards.
+func TestShardFor_Independent(t *testing.T) {
+	const shards, m = 4, 1024
+	counts := make([]int, shards)
+	buff := make([]byte, 4)
+	for i := 0; i < 16000; i++ {
+		intToByte(buff, i)
+		if ShardFor(buff, shards) != 0 {
+			continue
+		}
+		counts[ProbePositions(0, 1, m, buff)[0]%shards]++
+	}
+	for i, count := range counts {
+		if count < 800 || count > 1200 {
+			t.Errorf("residue %v of shard 0 is unbalanced: %v", i, count)
+		}
+	}
+}
+
+var update = flag.Bool("update", false, "regenerate testdata/vectors.json")
+
+// vectorsFile contains the conformance vectors for ports of the filter.
+const vectorsFile = "testdata/vectors.json"
+
+// probeVector is the expected output of ProbePositions.
+type probeVector struct {
+	Seed      uint32   `json:"seed"`
+	K         uint64   `json:"k"`
+	M         uint64   `json:"m"`
+	Data      string   `json:"data"`
+	Positions []uint64 `json:"positions"`
+}
+
+// encodingVector is the expected output of MarshalBinary after the elements
+// have been added to a filter of the given size and hash rounds.
+type encodingVector struct {
+	Size     uint64   `json:"size"`
+	Hash     uint64   `json:"hash"`
+	Elements []string `json:"elements"`
+	Binary   string   `json:"binary"`
+}
+
+type vectors struct {
+	Probes    []probeVector    `json:"probes"`
+	Encodings []encodingVector `json:"encodings"`
+}
+
+// generateVectors builds the conformance vectors from the implementation.
+func generateVectors(t *testing.T) vectors {
+	var v vectors
+	// cover every tail length of the hash and a range of parameters
+	for length := 0; length <= 40; length++ {
+		data := make([]byte, length)
+		for i := range data {
+			data[i] = byte(i*31 + length)
+		}
+		for _, seed := range []uint32{0, 1, 0xdeadbeef} {
+			k := uint64(length%10 + 1)
+			m := uint64(length*97 + 8)
+			v.Probes = append(v.Probes, probeVector{
+				Seed:      seed,
+				K:         k,
+				M:         m,
+				Data:      hex.EncodeToString(data),
+				Positions: ProbePositions(seed, k, m, data),
+			})
+		}
+	}
+
+	for _, params := range [][2]uint64{{8, 1}, {61, 3}, {200, 7}, {1021, 10}} {
+		r, err := InitByParameters(params[0], params[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		enc := encodingVector{Size: params[0], Hash: params[1]}
+		for i := 0; i < int(params[0]/20)+1; i++ {
+			el := []byte(fmt.Sprintf("element-%d", i))
+			r.Add(el)
+			enc.Elements = append(enc.Elements, hex.EncodeToString(el))
+		}
+		out, err := r.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		enc.Binary = hex.EncodeToString(out)
+		v.Encodings = append(v.Encodings, enc)
+	}
+	return v
+}
+
+// TestConformanceVectors ensures the implementation matches the vectors
+// shipped for ports. Run with -update to regenerate them.
+func TestConformanceVectors(t *testing.T) {
+	generated := generateVectors(t)
+	if *update {
+		out, err := json.MarshalIndent(generated, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(vectorsFile, append(out, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(vectorsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var shipped vectors
+	if err = json.Unmarshal(data, &shipped); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(generated, shipped) {
+		t.Fatalf("implementation does not match %s", vectorsFile)
+	}
+
+	// the bits of each encoding must be exactly the probe positions
+	for i, enc := range shipped.Encodings {
+		bits := make([]byte, getBuffSize(enc.Size))
+		for _, el := range enc.Elements {
+			raw, _ := hex.DecodeString(el)
+			for _, p := range ProbePositions(0, enc.Hash, enc.Size, raw) {
+				bits[p/8] |= 1 << (p % 8)
+			}
+		}
+		if hex.EncodeToString(bits) != enc.Binary[headerSize*2:] {
+			t.Errorf("encoding %d does not match probe positions", i)
+		}
+	}
+}
+
+func TestProbePositions(t *testing.T) {
+	data := []byte{0x00, 0x12, 0x34, 0x56, 0x78, 0x00}
+	r, _ := InitByParameters(1000, 5)
+	r.Add(data)
+	positions := ProbePositions(0, 5, 1000, data)
+	if len(positions) != 5 {
+		t.Fatalf("unexpected number of positions: %v", len(positions))
+	}
+	for _, p := range positions {
+		if r.bits[p/8]&(1<<(p%8)) == 0 {
+			t.Fatalf("position %v not set by Add", p)
+		}
+	}
+	if reflect.DeepEqual(positions, ProbePositions(1, 5, 1000, data)) {
+		t.Fatal("seed does not change positions")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("m <= 0 not captured")
+		}
+	}()
+	ProbePositions(0, 5, 0, data)
+}
+
+func TestSum128(t *testing.T) {
+	data := []byte("sum")
+	h1, h2 := Sum128(data, 3)
+	hash := generateMultiHash(data, 3)
+	if h1 != hash[0] || h2 != hash[1] {
+		t.Fatalf("Sum128 does not match the ring hash: %x %x", h1, h2)
+	}
+}
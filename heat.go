@@ -0,0 +1,81 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import "time"
+
+const (
+	// heatBucketWidth is the span of time covered by each write heat bucket.
+	heatBucketWidth = time.Minute
+	// heatBuckets is the number of buckets kept, covering the last hour.
+	heatBuckets = 60
+)
+
+// heatSlot is the number of bits changed during one bucket of time.
+type heatSlot struct {
+	epoch int64 // index of the bucket since the Unix epoch
+	bits  uint64
+}
+
+// HeatBucket is the number of bits of the ring that changed during a minute.
+type HeatBucket struct {
+	Start   time.Time // start of the minute
+	Changed uint64    // number of bits set or cleared
+}
+
+// WriteHeat returns the number of bits changed by each minute of the last
+// hour, oldest first and ending with the current minute. A ring whose recent
+// buckets are all zero has gone cold: its writes only repeat existing
+// elements, if it is written at all, making it a candidate to be frozen or
+// offloaded. Bits changed by Add, Merge, Intersect, and similar operations are
+// counted, but not those replaced wholesale by Reset or unmarshaling.
+func (r *Bloom) WriteHeat() []HeatBucket {
+	r.mutex.Lock()
+	defer r.writeUnlock()
+	now := time.Now()
+	current := heatEpoch(now)
+	heat := make([]HeatBucket, heatBuckets)
+	for i := range heat {
+		epoch := current - heatBuckets + 1 + int64(i)
+		heat[i].Start = time.Unix(0, epoch*int64(heatBucketWidth))
+		if slot := r.heat[epoch%heatBuckets]; slot.epoch == epoch {
+			heat[i].Changed = slot.bits
+		}
+	}
+	// bits changed since the last write lock was released are in the current
+	// bucket
+	heat[heatBuckets-1].Changed += r.unrecorded
+	return heat
+}
+
+// writeUnlock records the bits changed under the write lock in the current
+// heat bucket and releases the lock, then calls any saturation callbacks the
+// changes triggered. It must be used in place of Unlock by any operation that
+// may change bits.
+func (r *Bloom) writeUnlock() {
+	if r.unrecorded > 0 {
+		epoch := heatEpoch(time.Now())
+		slot := &r.heat[epoch%heatBuckets]
+		if slot.epoch != epoch {
+			*slot = heatSlot{epoch: epoch}
+		}
+		slot.bits += r.unrecorded
+		r.unrecorded = 0
+	}
+	if len(r.saturation) == 0 {
+		r.mutex.Unlock()
+		return
+	}
+	fill, triggered := r.checkSaturation()
+	r.mutex.Unlock()
+	for _, fn := range triggered {
+		fn(fill)
+	}
+}
+
+// heatEpoch returns the index of the heat bucket holding t.
+func heatEpoch(t time.Time) int64 {
+	return t.UnixNano() / int64(heatBucketWidth)
+}
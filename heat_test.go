@@ -0,0 +1,61 @@
+package ring
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestBloom_WriteHeat ensures changed bits are counted in the current bucket
+// and repeated writes are not.
+func TestBloom_WriteHeat(t *testing.T) {
+	r, _ := Init(1000, 0.01)
+	heat := r.WriteHeat()
+	require.Len(t, heat, heatBuckets)
+	for _, b := range heat {
+		require.Zero(t, b.Changed)
+	}
+	last := heat[heatBuckets-1].Start
+	require.False(t, last.After(time.Now()))
+	require.True(t, time.Since(last) < heatBucketWidth)
+	require.Equal(t, heatBucketWidth, heat[1].Start.Sub(heat[0].Start))
+
+	buff := make([]byte, 4)
+	for i := 0; i < 100; i++ {
+		intToByte(buff, i)
+		r.Add(buff)
+	}
+	set := countBits(r.bits)
+	heat = r.WriteHeat()
+	// the minute may have rolled over during the Adds
+	require.Equal(t, set, heat[heatBuckets-1].Changed+heat[heatBuckets-2].Changed)
+
+	// adding the same elements again changes nothing
+	for i := 0; i < 100; i++ {
+		intToByte(buff, i)
+		r.Add(buff)
+	}
+	heat2 := r.WriteHeat()
+	require.Equal(t, heat[heatBuckets-1].Changed+heat[heatBuckets-2].Changed,
+		heat2[heatBuckets-1].Changed+heat2[heatBuckets-2].Changed)
+
+	m, _ := Init(1000, 0.01)
+	m.Add([]byte("merged"))
+	require.NoError(t, r.Intersect(m))
+	heat3 := r.WriteHeat()
+	cleared := set - countBits(r.bits)
+	require.Equal(t, set+cleared,
+		heat3[heatBuckets-1].Changed+heat3[heatBuckets-2].Changed)
+}
+
+// TestBloom_WriteHeatOld ensures buckets older than the window are not
+// reported.
+func TestBloom_WriteHeatOld(t *testing.T) {
+	r, _ := Init(1000, 0.01)
+	old := heatEpoch(time.Now()) - heatBuckets
+	r.heat[old%heatBuckets] = heatSlot{epoch: old, bits: 10}
+	for _, b := range r.WriteHeat() {
+		require.Zero(t, b.Changed)
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+)
+
+var errThreshold = errors.New("error: threshold must not be negative")
+
+// hybridHeaderSize is the number of bytes preceding the members or ring in
+// the output of HybridSet.MarshalBinary: 1 byte of version, 1 byte of mode,
+// and 8 bytes each of threshold, elements and falsePositive.
+const hybridHeaderSize = 26
+
+// The modes of a HybridSet, as marshaled.
+const (
+	hybridExact = 0
+	hybridBloom = 1
+)
+
+// HybridSet is a set that stores its members exactly while there are at most
+// threshold of them, and converts itself to a ring once there are more, so
+// that small sets neither take the memory of a full ring nor have false
+// positives. The conversion happens within Add, and cannot be undone.
+type HybridSet struct {
+	threshold     int
+	elements      int
+	falsePositive float64
+	opts          []Option
+	mutex         *sync.RWMutex
+	members       map[string]struct{} // members, until converted
+	ring          *Bloom              // ring, once converted
+}
+
+// NewHybrid initializes and returns a new hybrid set, or an error. Once it
+// has more than threshold members it converts itself to a ring initialized
+// as Init would for the elements, falsePositive rate and options, which are
+// checked now.
+func NewHybrid(threshold, elements int, falsePositive float64, opts ...Option) (*HybridSet, error) {
+	if threshold < 0 {
+		return nil, errThreshold
+	}
+	if elements <= 0 {
+		return nil, errElements
+	}
+	if falsePositive <= 0 || falsePositive >= 1 {
+		return nil, errFalsePositive
+	}
+	check := Bloom{}
+	check.size, check.hash = optimalParameters(elements, falsePositive)
+	if err := check.applyOptions(opts); err != nil {
+		return nil, err
+	}
+
+	return &HybridSet{
+		threshold:     threshold,
+		elements:      elements,
+		falsePositive: falsePositive,
+		opts:          opts,
+		mutex:         &sync.RWMutex{},
+		members:       map[string]struct{}{},
+	}, nil
+}
+
+// Add adds the data to the set, converting it to a ring if it then has more
+// than threshold members.
+func (h *HybridSet) Add(data []byte) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.ring != nil {
+		h.ring.Add(data)
+		return
+	}
+	h.members[string(data)] = struct{}{}
+	if len(h.members) > h.threshold {
+		h.convert()
+	}
+}
+
+// Test returns a bool if the data is in the set. While the set is exact,
+// true indicates that the data has been added; once it is a ring, true
+// indicates that it may have been added. False always indicates that the
+// data has not been added.
+func (h *HybridSet) Test(data []byte) bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if h.ring != nil {
+		return h.ring.Test(data)
+	}
+	_, ok := h.members[string(data)]
+	return ok
+}
+
+// IsExact returns true if the set stores its members exactly, and has not
+// been converted to a ring.
+func (h *HybridSet) IsExact() bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.ring == nil
+}
+
+// Bloom returns the ring the set has been converted to, or nil if it is
+// exact. The ring is shared with the set.
+func (h *HybridSet) Bloom() *Bloom {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.ring
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface. The output
+// is 1 byte of version, 1 byte of mode, 0 if exact and 1 if converted, the
+// threshold, elements and falsePositive as 8 bytes each, then either the
+// number of members as 8 bytes followed by each member preceded by its length
+// as 4 bytes, or the output of MarshalBinary for the ring. Integers are big
+// endian, and falsePositive is an IEEE 754 double. Members are in no
+// particular order.
+func (h *HybridSet) MarshalBinary() ([]byte, error) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	out := make([]byte, hybridHeaderSize, hybridHeaderSize+8)
+	out[0] = 1
+	binary.BigEndian.PutUint64(out[2:10], uint64(h.threshold))
+	binary.BigEndian.PutUint64(out[10:18], uint64(h.elements))
+	binary.BigEndian.PutUint64(out[18:26], math.Float64bits(h.falsePositive))
+	if h.ring != nil {
+		out[1] = hybridBloom
+		return h.ring.AppendBinary(out)
+	}
+
+	out[1] = hybridExact
+	var buff [8]byte
+	binary.BigEndian.PutUint64(buff[:], uint64(len(h.members)))
+	out = append(out, buff[:]...)
+	for member := range h.members {
+		binary.BigEndian.PutUint32(buff[:4], uint32(len(member)))
+		out = append(append(out, buff[:4]...), member...)
+	}
+	return out, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface. A
+// converted set takes its options from the ring, while an exact one keeps the
+// options of the receiver, none for a new HybridSet, to convert with later.
+// A keyed ring takes its key from the options of the receiver, so it must be
+// unmarshaled into a set made by NewHybrid WithKey.
+func (h *HybridSet) UnmarshalBinary(data []byte) error {
+	if len(data) < hybridHeaderSize {
+		return fmt.Errorf("incorrect length: %d", len(data))
+	}
+	if data[0] != 1 {
+		return fmt.Errorf("unexpected version: %d", data[0])
+	}
+	threshold := binary.BigEndian.Uint64(data[2:10])
+	elements := binary.BigEndian.Uint64(data[10:18])
+	falsePositive := math.Float64frombits(binary.BigEndian.Uint64(data[18:26]))
+	if threshold > math.MaxInt32 {
+		return errThreshold
+	}
+	if elements == 0 || elements > math.MaxInt32 {
+		return errElements
+	}
+	if !(falsePositive > 0 && falsePositive < 1) {
+		return errFalsePositive
+	}
+
+	var members map[string]struct{}
+	var r *Bloom
+	rest := data[hybridHeaderSize:]
+	switch data[1] {
+	case hybridExact:
+		if len(rest) < 8 {
+			return fmt.Errorf("incorrect length: %d", len(data))
+		}
+		count := binary.BigEndian.Uint64(rest)
+		rest = rest[8:]
+		if count > uint64(len(rest))/4 || count > threshold {
+			return fmt.Errorf("invalid member count: %d", count)
+		}
+		members = make(map[string]struct{}, count)
+		for ; count > 0; count-- {
+			if len(rest) < 4 {
+				return fmt.Errorf("incorrect length: %d", len(data))
+			}
+			length := binary.BigEndian.Uint32(rest)
+			rest = rest[4:]
+			if uint64(length) > uint64(len(rest)) {
+				return fmt.Errorf("incorrect length: %d", len(data))
+			}
+			members[string(rest[:length])] = struct{}{}
+			rest = rest[length:]
+		}
+		if len(rest) != 0 {
+			return fmt.Errorf("incorrect length: %d", len(data))
+		}
+	case hybridBloom:
+		var err error
+		if r, err = unmarshalWith(rest, h.opts); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unexpected mode: %d", data[1])
+	}
+
+	if h.mutex == nil {
+		h.mutex = new(sync.RWMutex)
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.threshold = int(threshold)
+	h.elements = int(elements)
+	h.falsePositive = falsePositive
+	h.members = members
+	h.ring = r
+	return nil
+}
+
+// convert replaces the members with a ring holding them. The caller must
+// hold the write lock.
+func (h *HybridSet) convert() {
+	// the options were checked by NewHybrid
+	r, err := Init(h.elements, h.falsePositive, h.opts...)
+	if err != nil {
+		panic(err)
+	}
+	for member := range h.members {
+		r.Add([]byte(member))
+	}
+	h.ring = r
+	h.members = nil
+}
@@ -0,0 +1,109 @@
+package ring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestHybridSet ensures the set is exact up to its threshold, then converts to
+// a ring holding every member, and round trips in both modes.
+func TestHybridSet(t *testing.T) {
+	h, err := NewHybrid(10, 1000, fpRate, WithSeed(9))
+	require.NoError(t, err)
+	buff := make([]byte, 4)
+	for i := 0; i < 10; i++ {
+		intToByte(buff, i)
+		h.Add(buff)
+	}
+	require.True(t, h.IsExact())
+	require.Nil(t, h.Bloom())
+	for i := 0; i < 1000; i++ {
+		intToByte(buff, i)
+		require.Equal(t, i < 10, h.Test(buff), "element %d", i)
+	}
+
+	out, err := h.MarshalBinary()
+	require.NoError(t, err)
+	exact := new(HybridSet)
+	require.NoError(t, exact.UnmarshalBinary(out))
+	require.True(t, exact.IsExact())
+	for i := 0; i < 20; i++ {
+		intToByte(buff, i)
+		require.Equal(t, i < 10, exact.Test(buff), "element %d", i)
+	}
+
+	intToByte(buff, 10)
+	h.Add(buff)
+	require.False(t, h.IsExact())
+	require.Equal(t, uint32(9), h.Bloom().hashing.seed)
+	for i := 0; i <= 10; i++ {
+		intToByte(buff, i)
+		require.True(t, h.Test(buff), "element %d", i)
+	}
+
+	out, err = h.MarshalBinary()
+	require.NoError(t, err)
+	converted := new(HybridSet)
+	require.NoError(t, converted.UnmarshalBinary(out))
+	require.False(t, converted.IsExact())
+	require.True(t, h.Bloom().Equal(converted.Bloom()))
+
+	// a set unmarshaled exact converts when it passes the threshold
+	for i := 10; i < 12; i++ {
+		intToByte(buff, i)
+		exact.Add(buff)
+	}
+	require.False(t, exact.IsExact())
+	for i := 0; i < 12; i++ {
+		intToByte(buff, i)
+		require.True(t, exact.Test(buff), "element %d", i)
+	}
+}
+
+// TestHybridSet_Errors ensures bad parameters and data are rejected.
+func TestHybridSet_Errors(t *testing.T) {
+	_, err := NewHybrid(-1, 100, fpRate)
+	require.Equal(t, errThreshold, err)
+	_, err = NewHybrid(10, 0, fpRate)
+	require.Equal(t, errElements, err)
+	_, err = NewHybrid(10, 100, 0)
+	require.Equal(t, errFalsePositive, err)
+	_, err = NewHybrid(10, 100, fpRate, WithHash(HashFamily(9)))
+	require.Equal(t, errHashFamily, err)
+
+	h, _ := NewHybrid(10, 100, fpRate)
+	h.Add([]byte("member"))
+	out, _ := h.MarshalBinary()
+	u := new(HybridSet)
+	require.Error(t, u.UnmarshalBinary(out[:len(out)-1]))
+	require.Error(t, u.UnmarshalBinary(append(out, 0)))
+	require.Error(t, u.UnmarshalBinary(out[:hybridHeaderSize-1]))
+	for _, i := range []int{0, 1} {
+		bad := append([]byte{}, out...)
+		bad[i] = 2
+		require.Error(t, u.UnmarshalBinary(bad), "byte %d", i)
+	}
+	bad := append([]byte{}, out...)
+	bad[9] = 0
+	require.Error(t, u.UnmarshalBinary(bad))
+}
+
+// TestHybridSet_Keyed ensures a converted keyed set is unmarshaled into a set
+// made with its key.
+func TestHybridSet_Keyed(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	h, err := NewHybrid(1, 1000, 0.01, WithKey(key))
+	require.NoError(t, err)
+	h.Add([]byte("a"))
+	h.Add([]byte("b"))
+	out, err := h.MarshalBinary()
+	require.NoError(t, err)
+
+	require.Equal(t, errNoKey, new(HybridSet).UnmarshalBinary(out))
+	u, err := NewHybrid(1, 1000, 0.01, WithKey(key))
+	require.NoError(t, err)
+	require.NoError(t, u.UnmarshalBinary(out))
+	require.True(t, u.Test([]byte("a")))
+	require.True(t, u.Test([]byte("b")))
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"sync"
+)
+
+var (
+	errPrecision      = errors.New("error: precision must be between 4 and 18")
+	errPrecisionMatch = errors.New("sketches must have the same precision")
+)
+
+const (
+	// minPrecision and maxPrecision bound the precision of a HyperLogLog.
+	minPrecision = 4
+	maxPrecision = 18
+	// hllHeaderSize is the number of bytes preceding the registers in the
+	// output of HyperLogLog.MarshalBinary: 1 byte each of version and
+	// precision.
+	hllHeaderSize = 2
+)
+
+// HyperLogLog estimates the number of distinct elements added to it, in
+// 2^precision bytes. Each element is hashed as a ring hashes it; the first
+// precision bits of the hash choose a register, which keeps the longest run
+// of leading zeros seen in the rest. The estimate has a standard error of
+// about 1.04/sqrt(2^precision).
+type HyperLogLog struct {
+	precision uint8
+	registers []uint8
+	mutex     *sync.RWMutex
+}
+
+// InitHyperLogLog initializes and returns a new sketch, or an error if the
+// precision is not between 4 and 18.
+func InitHyperLogLog(precision uint8) (*HyperLogLog, error) {
+	if precision < minPrecision || precision > maxPrecision {
+		return nil, errPrecision
+	}
+	return &HyperLogLog{
+		precision: precision,
+		registers: make([]uint8, 1<<precision),
+		mutex:     &sync.RWMutex{},
+	}, nil
+}
+
+// Add adds the data to the sketch.
+func (h *HyperLogLog) Add(data []byte) {
+	hash, _ := murmur128(data, 0)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	index := hash >> (64 - h.precision)
+	// the sentinel bit caps the run at 64-precision zeros
+	rest := hash<<h.precision | 1<<(h.precision-1)
+	if rank := uint8(bits.LeadingZeros64(rest)) + 1; rank > h.registers[index] {
+		h.registers[index] = rank
+	}
+}
+
+// Estimate returns an estimate of the number of distinct elements added to
+// the sketch. Small counts are estimated from the number of empty registers,
+// which is more accurate while many registers are empty.
+func (h *HyperLogLog) Estimate() uint64 {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	m := float64(len(h.registers))
+	sum, zeros := 0.0, 0
+	for _, rank := range h.registers {
+		sum += math.Exp2(-float64(rank))
+		if rank == 0 {
+			zeros++
+		}
+	}
+	estimate := hllAlpha(len(h.registers)) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// Merge adds the elements of other to the sketch, so that it estimates the
+// distinct elements of both. The sketches must have the same precision. Other
+// is read under its lock before the sketch is locked.
+func (h *HyperLogLog) Merge(other *HyperLogLog) error {
+	other.mutex.RLock()
+	precision := other.precision
+	registers := append([]uint8{}, other.registers...)
+	other.mutex.RUnlock()
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.precision != precision {
+		return errPrecisionMatch
+	}
+	for i, rank := range registers {
+		if rank > h.registers[i] {
+			h.registers[i] = rank
+		}
+	}
+	return nil
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface. The output
+// is 1 byte of version and 1 byte of precision, then a byte for each
+// register.
+func (h *HyperLogLog) MarshalBinary() ([]byte, error) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	out := make([]byte, hllHeaderSize+len(h.registers))
+	out[0] = 1
+	out[1] = h.precision
+	copy(out[hllHeaderSize:], h.registers)
+	return out, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (h *HyperLogLog) UnmarshalBinary(data []byte) error {
+	if len(data) < hllHeaderSize {
+		return fmt.Errorf("incorrect length: %d", len(data))
+	}
+	if data[0] != 1 {
+		return fmt.Errorf("unexpected version: %d", data[0])
+	}
+	precision := data[1]
+	if precision < minPrecision || precision > maxPrecision {
+		return errPrecision
+	}
+	if len(data)-hllHeaderSize != 1<<precision {
+		return fmt.Errorf("incorrect length: %d", len(data))
+	}
+	for _, rank := range data[hllHeaderSize:] {
+		if rank > 65-precision {
+			return fmt.Errorf("invalid register: %d", rank)
+		}
+	}
+
+	if h.mutex == nil {
+		h.mutex = new(sync.RWMutex)
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.precision = precision
+	h.registers = append([]uint8{}, data[hllHeaderSize:]...)
+	return nil
+}
+
+// hllAlpha returns the bias correction of a sketch with m registers.
+func hllAlpha(m int) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	default:
+		return 0.7213 / (1 + 1.079/float64(m))
+	}
+}
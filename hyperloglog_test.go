@@ -0,0 +1,81 @@
+package ring
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestHyperLogLog ensures estimates are within a few standard errors across
+// small and large counts, and repeated data is not counted twice.
+func TestHyperLogLog(t *testing.T) {
+	h, err := InitHyperLogLog(14)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), h.Estimate())
+	buff := make([]byte, 4)
+	added := 0
+	for _, n := range []int{10, 1000, 100000, 1000000} {
+		for ; added < n; added++ {
+			intToByte(buff, added)
+			h.Add(buff)
+			h.Add(buff)
+		}
+		// three standard errors
+		tolerance := 3 * 1.04 / math.Sqrt(1<<14)
+		require.InEpsilon(t, n, h.Estimate(), tolerance, "n %d", n)
+	}
+
+	_, err = InitHyperLogLog(3)
+	require.Equal(t, errPrecision, err)
+	_, err = InitHyperLogLog(19)
+	require.Equal(t, errPrecision, err)
+}
+
+// TestHyperLogLog_Merge ensures merged sketches estimate the union, and
+// sketches of different precisions are rejected.
+func TestHyperLogLog_Merge(t *testing.T) {
+	a, _ := InitHyperLogLog(12)
+	b, _ := InitHyperLogLog(12)
+	buff := make([]byte, 4)
+	for i := 0; i < 30000; i++ {
+		intToByte(buff, i)
+		if i < 20000 {
+			a.Add(buff)
+		}
+		if i >= 10000 {
+			b.Add(buff)
+		}
+	}
+	require.NoError(t, a.Merge(b))
+	require.InEpsilon(t, 30000, a.Estimate(), 0.05)
+
+	c, _ := InitHyperLogLog(10)
+	require.Equal(t, errPrecisionMatch, a.Merge(c))
+}
+
+// TestHyperLogLog_Marshal ensures the sketch round trips and bad data is
+// rejected.
+func TestHyperLogLog_Marshal(t *testing.T) {
+	h, _ := InitHyperLogLog(4)
+	for i := 0; i < 5; i++ {
+		h.Add([]byte{uint8(i)})
+	}
+	out, err := h.MarshalBinary()
+	require.NoError(t, err)
+
+	u := new(HyperLogLog)
+	require.NoError(t, u.UnmarshalBinary(out))
+	require.Equal(t, h.Estimate(), u.Estimate())
+	require.NoError(t, u.Merge(h))
+
+	require.Error(t, u.UnmarshalBinary(out[:len(out)-1]))
+	require.Error(t, u.UnmarshalBinary(out[:1]))
+	bad := append([]byte{}, out...)
+	bad[0] = 2
+	require.Error(t, u.UnmarshalBinary(bad))
+	bad[0], bad[1] = 1, 5
+	require.Error(t, u.UnmarshalBinary(bad))
+	bad[1], bad[2] = 4, 62
+	require.Error(t, u.UnmarshalBinary(bad))
+}
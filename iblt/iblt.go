@@ -0,0 +1,226 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package iblt provides a thread safe invertible bloom lookup table. Two peers
+// each build a table of their set, exchange them, and subtract one from the
+// other. As long as the sets differ in somewhat fewer keys than the tables
+// have cells, the difference lists exactly which keys each side is missing,
+// at a cost proportional to the difference rather than the sets.
+package iblt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	errCells      = errors.New("error: cells must be greater than 0")
+	errParameters = errors.New("tables must have the same number of cells")
+	errIncomplete = errors.New("error: the table is too full to list every entry")
+)
+
+const (
+	// hashes is the number of cells each key is stored in, one per
+	// subtable.
+	hashes = 3
+	// cellSize is the number of bytes of a marshaled cell: 4 bytes of count
+	// and 8 bytes each of key sum and hash sum.
+	cellSize = 20
+	// headerSize is the number of bytes preceding the cells in the output of
+	// MarshalBinary: 1 byte of version and 8 bytes of cells per subtable.
+	headerSize = 9
+)
+
+// IBLT is an invertible bloom lookup table over 64-bit keys. Each key is
+// added to one cell in each of three subtables, which count the keys and XOR
+// together the keys and a check hash of each. A cell holding a single key
+// gives it back, and removing that key from its other cells frees more.
+type IBLT struct {
+	cells []cell // hashes subtables of equal length
+	mutex *sync.RWMutex
+}
+
+// cell is a cell of the table.
+type cell struct {
+	count   int32  // keys inserted less keys deleted
+	keySum  uint64 // XOR of the keys
+	hashSum uint64 // XOR of the check hashes of the keys
+}
+
+// New returns a new table of at least cells cells, or an error. To list a
+// difference of d keys with high probability, cells should be about 1.5d, and
+// at least 2d for small d.
+func New(cells int) (*IBLT, error) {
+	if cells <= 0 {
+		return nil, errCells
+	}
+	perTable := (cells + hashes - 1) / hashes
+	return &IBLT{
+		cells: make([]cell, perTable*hashes),
+		mutex: &sync.RWMutex{},
+	}, nil
+}
+
+// Insert adds the key to the table.
+func (t *IBLT) Insert(key uint64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.update(key, 1)
+}
+
+// Delete removes the key from the table. Deleting a key that was never
+// inserted records it as missing, so it is listed as removed by ListEntries.
+func (t *IBLT) Delete(key uint64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.update(key, -1)
+}
+
+// Subtract returns a new table holding the keys of the table less those of
+// other, which must have the same number of cells. Keys in both cancel, so
+// the result holds only the symmetric difference, with the keys of other
+// deleted. Other is read under its lock before the table is locked.
+func (t *IBLT) Subtract(other *IBLT) (*IBLT, error) {
+	other.mutex.RLock()
+	cells := append([]cell{}, other.cells...)
+	other.mutex.RUnlock()
+
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	if len(t.cells) != len(cells) {
+		return nil, errParameters
+	}
+	for i, c := range t.cells {
+		cells[i] = cell{
+			count:   c.count - cells[i].count,
+			keySum:  c.keySum ^ cells[i].keySum,
+			hashSum: c.hashSum ^ cells[i].hashSum,
+		}
+	}
+	return &IBLT{cells: cells, mutex: &sync.RWMutex{}}, nil
+}
+
+// ListEntries returns the keys inserted into the table and the keys deleted
+// from it, which for a table returned by Subtract are the keys only in the
+// first set and only in the second. It returns an error, along with the keys
+// found, if the table holds too many keys to list them all. The table is not
+// modified.
+func (t *IBLT) ListEntries() ([]uint64, []uint64, error) {
+	t.mutex.RLock()
+	work := &IBLT{cells: append([]cell{}, t.cells...)}
+	t.mutex.RUnlock()
+
+	var inserted, deleted []uint64
+	// peel pure cells until none are left, each peel possibly freeing others
+	for progress := true; progress; {
+		progress = false
+		for i := range work.cells {
+			c := work.cells[i]
+			if (c.count != 1 && c.count != -1) || c.hashSum != check(c.keySum) {
+				continue
+			}
+			if c.count == 1 {
+				inserted = append(inserted, c.keySum)
+			} else {
+				deleted = append(deleted, c.keySum)
+			}
+			work.update(c.keySum, -c.count)
+			progress = true
+		}
+	}
+	for _, c := range work.cells {
+		if c != (cell{}) {
+			return inserted, deleted, errIncomplete
+		}
+	}
+	return inserted, deleted, nil
+}
+
+// Cells returns the number of cells in the table.
+func (t *IBLT) Cells() int {
+	return len(t.cells)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface. The output
+// is 1 byte of version, the number of cells per subtable as 8 bytes, then
+// each cell as its count as 4 bytes and its key and hash sums as 8 bytes
+// each. Integers are big endian.
+func (t *IBLT) MarshalBinary() ([]byte, error) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	out := make([]byte, headerSize+cellSize*len(t.cells))
+	out[0] = 1
+	binary.BigEndian.PutUint64(out[1:9], uint64(len(t.cells)/hashes))
+	for i, c := range t.cells {
+		b := out[headerSize+cellSize*i:]
+		binary.BigEndian.PutUint32(b[0:4], uint32(c.count))
+		binary.BigEndian.PutUint64(b[4:12], c.keySum)
+		binary.BigEndian.PutUint64(b[12:20], c.hashSum)
+	}
+	return out, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (t *IBLT) UnmarshalBinary(data []byte) error {
+	if len(data) < headerSize {
+		return fmt.Errorf("incorrect length: %d", len(data))
+	}
+	if data[0] != 1 {
+		return fmt.Errorf("unexpected version: %d", data[0])
+	}
+	perTable := binary.BigEndian.Uint64(data[1:9])
+	if perTable == 0 ||
+		uint64(len(data)-headerSize)/cellSize/hashes != perTable ||
+		uint64(len(data)-headerSize) != perTable*hashes*cellSize {
+		return fmt.Errorf("incorrect length: %d", len(data))
+	}
+
+	cells := make([]cell, perTable*hashes)
+	for i := range cells {
+		b := data[headerSize+cellSize*i:]
+		cells[i] = cell{
+			count:   int32(binary.BigEndian.Uint32(b[0:4])),
+			keySum:  binary.BigEndian.Uint64(b[4:12]),
+			hashSum: binary.BigEndian.Uint64(b[12:20]),
+		}
+	}
+	if t.mutex == nil {
+		t.mutex = new(sync.RWMutex)
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.cells = cells
+	return nil
+}
+
+// update adds the key to its cells count times, which may be negative. The
+// caller must hold the write lock, unless the table is private.
+func (t *IBLT) update(key uint64, count int32) {
+	perTable := uint64(len(t.cells) / hashes)
+	h := check(key)
+	for i := uint64(0); i < hashes; i++ {
+		c := &t.cells[i*perTable+mix(key+i)%perTable]
+		c.count += count
+		c.keySum ^= key
+		c.hashSum ^= h
+	}
+}
+
+// check returns the check hash of the key, which tells a cell holding a single
+// key from one holding several.
+func check(key uint64) uint64 {
+	return mix(key ^ 0x5851f42d4c957f2d)
+}
+
+// mix is the 64-bit MurmurHash3 finalizer, spreading the bits of the key.
+func mix(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
@@ -0,0 +1,87 @@
+package iblt
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func sorted(keys []uint64) []uint64 {
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// TestIBLT_Subtract ensures two peers recover exactly the symmetric difference
+// of their sets.
+func TestIBLT_Subtract(t *testing.T) {
+	a, err := New(200)
+	require.NoError(t, err)
+	b, _ := New(200)
+	var onlyA, onlyB []uint64
+	for i := uint64(0); i < 10000; i++ {
+		a.Insert(i)
+		b.Insert(i)
+	}
+	for i := uint64(10000); i < 10050; i++ {
+		a.Insert(i)
+		onlyA = append(onlyA, i)
+	}
+	for i := uint64(20000); i < 20030; i++ {
+		b.Insert(i)
+		onlyB = append(onlyB, i)
+	}
+
+	// exchange b as a peer would
+	out, err := b.MarshalBinary()
+	require.NoError(t, err)
+	remote := new(IBLT)
+	require.NoError(t, remote.UnmarshalBinary(out))
+
+	diff, err := a.Subtract(remote)
+	require.NoError(t, err)
+	inserted, deleted, err := diff.ListEntries()
+	require.NoError(t, err)
+	require.Equal(t, onlyA, sorted(inserted))
+	require.Equal(t, onlyB, sorted(deleted))
+
+	// listing does not modify the table
+	again, _, err := diff.ListEntries()
+	require.NoError(t, err)
+	require.Len(t, again, len(onlyA))
+}
+
+// TestIBLT_Delete ensures deleted keys cancel inserted ones.
+func TestIBLT_Delete(t *testing.T) {
+	table, _ := New(30)
+	table.Insert(1)
+	table.Insert(2)
+	table.Delete(1)
+	table.Delete(3)
+	inserted, deleted, err := table.ListEntries()
+	require.NoError(t, err)
+	require.Equal(t, []uint64{2}, inserted)
+	require.Equal(t, []uint64{3}, deleted)
+}
+
+// TestIBLT_Incomplete ensures a table holding too many keys reports an error
+// and that mismatched tables are rejected.
+func TestIBLT_Incomplete(t *testing.T) {
+	table, _ := New(30)
+	for i := uint64(0); i < 1000; i++ {
+		table.Insert(i)
+	}
+	_, _, err := table.ListEntries()
+	require.Equal(t, errIncomplete, err)
+
+	other, _ := New(60)
+	_, err = table.Subtract(other)
+	require.Equal(t, errParameters, err)
+	_, err = New(0)
+	require.Equal(t, errCells, err)
+
+	out, _ := table.MarshalBinary()
+	require.Error(t, new(IBLT).UnmarshalBinary(out[:len(out)-1]))
+	out[0] = 2
+	require.Error(t, new(IBLT).UnmarshalBinary(out))
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// The latency histograms have HDR-style log-linear buckets: durations under
+// latencyLinear nanoseconds each have their own bucket, and above that each
+// power of two is split into latencySub equal buckets, so every bucket is
+// within 1/latencySub of the durations it holds, from nanoseconds to hours.
+const (
+	latencySubBits = 3
+	latencySub     = 1 << latencySubBits
+	latencyLinear  = 2 * latencySub
+	latencyBuckets = latencyLinear + (64-latencySubBits-1)*latencySub
+)
+
+// LatencyHistogram counts the durations of an operation. The zero value is an
+// empty histogram.
+type LatencyHistogram struct {
+	counts [latencyBuckets]uint64
+}
+
+// Count returns the number of durations in the histogram.
+func (h *LatencyHistogram) Count() uint64 {
+	var n uint64
+	for i := range h.counts {
+		n += h.counts[i]
+	}
+	return n
+}
+
+// Quantile returns the duration at or below which the fraction q of durations
+// in the histogram lie, rounded up to the top of its bucket, or 0 if the
+// histogram is empty.
+func (h *LatencyHistogram) Quantile(q float64) time.Duration {
+	total := h.Count()
+	if total == 0 {
+		return 0
+	}
+	rank := uint64(q * float64(total))
+	if rank == 0 {
+		rank = 1
+	}
+	if rank > total {
+		rank = total
+	}
+	var seen uint64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			return time.Duration(latencyBucketMax(i))
+		}
+	}
+	return time.Duration(latencyBucketMax(latencyBuckets - 1))
+}
+
+// Buckets calls fn with the upper bound and count of each non-empty bucket, in
+// increasing order, for export to a metrics system.
+func (h *LatencyHistogram) Buckets(fn func(max time.Duration, count uint64)) {
+	for i, c := range h.counts {
+		if c != 0 {
+			fn(time.Duration(latencyBucketMax(i)), c)
+		}
+	}
+}
+
+// observe adds the duration since start to the histogram. It is safe for
+// concurrent use.
+func (h *LatencyHistogram) observe(start time.Time) {
+	h.observeN(start, 1)
+}
+
+// observeN adds n durations to the histogram, each an equal share of the
+// duration since start, for a batch of n operations. It is safe for
+// concurrent use.
+func (h *LatencyHistogram) observeN(start time.Time, n int) {
+	if n <= 0 {
+		return
+	}
+	d := time.Since(start)
+	if d < 0 {
+		d = 0
+	}
+	atomic.AddUint64(&h.counts[latencyBucket(uint64(d)/uint64(n))], uint64(n))
+}
+
+// snapshot returns a copy of the histogram, loading each count atomically.
+func (h *LatencyHistogram) snapshot() *LatencyHistogram {
+	s := new(LatencyHistogram)
+	for i := range h.counts {
+		s.counts[i] = atomic.LoadUint64(&h.counts[i])
+	}
+	return s
+}
+
+// latencyBucket returns the bucket of a duration of v nanoseconds.
+func latencyBucket(v uint64) int {
+	if v < latencyLinear {
+		return int(v)
+	}
+	exp := bits.Len64(v) - 1
+	sub := (v >> uint(exp-latencySubBits)) & (latencySub - 1)
+	return latencyLinear + (exp-latencySubBits-1)*latencySub + int(sub)
+}
+
+// latencyBucketMax returns the largest duration in nanoseconds in bucket i.
+func latencyBucketMax(i int) uint64 {
+	if i < latencyLinear {
+		return uint64(i)
+	}
+	i -= latencyLinear
+	exp := uint(i/latencySub + latencySubBits + 1)
+	sub := uint64(i % latencySub)
+	width := uint64(1) << (exp - latencySubBits)
+	return 1<<exp + sub*width + width - 1
+}
+
+// latency holds the histograms of a ring created with WithLatency.
+type latency struct {
+	add   LatencyHistogram
+	test  LatencyHistogram
+	merge LatencyHistogram
+}
+
+// WithLatency makes the ring record the latency of each single element Add
+// and Test, including the String, Uint64, Hash, and Tuple variants and
+// TestApprox, and of Merge and MergeMany, in histograms reported by Stats.
+// TestAndAdd is recorded as an Add. AddMany, TestMany, and TestManyMask are
+// recorded as an Add or Test of each item, taking an equal share of the time
+// of the batch, while TestAny and TestEvery, which may stop early, are each
+// recorded as one Test. The latency includes waiting for the lock, so
+// contention shows up in the tail.
+func WithLatency() Option {
+	return func(r *Bloom) error {
+		r.latency = new(latency)
+		return nil
+	}
+}
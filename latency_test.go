@@ -0,0 +1,93 @@
+package ring
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestLatencyBucket ensures every duration lies within its bucket, and that
+// buckets are contiguous and within an eighth of their durations.
+func TestLatencyBucket(t *testing.T) {
+	for _, v := range []uint64{0, 1, 15, 16, 17, 31, 32, 1000, 123456789, 1 << 40} {
+		i := latencyBucket(v)
+		require.True(t, v <= latencyBucketMax(i), "%d in bucket %d", v, i)
+		if i > 0 {
+			require.True(t, v > latencyBucketMax(i-1), "%d in bucket %d", v, i)
+		}
+		require.True(t, latencyBucketMax(i)-v <= v/latencySub, "%d", v)
+	}
+	for i := 1; i < latencyBuckets-1; i++ {
+		require.Equal(t, i, latencyBucket(latencyBucketMax(i-1)+1))
+	}
+	require.Equal(t, latencyBuckets-1, latencyBucket(^uint64(0)))
+}
+
+// TestLatencyHistogram_Quantile ensures quantiles are read from the counts.
+func TestLatencyHistogram_Quantile(t *testing.T) {
+	var h LatencyHistogram
+	require.Equal(t, time.Duration(0), h.Quantile(0.5))
+	for i := 0; i < 99; i++ {
+		h.counts[latencyBucket(100)]++
+	}
+	h.counts[latencyBucket(uint64(time.Millisecond))]++
+
+	require.Equal(t, uint64(100), h.Count())
+	require.Equal(t, time.Duration(latencyBucketMax(latencyBucket(100))),
+		h.Quantile(0.5))
+	require.Equal(t, time.Duration(latencyBucketMax(latencyBucket(100))),
+		h.Quantile(0.99))
+	p100 := h.Quantile(1)
+	require.True(t, p100 >= time.Millisecond && p100 < 2*time.Millisecond)
+
+	var seen uint64
+	h.Buckets(func(max time.Duration, count uint64) {
+		seen += count
+	})
+	require.Equal(t, uint64(100), seen)
+}
+
+// TestWithLatency ensures operations are recorded in the histograms reported
+// by Stats only if the ring was created with WithLatency.
+func TestWithLatency(t *testing.T) {
+	r, _ := Init(1000, 0.01, WithLatency())
+	other, _ := Init(1000, 0.01)
+	r.Add([]byte("a"))
+	r.AddString("b")
+	r.AddMany([][]byte{[]byte("d"), []byte("e"), []byte("f")})
+	r.Test([]byte("a"))
+	require.NoError(t, r.Merge(other))
+	require.NoError(t, r.MergeMany(other, other))
+
+	s := r.Stats()
+	require.Equal(t, uint64(5), s.AddLatency.Count())
+	require.Equal(t, uint64(1), s.TestLatency.Count())
+	require.Equal(t, uint64(2), s.MergeLatency.Count())
+
+	// the stats hold copies
+	r.Add([]byte("c"))
+	require.Equal(t, uint64(5), s.AddLatency.Count())
+
+	other.Add([]byte("a"))
+	require.Nil(t, other.Stats().AddLatency)
+}
+
+// TestWithLatency_Variants ensures the batch, tuple, and combined entry points
+// record latency as documented.
+func TestWithLatency_Variants(t *testing.T) {
+	r, _ := Init(1000, 0.01, WithLatency())
+	items := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	r.TestAndAdd([]byte("a"))
+	r.AddTuple([]byte("a"), []byte("b"))
+	r.TestMany(items)
+	r.TestManyMask(items)
+	r.TestAny(items)
+	r.TestEvery(items)
+	r.TestApprox([]byte("a"), 1)
+	r.TestTuple([]byte("a"), []byte("b"))
+
+	s := r.Stats()
+	require.Equal(t, uint64(2), s.AddLatency.Count())
+	require.Equal(t, uint64(10), s.TestLatency.Count())
+}
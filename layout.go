@@ -0,0 +1,170 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"sync"
+)
+
+var errLayout = errors.New("rings must have the same layout")
+
+const (
+	// blockBits is the number of bits in a block of LayoutBlocked, one
+	// 64-byte cache line.
+	blockBits = 512
+	// parallelMergeBytes is the size of bit array from which Merge merges
+	// the partitions of LayoutPartitioned in parallel.
+	parallelMergeBytes = 1 << 16
+)
+
+// Layout selects how the k bits of an element are placed in the bit array.
+type Layout uint8
+
+const (
+	// LayoutStandard places each bit anywhere in the bit array, as described
+	// by ProbePositions. It is the default.
+	LayoutStandard Layout = iota
+	// LayoutBlocked places all k bits of an element in a single 512-bit
+	// block, so a Test touches one cache line instead of k. Elements crowd
+	// unevenly into blocks, raising the false positive rate somewhat, most
+	// for large k.
+	LayoutBlocked
+	// LayoutPartitioned splits the bit array into k equal byte-aligned
+	// partitions and places the bit of the nth hash round in the nth. No two
+	// rounds of an element can collide, so the false positive rate depends
+	// less on the quality of the hash, and merges of large rings are spread
+	// over the partitions in parallel.
+	LayoutPartitioned
+)
+
+// String returns the name of the layout, as reported by Schema.
+func (l Layout) String() string {
+	switch l {
+	case LayoutStandard:
+		return "standard"
+	case LayoutBlocked:
+		return "blocked"
+	case LayoutPartitioned:
+		return "partitioned"
+	default:
+		return fmt.Sprintf("Layout(%d)", uint8(l))
+	}
+}
+
+// valid returns true if the layout is known.
+func (l Layout) valid() bool {
+	return l <= LayoutPartitioned
+}
+
+// unit returns the number of bits the size of a ring with the layout and hash
+// rounds must be a multiple of.
+func (l Layout) unit(hash uint64) uint64 {
+	switch l {
+	case LayoutBlocked:
+		return blockBits
+	case LayoutPartitioned:
+		return 8 * hash
+	default:
+		return 1
+	}
+}
+
+// fits returns true if a bit array of size bits can have the layout with the
+// given hash rounds.
+func (l Layout) fits(size, hash uint64) bool {
+	unit := l.unit(hash)
+	return unit != 0 && size%unit == 0
+}
+
+// fit returns the smallest size of at least size bits that can have the
+// layout with the given hash rounds.
+func (l Layout) fit(size, hash uint64) uint64 {
+	unit := l.unit(hash)
+	return (size + unit - 1) / unit * unit
+}
+
+// WithLayout sets the layout of the ring. The size of the ring is rounded up
+// to suit the layout, to a multiple of 512 bits for LayoutBlocked and of 8k
+// bits for LayoutPartitioned. It returns an error from the constructor if the
+// layout is unknown. Rings with different layouts place the same data at
+// different bits, so they cannot be merged or compared.
+func WithLayout(layout Layout) Option {
+	return func(r *Bloom) error {
+		if !layout.valid() {
+			return fmt.Errorf("error: unknown layout: %s", layout)
+		}
+		r.layout = layout
+		return nil
+	}
+}
+
+// position returns the bit set by the nth round of the pre-generated hashes.
+func (r *Bloom) position(hash [4]uint64, n uint64) uint64 {
+	switch r.layout {
+	case LayoutBlocked:
+		// the block is chosen by a hash independent of the rounds
+		block := fmix(hash[0]) % (r.size / blockBits)
+		return block*blockBits + getRound(hash, n)%blockBits
+	case LayoutPartitioned:
+		partition := r.size / r.hash
+		return n*partition + getRound(hash, n)%partition
+	default:
+		return getRound(hash, n) % r.size
+	}
+}
+
+// orBits sets the bits of src, a bit array of the same size, in the ring. The
+// caller must hold the write lock.
+func (r *Bloom) orBits(src []uint8) {
+	if r.layout != LayoutPartitioned || len(r.bits) < parallelMergeBytes {
+		for i := range src {
+			r.orByte(uint64(i), src[i])
+		}
+		return
+	}
+
+	// partitions are byte aligned, so each is merged by its own goroutine,
+	// which accumulates its changes to the digest and heat to be combined
+	partition := len(r.bits) / int(r.hash)
+	digests := make([]uint64, r.hash)
+	changed := make([]uint64, r.hash)
+	var wg sync.WaitGroup
+	for p := range digests {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := p * partition; i < (p+1)*partition; i++ {
+				old := r.bits[i]
+				if updated := old | src[i]; updated != old {
+					r.bits[i] = updated
+					digests[p] ^= byteDigest(uint64(i), old) ^
+						byteDigest(uint64(i), updated)
+					changed[p] += uint64(bits.OnesCount8(updated ^ old))
+				}
+			}
+		}(p)
+	}
+	wg.Wait()
+	for p := range digests {
+		r.digest ^= digests[p]
+		r.unrecorded += changed[p]
+		r.set += changed[p]
+	}
+}
+
+// decodeLayout parses the payload of the layout extension.
+func decodeLayout(data []byte) (Layout, error) {
+	if len(data) != 1 {
+		return 0, errExtension
+	}
+	layout := Layout(data[0])
+	if !layout.valid() || layout == LayoutStandard {
+		return 0, fmt.Errorf("error: unexpected layout: %s", layout)
+	}
+	return layout, nil
+}
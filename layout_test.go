@@ -0,0 +1,131 @@
+package ring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestWithLayout_Blocked ensures a blocked ring finds every element, places
+// each element's bits in one block, and stays near its false positive rate.
+func TestWithLayout_Blocked(t *testing.T) {
+	r, err := Init(10000, 0.01, WithLayout(LayoutBlocked))
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), r.GetM()%blockBits)
+
+	buff := make([]byte, 4)
+	for i := 0; i < 10000; i++ {
+		intToByte(buff, i)
+		r.Add(buff)
+		locations := r.Locations(buff)
+		for _, l := range locations {
+			require.Equal(t, locations[0]/blockBits, l/blockBits)
+		}
+	}
+	for i := 0; i < 10000; i++ {
+		intToByte(buff, i)
+		require.True(t, r.Test(buff))
+	}
+	positives := 0
+	for i := 10000; i < 110000; i++ {
+		intToByte(buff, i)
+		if r.Test(buff) {
+			positives++
+		}
+	}
+	require.True(t, float64(positives)/100000 < 0.02, "false positives %d",
+		positives)
+	require.NoError(t, r.Validate())
+}
+
+// TestWithLayout_Marshal ensures the layout is carried when marshaled and
+// that rings with different layouts cannot be combined.
+func TestWithLayout_Marshal(t *testing.T) {
+	r, _ := InitByParameters(1000, 4, WithLayout(LayoutBlocked))
+	require.Equal(t, uint64(1024), r.GetM())
+	r.Add([]byte("data"))
+	out, err := r.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, uint8(2), out[0])
+
+	u := new(Bloom)
+	require.NoError(t, u.UnmarshalBinary(out))
+	require.True(t, r.Equal(u))
+	require.True(t, u.Test([]byte("data")))
+
+	standard, _ := InitByParameters(1024, 4)
+	require.False(t, r.Equal(standard))
+	require.Equal(t, errLayout, r.Merge(standard))
+	require.Equal(t, errLayout, r.MergeMany(standard))
+	require.Equal(t, errLayout, r.Intersect(standard))
+
+	// a blocked ring must be a whole number of blocks
+	bad := append([]byte{}, out...)
+	bad[8]--
+	require.Error(t, u.UnmarshalBinary(bad))
+
+	_, err = Init(10, 0.01, WithLayout(Layout(9)))
+	require.Error(t, err)
+	require.Equal(t, "blocked", LayoutBlocked.String())
+}
+
+// TestWithLayout_Partitioned ensures a partitioned ring places the bit of the
+// nth round in the nth partition and stays near its false positive rate.
+func TestWithLayout_Partitioned(t *testing.T) {
+	r, err := Init(10000, 0.01, WithLayout(LayoutPartitioned))
+	require.NoError(t, err)
+	partition := r.GetM() / r.GetK()
+	require.Equal(t, uint64(0), partition%8)
+
+	buff := make([]byte, 4)
+	for i := 0; i < 10000; i++ {
+		intToByte(buff, i)
+		r.Add(buff)
+		for n, l := range r.Locations(buff) {
+			require.Equal(t, uint64(n), l/partition)
+		}
+	}
+	positives := 0
+	for i := 10000; i < 110000; i++ {
+		intToByte(buff, i)
+		if r.Test(buff) {
+			positives++
+		}
+	}
+	require.True(t, float64(positives)/100000 < 0.015, "false positives %d",
+		positives)
+
+	out, _ := r.MarshalBinary()
+	u := new(Bloom)
+	require.NoError(t, u.UnmarshalBinary(out))
+	require.True(t, r.Equal(u))
+	require.NoError(t, u.Validate())
+}
+
+// TestWithLayout_PartitionedMerge ensures merging large partitioned rings in
+// parallel matches merging them one byte at a time, digest included.
+func TestWithLayout_PartitionedMerge(t *testing.T) {
+	a, _ := Init(100000, 0.01, WithLayout(LayoutPartitioned))
+	b, _ := Init(100000, 0.01, WithLayout(LayoutPartitioned))
+	require.True(t, a.BufferSize() >= parallelMergeBytes)
+	buff := make([]byte, 4)
+	for i := 0; i < 50000; i++ {
+		intToByte(buff, i)
+		a.Add(buff)
+		intToByte(buff, i+50000)
+		b.Add(buff)
+	}
+	want := a.Clone()
+	for i := range b.bits {
+		want.orByte(uint64(i), b.bits[i])
+	}
+
+	require.NoError(t, a.Merge(b))
+	require.True(t, want.Equal(a))
+	require.Equal(t, want.Digest(), a.Digest())
+	require.NoError(t, a.Validate())
+	for i := 0; i < 100000; i++ {
+		intToByte(buff, i)
+		require.True(t, a.Test(buff))
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import "fmt"
+
+// State is the lifecycle state of a ring. A ring only moves forward through
+// the states: building, active, frozen, and destroyed, skipping any of them.
+type State uint8
+
+const (
+	// StateBuilding is a ring that is being populated and not yet in use.
+	StateBuilding State = iota
+	// StateActive is a ring that is in use and may still be modified.
+	StateActive
+	// StateFrozen is a ring that may be read but not modified.
+	StateFrozen
+	// StateDestroyed is a ring whose bits have been released. It may not be
+	// used at all.
+	StateDestroyed
+)
+
+// String returns the name of the state.
+func (s State) String() string {
+	switch s {
+	case StateBuilding:
+		return "building"
+	case StateActive:
+		return "active"
+	case StateFrozen:
+		return "frozen"
+	case StateDestroyed:
+		return "destroyed"
+	default:
+		return fmt.Sprintf("State(%d)", uint8(s))
+	}
+}
+
+// StateError is the error for an operation that is invalid in the current
+// state of the ring. Operations without an error result panic with it.
+type StateError struct {
+	Op    string // operation that was attempted
+	State State  // state of the ring at the time
+}
+
+// Error implements the error interface.
+func (e *StateError) Error() string {
+	return fmt.Sprintf("error: cannot %s a %s ring", e.Op, e.State)
+}
+
+// TransitionError is the error for a state change that would move a ring
+// backwards or keep it in the same state.
+type TransitionError struct {
+	From State
+	To   State
+}
+
+// Error implements the error interface.
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("error: cannot transition a ring from %s to %s",
+		e.From, e.To)
+}
+
+// State returns the current lifecycle state of the ring.
+func (r *Bloom) State() State {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.state
+}
+
+// Transition moves the ring to the given state. It returns a *TransitionError
+// if the state is not after the current one. Destroying the ring releases its
+// bits.
+func (r *Bloom) Transition(to State) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.transition(to)
+}
+
+// transition is Transition for a caller that holds the write lock.
+func (r *Bloom) transition(to State) error {
+	if to <= r.state || to > StateDestroyed {
+		return &TransitionError{From: r.state, To: to}
+	}
+	r.state = to
+	if to == StateDestroyed {
+		r.bits = nil
+		r.samples = nil
+		r.digest = 0
+		r.set = 0
+		r.provenance = Provenance{}
+	}
+	return nil
+}
+
+// checkMutable returns a *StateError if the ring may not be modified. The
+// caller must hold the lock.
+func (r *Bloom) checkMutable(op string) error {
+	if r.state >= StateFrozen {
+		return &StateError{Op: op, State: r.state}
+	}
+	return nil
+}
+
+// checkUsable returns a *StateError if the ring has been destroyed. The caller
+// must hold the lock.
+func (r *Bloom) checkUsable(op string) error {
+	if r.state == StateDestroyed {
+		return &StateError{Op: op, State: r.state}
+	}
+	return nil
+}
+
+// mustBeMutable panics with a *StateError if the ring may not be modified. The
+// caller must hold the lock and release it with a defer.
+func (r *Bloom) mustBeMutable(op string) {
+	if err := r.checkMutable(op); err != nil {
+		panic(err)
+	}
+}
+
+// mustBeUsable panics with a *StateError if the ring has been destroyed. The
+// caller must hold the lock and release it with a defer.
+func (r *Bloom) mustBeUsable(op string) {
+	if err := r.checkUsable(op); err != nil {
+		panic(err)
+	}
+}
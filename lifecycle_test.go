@@ -0,0 +1,99 @@
+package ring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestBloom_Transition ensures the ring only moves forward through its states.
+func TestBloom_Transition(t *testing.T) {
+	r, err := Init(100, fpRate)
+	require.NoError(t, err)
+	require.Equal(t, StateBuilding, r.State())
+
+	require.NoError(t, r.Transition(StateActive))
+	require.Equal(t, StateActive, r.State())
+
+	err = r.Transition(StateActive)
+	require.IsType(t, &TransitionError{}, err)
+	err = r.Transition(StateBuilding)
+	require.IsType(t, &TransitionError{}, err)
+	require.Equal(t, "error: cannot transition a ring from active to building",
+		err.Error())
+	err = r.Transition(StateDestroyed + 1)
+	require.IsType(t, &TransitionError{}, err)
+
+	require.NoError(t, r.Transition(StateFrozen))
+	require.NoError(t, r.Transition(StateDestroyed))
+	require.Equal(t, StateDestroyed, r.State())
+	require.Nil(t, r.bits)
+
+	// states may be skipped
+	r2, _ := Init(100, fpRate)
+	require.NoError(t, r2.Transition(StateDestroyed))
+
+	require.Equal(t, "State(9)", State(9).String())
+}
+
+// TestBloom_Frozen ensures a frozen ring can be read but not modified.
+func TestBloom_Frozen(t *testing.T) {
+	r, _ := Init(100, fpRate)
+	r.Add([]byte("frozen"))
+	require.NoError(t, r.Transition(StateFrozen))
+
+	require.True(t, r.Test([]byte("frozen")))
+	_, err := r.MarshalBinary()
+	require.NoError(t, err)
+	_, err = r.MarshalStorage()
+	require.NoError(t, err)
+
+	requireStatePanic(t, StateFrozen, func() { r.Add(nil) })
+	requireStatePanic(t, StateFrozen, func() { r.AddMany(nil) })
+	requireStatePanic(t, StateFrozen, func() { r.TestAndAdd(nil) })
+	requireStatePanic(t, StateFrozen, func() { r.Reset() })
+
+	m, _ := Init(100, fpRate)
+	require.IsType(t, &StateError{}, r.Merge(m))
+	require.NoError(t, m.Merge(r))
+	out, _ := m.MarshalBinary()
+	require.IsType(t, &StateError{}, r.UnmarshalBinary(out))
+	stored, _ := m.MarshalStorage()
+	require.IsType(t, &StateError{}, r.UnmarshalStorage(stored))
+
+	// the lock is released after a panic
+	require.Equal(t, StateFrozen, r.State())
+}
+
+// TestBloom_Destroyed ensures a destroyed ring cannot be used at all.
+func TestBloom_Destroyed(t *testing.T) {
+	r, _ := Init(100, fpRate)
+	require.NoError(t, r.Transition(StateDestroyed))
+
+	requireStatePanic(t, StateDestroyed, func() { r.Test(nil) })
+	requireStatePanic(t, StateDestroyed, func() { r.Add(nil) })
+	requireStatePanic(t, StateDestroyed, func() { r.Locations(nil) })
+	_, err := r.MarshalBinary()
+	require.IsType(t, &StateError{}, err)
+	_, err = r.MarshalBinaryPadded(100)
+	require.IsType(t, &StateError{}, err)
+	_, err = r.MarshalBinaryAligned(8)
+	require.IsType(t, &StateError{}, err)
+	_, err = r.MarshalObfuscated(100, 0)
+	require.IsType(t, &StateError{}, err)
+	_, err = r.MarshalStorage()
+	require.IsType(t, &StateError{}, err)
+
+	m, _ := Init(100, fpRate)
+	require.IsType(t, &StateError{}, m.Merge(r))
+}
+
+// requireStatePanic ensures fn panics with a *StateError in the given state.
+func requireStatePanic(t *testing.T, state State, fn func()) {
+	defer func() {
+		err, ok := recover().(*StateError)
+		require.True(t, ok, "expected *StateError panic")
+		require.Equal(t, state, err.State)
+	}()
+	fn()
+}
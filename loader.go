@@ -0,0 +1,161 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+var (
+	errCheckpointEvery = errors.New("error: CheckpointEvery must be greater than 0")
+	errCheckpoint      = errors.New("error: malformed checkpoint")
+)
+
+// Checkpoint is the progress of a bulk load: the offset in the key file up to
+// which every key has been added, and the filter at that point.
+type Checkpoint struct {
+	Offset int64
+	Filter []byte // output of MarshalBinary
+}
+
+// CheckpointStore persists the latest checkpoint of a bulk load.
+type CheckpointStore interface {
+	// Save replaces the stored checkpoint. It must not leave a partially
+	// written checkpoint behind if it fails.
+	Save(cp Checkpoint) error
+	// Load returns the stored checkpoint, or false if there is none.
+	Load() (Checkpoint, bool, error)
+}
+
+// LoaderConfig contains the parameters of a bulk load.
+type LoaderConfig struct {
+	CheckpointEvery int             // keys added between checkpoints
+	Store           CheckpointStore // where checkpoints are kept
+}
+
+// LoadKeys adds every newline separated key of the file to the ring, saving a
+// checkpoint every CheckpointEvery keys and once at the end. If the store
+// holds a checkpoint, the ring is first replaced with its filter and loading
+// resumes from its offset, so a load interrupted by a crash continues rather
+// than starting over. Keys after the last checkpoint are added again on
+// resume, which leaves the ring unchanged. Empty lines are skipped. It returns
+// the offset reached, which is the size of the file unless an error or the
+// cancellation of ctx stopped the load.
+func LoadKeys(ctx context.Context, r *Bloom, keys io.ReadSeeker,
+	config LoaderConfig) (int64, error) {
+	if config.CheckpointEvery <= 0 {
+		return 0, errCheckpointEvery
+	}
+
+	var offset int64
+	cp, ok, err := config.Store.Load()
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		if err := r.UnmarshalBinary(cp.Filter); err != nil {
+			return 0, err
+		}
+		offset = cp.Offset
+	}
+	if _, err := keys.Seek(offset, io.SeekStart); err != nil {
+		return offset, err
+	}
+
+	checkpoint := func() error {
+		filter, err := r.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		return config.Store.Save(Checkpoint{Offset: offset, Filter: filter})
+	}
+
+	reader := bufio.NewReader(keys)
+	added := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return offset, err
+		}
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(line) > 0 {
+			// the final key has no newline
+			err = nil
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return offset, err
+		}
+
+		offset += int64(len(line))
+		if key := bytes.TrimSuffix(line, []byte{'\n'}); len(key) > 0 {
+			r.Add(key)
+			added++
+		}
+		if added == config.CheckpointEvery {
+			if err := checkpoint(); err != nil {
+				return offset, err
+			}
+			added = 0
+		}
+	}
+	return offset, checkpoint()
+}
+
+// FileCheckpointStore is a CheckpointStore keeping the checkpoint in a file.
+// The checkpoint is written to a temporary file that then replaces it, so a
+// crash during Save leaves the previous checkpoint intact.
+type FileCheckpointStore struct {
+	Path string
+}
+
+// Save implements CheckpointStore.
+func (s FileCheckpointStore) Save(cp Checkpoint) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(s.Path), filepath.Base(s.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	var offset [8]byte
+	binary.BigEndian.PutUint64(offset[:], uint64(cp.Offset))
+	if _, err := tmp.Write(append(offset[:], cp.Filter...)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Path)
+}
+
+// Load implements CheckpointStore.
+func (s FileCheckpointStore) Load() (Checkpoint, bool, error) {
+	data, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return Checkpoint{}, false, nil
+	} else if err != nil {
+		return Checkpoint{}, false, err
+	}
+	if len(data) < 8 {
+		return Checkpoint{}, false, errCheckpoint
+	}
+	return Checkpoint{
+		Offset: int64(binary.BigEndian.Uint64(data[:8])),
+		Filter: data[8:],
+	}, true, nil
+}
@@ -0,0 +1,96 @@
+package ring
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// crashingStore is a CheckpointStore that fails after a number of saves.
+type crashingStore struct {
+	CheckpointStore
+	remaining int
+}
+
+// Save implements CheckpointStore.
+func (s *crashingStore) Save(cp Checkpoint) error {
+	if s.remaining == 0 {
+		return errors.New("crashed")
+	}
+	s.remaining--
+	return s.CheckpointStore.Save(cp)
+}
+
+// TestLoadKeys_Resume ensures an interrupted load resumes from its last
+// checkpoint and produces the same filter as an uninterrupted one.
+func TestLoadKeys_Resume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loader")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var file bytes.Buffer
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&file, "key-%d\n", i)
+	}
+	// a blank line and a final key without a newline
+	file.WriteString("\nlast")
+	keys := bytes.NewReader(file.Bytes())
+
+	expected, _ := Init(2000, 0.01)
+	store := FileCheckpointStore{Path: filepath.Join(dir, "checkpoint")}
+	offset, err := LoadKeys(context.Background(), expected, keys,
+		LoaderConfig{CheckpointEvery: 100, Store: store})
+	require.NoError(t, err)
+	require.Equal(t, int64(file.Len()), offset)
+	require.True(t, expected.Test([]byte("last")))
+	require.True(t, expected.Test([]byte("key-999")))
+	require.NoError(t, os.Remove(store.Path))
+
+	r, _ := Init(2000, 0.01)
+	crashing := &crashingStore{CheckpointStore: store, remaining: 3}
+	_, err = LoadKeys(context.Background(), r, keys,
+		LoaderConfig{CheckpointEvery: 100, Store: crashing})
+	require.Error(t, err)
+	cp, ok, err := store.Load()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, int64(len("key-0\n")*10+len("key-10\n")*90+
+		len("key-100\n")*200), cp.Offset)
+
+	resumed, _ := Init(2000, 0.01)
+	offset, err = LoadKeys(context.Background(), resumed, keys,
+		LoaderConfig{CheckpointEvery: 100, Store: store})
+	require.NoError(t, err)
+	require.Equal(t, int64(file.Len()), offset)
+	require.True(t, expected.Equal(resumed))
+}
+
+// TestLoadKeys_Cancel ensures a cancelled load stops with the context error.
+func TestLoadKeys_Cancel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loader")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r, _ := Init(10, 0.01)
+	store := FileCheckpointStore{Path: filepath.Join(dir, "checkpoint")}
+	_, err = LoadKeys(ctx, r, bytes.NewReader([]byte("a\nb\n")),
+		LoaderConfig{CheckpointEvery: 1, Store: store})
+	require.Equal(t, context.Canceled, err)
+
+	_, err = LoadKeys(context.Background(), r, bytes.NewReader(nil),
+		LoaderConfig{Store: store})
+	require.Error(t, err)
+
+	require.NoError(t, ioutil.WriteFile(store.Path, []byte{1, 2}, 0644))
+	_, _, err = store.Load()
+	require.Error(t, err)
+}
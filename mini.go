@@ -0,0 +1,101 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	// MaxMiniBits is the largest filter size, in bits, that fits in a mini
+	// filter field.
+	MaxMiniBits = 512
+
+	// MiniFilterSize is the width, in bytes, of a packed mini filter: 2 bytes
+	// of size, 1 byte of hash rounds, and the bit array padded to MaxMiniBits.
+	MiniFilterSize = 3 + MaxMiniBits/8
+)
+
+var (
+	errMiniSize = errors.New("error: mini filters must have between 1 and 512 bits")
+	errMiniHash = errors.New("error: mini filters must have between 1 and 255 hash rounds")
+	errMiniBits = errors.New("error: mini filter has bits set beyond its size")
+	errMiniMode = errors.New("error: mini filters must use the default hashing and layout")
+)
+
+// MiniFilter is a filter of at most MaxMiniBits packed into a fixed-width
+// field, for embedding in per-message headers.
+type MiniFilter [MiniFilterSize]byte
+
+// PackMini packs the ring into a fixed-width field. It returns an error if the
+// ring has more than MaxMiniBits bits or more than 255 hash rounds, or uses a
+// seed, hash family or layout other than the default, as the field has no
+// room for them.
+func PackMini(r *Bloom) (MiniFilter, error) {
+	var field MiniFilter
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if err := r.checkUsable("pack"); err != nil {
+		return field, err
+	}
+	if r.size == 0 || r.size > MaxMiniBits {
+		return field, errMiniSize
+	}
+	if r.hash == 0 || r.hash > 255 {
+		return field, errMiniHash
+	}
+	if r.hashing != (hashing{}) || r.layout != LayoutStandard {
+		return field, errMiniMode
+	}
+	binary.BigEndian.PutUint16(field[0:2], uint16(r.size))
+	field[2] = uint8(r.hash)
+	copy(field[3:], r.bits)
+	return field, nil
+}
+
+// UnpackMini decodes a field packed by PackMini. Any field it accepts packs back
+// to the same bytes: it returns an error if the parameters are out of range or
+// any bit beyond the size is set.
+func UnpackMini(field MiniFilter) (*Bloom, error) {
+	size := uint64(binary.BigEndian.Uint16(field[0:2]))
+	hash := uint64(field[2])
+	if size == 0 || size > MaxMiniBits {
+		return nil, errMiniSize
+	}
+	if hash == 0 {
+		return nil, errMiniHash
+	}
+
+	// check every byte without exiting early, so that timing does not reveal
+	// where stray bits are
+	bits := field[3:]
+	var stray uint8
+	for i := range bits {
+		stray |= bits[i] & invalidBits(uint64(i), size)
+	}
+	if stray != 0 {
+		return nil, errMiniBits
+	}
+
+	r, _ := InitByParameters(size, hash)
+	copy(r.bits, bits)
+	r.digest = computeDigest(r.bits)
+	r.set = countBits(r.bits)
+	return r, nil
+}
+
+// invalidBits returns the mask of bits in byte i that lie beyond a filter of
+// the given size.
+func invalidBits(i, size uint64) uint8 {
+	switch {
+	case (i+1)*8 <= size:
+		return 0
+	case i*8 >= size:
+		return 0xff
+	default:
+		return ^uint8(0) << (size % 8)
+	}
+}
@@ -0,0 +1,99 @@
+package ring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestPackMini ensures mini filters round trip for every supported size.
+func TestPackMini(t *testing.T) {
+	for size := uint64(1); size <= MaxMiniBits; size += 7 {
+		r, err := InitByParameters(size, 3)
+		require.NoError(t, err)
+		buff := make([]byte, 4)
+		for i := 0; i < int(size/10)+1; i++ {
+			intToByte(buff, i)
+			r.Add(buff)
+		}
+
+		field, err := PackMini(r)
+		require.NoError(t, err)
+		r2, err := UnpackMini(field)
+		require.NoError(t, err)
+		require.Equal(t, r.GetSize(), r2.GetSize())
+		require.Equal(t, r.GetHashOpCount(), r2.GetHashOpCount())
+		require.Equal(t, r.bits, r2.bits)
+		require.Equal(t, r.Digest(), r2.Digest())
+
+		field2, err := PackMini(r2)
+		require.NoError(t, err)
+		require.Equal(t, field, field2)
+	}
+}
+
+// TestPackMini_BadParameters ensures filters that do not fit are rejected.
+func TestPackMini_BadParameters(t *testing.T) {
+	r, _ := InitByParameters(MaxMiniBits+1, 3)
+	_, err := PackMini(r)
+	require.Error(t, err)
+	r, _ = InitByParameters(64, 256)
+	_, err = PackMini(r)
+	require.Error(t, err)
+	r, _ = InitByParameters(64, 3)
+	require.NoError(t, r.Transition(StateDestroyed))
+	_, err = PackMini(r)
+	require.Error(t, err)
+}
+
+// TestPackMini_NonDefault ensures rings whose seed or layout the field cannot
+// carry are rejected rather than unpacked into one that misses their elements.
+func TestPackMini_NonDefault(t *testing.T) {
+	r, _ := InitByParameters(256, 3, WithSeed(7))
+	r.Add([]byte("hello"))
+	_, err := PackMini(r)
+	require.Equal(t, errMiniMode, err)
+
+	r, _ = InitByParameters(512, 3, WithLayout(LayoutBlocked))
+	r.Add([]byte("hello"))
+	_, err = PackMini(r)
+	require.Equal(t, errMiniMode, err)
+}
+
+// TestUnpackMini_Strict ensures fields that would not round trip are rejected.
+func TestUnpackMini_Strict(t *testing.T) {
+	r, _ := InitByParameters(13, 2)
+	field, err := PackMini(r)
+	require.NoError(t, err)
+
+	// a bit beyond the size in the last byte
+	bad := field
+	bad[3+1] |= 1 << 5
+	_, err = UnpackMini(bad)
+	require.Error(t, err)
+
+	// a bit in the padding
+	bad = field
+	bad[MiniFilterSize-1] = 1
+	_, err = UnpackMini(bad)
+	require.Error(t, err)
+
+	// the last valid bit is accepted
+	ok := field
+	ok[3+1] |= 1 << 4
+	_, err = UnpackMini(ok)
+	require.NoError(t, err)
+
+	bad = field
+	bad[0], bad[1] = 0, 0
+	_, err = UnpackMini(bad)
+	require.Error(t, err)
+	bad = field
+	bad[0] = 0xff
+	_, err = UnpackMini(bad)
+	require.Error(t, err)
+	bad = field
+	bad[2] = 0
+	_, err = UnpackMini(bad)
+	require.Error(t, err)
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+// MultiTester tests data against a list of rings, ordered from the most to
+// the least likely to contain it, and reports the first that may. The data is
+// hashed once for each run of rings sharing a hash family and seed. Go has no
+// prefetch intrinsic, so instead each ring is probed without branching between
+// probes: the loads of all k bits are independent and the processor overlaps
+// their cache misses, rather than waiting on each before deciding whether to
+// issue the next. The scan stops at the first ring that may contain the data.
+type MultiTester struct {
+	rings []*Bloom
+}
+
+// NewMultiTester returns a MultiTester over the rings, in the order given.
+func NewMultiTester(rings ...*Bloom) *MultiTester {
+	return &MultiTester{rings: append([]*Bloom{}, rings...)}
+}
+
+// First returns the index of the first ring that may contain the data, or -1
+// if none of them do. Like Test, it panics if a ring has been destroyed.
+func (mt *MultiTester) First(data []byte) int {
+	var h HashHandle
+	for i, r := range mt.rings {
+		// rings are usually hashed alike, so the data is hashed again only
+		// when the hash family or seed changes; the layout only affects
+		// where the hashes are probed
+		if hs := r.currentHashing(); i == 0 || hs != h.hashing {
+			h = hs.handle(data)
+		}
+		if r.testHashUnrolled(h.hash) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Len returns the number of rings in the MultiTester.
+func (mt *MultiTester) Len() int {
+	return len(mt.rings)
+}
+
+// testHashUnrolled is testHash taking the read lock and checking every probe
+// without exiting early, so that the loads of the bits are issued together.
+func (r *Bloom) testHashUnrolled(hash [4]uint64) bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	r.mustBeUsable("test")
+	present := uint8(1)
+	for i := uint64(0); i < r.hash; i++ {
+		index := r.position(hash, i)
+		present &= r.bits[index/8] >> (index % 8)
+	}
+	r.logOp(OpTest, hash, present&1 == 1)
+	return present&1 == 1
+}
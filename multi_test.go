@@ -0,0 +1,55 @@
+package ring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestMultiTester ensures First reports the first ring holding the data and
+// agrees with Test.
+func TestMultiTester(t *testing.T) {
+	rings := make([]*Bloom, 4)
+	buff := make([]byte, 4)
+	for i := range rings {
+		rings[i], _ = Init(1000, 0.001)
+		for j := i * 1000; j < (i+1)*1000; j++ {
+			intToByte(buff, j)
+			rings[i].Add(buff)
+		}
+	}
+	mt := NewMultiTester(rings...)
+	require.Equal(t, 4, mt.Len())
+
+	for j := 0; j < 5000; j++ {
+		intToByte(buff, j)
+		expected := -1
+		for i, r := range rings {
+			if r.Test(buff) {
+				expected = i
+				break
+			}
+		}
+		require.Equal(t, expected, mt.First(buff), "element %d", j)
+		if j < 4000 {
+			require.True(t, expected >= 0 && expected <= j/1000)
+		}
+	}
+
+	require.Equal(t, -1, NewMultiTester().First(buff))
+}
+
+// BenchmarkMultiTester measures a scan of many rings that misses all of them.
+func BenchmarkMultiTester(b *testing.B) {
+	rings := make([]*Bloom, 16)
+	for i := range rings {
+		rings[i], _ = Init(100000, 0.01)
+	}
+	mt := NewMultiTester(rings...)
+	buff := make([]byte, 4)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		intToByte(buff, i)
+		mt.First(buff)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+)
+
+// MarshalObfuscated is MarshalBinary for responses whose size and contents
+// must not reveal how much data the filter holds. The output is exactly size
+// bytes, with the space after the bit array filled with random bytes, and
+// saltBits randomly chosen bits are additionally set in the output (but not
+// in the filter itself) to mask its true fill. Salting raises the false
+// positive rate of the output, never its false negatives. It returns an error
+// if the filter does not fit in size bytes or randomness cannot be read.
+func (r *Bloom) MarshalObfuscated(size int, saltBits uint64) ([]byte, error) {
+	return r.marshalObfuscated(size, saltBits, rand.Reader)
+}
+
+// marshalObfuscated is MarshalObfuscated drawing randomness from rng.
+func (r *Bloom) marshalObfuscated(size int, saltBits uint64, rng io.Reader) ([]byte, error) {
+	r.mutex.RLock()
+	if err := r.checkUsable("marshal"); err != nil {
+		r.mutex.RUnlock()
+		return nil, err
+	}
+	section := r.extensionSection()
+	offset := bitsOffset(section)
+	if size < offset+len(r.bits) {
+		r.mutex.RUnlock()
+		return nil, errPadSize
+	}
+	out := r.marshal(section, size)
+	// the ring may change once unlocked, so only the output is used after
+	length, ringSize := len(r.bits), r.size
+	r.mutex.RUnlock()
+
+	if _, err := io.ReadFull(rng, out[offset+length:]); err != nil {
+		return nil, err
+	}
+
+	bits := out[offset : offset+length]
+	buff := make([]byte, 8)
+	for i := uint64(0); i < saltBits; i++ {
+		if _, err := io.ReadFull(rng, buff); err != nil {
+			return nil, err
+		}
+		index := binary.BigEndian.Uint64(buff) % ringSize
+		bits[index/8] |= 1 << (index % 8)
+	}
+	return out, nil
+}
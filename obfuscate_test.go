@@ -0,0 +1,59 @@
+package ring
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestBloom_MarshalObfuscated ensures the output has a fixed size, keeps every
+// element, and is salted without changing the filter.
+func TestBloom_MarshalObfuscated(t *testing.T) {
+	r, err := Init(1000, 0.01)
+	require.NoError(t, err)
+	buff := make([]byte, 4)
+	for i := 0; i < 100; i++ {
+		intToByte(buff, i)
+		r.Add(buff)
+	}
+	before := countBits(r.bits)
+
+	out, err := r.MarshalObfuscated(2048, 500)
+	require.NoError(t, err)
+	require.Len(t, out, 2048)
+	require.Equal(t, before, countBits(r.bits))
+
+	r2 := new(Bloom)
+	require.NoError(t, r2.UnmarshalBinary(out))
+	for i := 0; i < 100; i++ {
+		intToByte(buff, i)
+		require.True(t, r2.Test(buff))
+	}
+	salted := countBits(r2.bits)
+	require.True(t, salted > before && salted <= before+500)
+
+	// the padding is random rather than zeros
+	padding := out[headerSize+r.BufferSize():]
+	require.False(t, bytes.Equal(padding, make([]byte, len(padding))))
+
+	_, err = r.MarshalObfuscated(r.BufferSize()+headerSize-1, 0)
+	require.Error(t, err)
+}
+
+// TestBloom_MarshalObfuscated_RandError ensures a failure to read randomness
+// is returned.
+func TestBloom_MarshalObfuscated_RandError(t *testing.T) {
+	r, _ := Init(1000, 0.01)
+	_, err := r.marshalObfuscated(2048, 1, errReader{})
+	require.Error(t, err)
+	_, err = r.marshalObfuscated(r.BufferSize()+headerSize, 1, errReader{})
+	require.Error(t, err)
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New("no randomness")
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"fmt"
+	"time"
+)
+
+// Op is the kind of operation in an OpRecord.
+type Op uint8
+
+const (
+	// OpAdd is an Add of a single element. Result is true if it set any bit,
+	// meaning the element was novel.
+	OpAdd Op = iota
+	// OpTest is a Test of a single element. Result is true if it hit.
+	OpTest
+	// OpTestAndAdd is a TestAndAdd. Result is true if it hit.
+	OpTestAndAdd
+	// OpSetBits is a SetBits. Result is true if it set any bit. As there is
+	// no element, KeyPrefix is 0.
+	OpSetBits
+	// OpTestBits is a TestBits. Result is true if it hit. As there is no
+	// element, KeyPrefix is 0.
+	OpTestBits
+)
+
+// String returns the name of the op.
+func (o Op) String() string {
+	switch o {
+	case OpAdd:
+		return "add"
+	case OpTest:
+		return "test"
+	case OpTestAndAdd:
+		return "test-and-add"
+	case OpSetBits:
+		return "set-bits"
+	case OpTestBits:
+		return "test-bits"
+	default:
+		return fmt.Sprintf("Op(%d)", uint8(o))
+	}
+}
+
+// OpRecord describes an operation on a ring for offline analytics. It holds a
+// prefix of the element's hash instead of the element, which is enough to
+// estimate how many distinct elements were seen but not to recover them.
+type OpRecord struct {
+	At        time.Time
+	Op        Op
+	KeyPrefix uint32 // top 32 bits of the element's first hash
+	Result    bool   // outcome of the op, see Op
+}
+
+// OpSink receives the records of a ring created with WithOpLog.
+type OpSink interface {
+	// Record is called with each record, in order, while the ring is locked.
+	// It must be fast, for example appending to a buffer or sending on a
+	// buffered channel, and must not call into the ring.
+	Record(rec OpRecord)
+}
+
+// WithOpLog makes the ring send a record of each operation on an element to
+// the sink: Add, Test, and TestAndAdd, including the String, Uint64, Hash, and
+// Tuple variants, TestApprox, the Tests of a MultiTester, one record per
+// element reached by AddMany, TestMany, TestManyMask, TestAny, and TestEvery,
+// and the Adds and Tests of an AsyncBloom. SetBits and TestBits are recorded
+// as OpSetBits and OpTestBits.
+// Merges, other operations on the whole ring, and Tests of a Frozen view,
+// which takes no lock to order its records by, are not recorded.
+func WithOpLog(sink OpSink) Option {
+	return func(r *Bloom) error {
+		r.opLog = sink
+		return nil
+	}
+}
+
+// add sets the bits for the pre-generated hashes and records the Add. The
+// caller must hold the write lock.
+func (r *Bloom) add(hash [4]uint64) {
+	if r.opLog == nil {
+		r.setHash(hash)
+		return
+	}
+	before := r.unrecorded
+	r.setHash(hash)
+	r.logOp(OpAdd, hash, r.unrecorded != before)
+}
+
+// test returns testHash of the pre-generated hashes and records the Test. The
+// caller must hold the read lock.
+func (r *Bloom) test(hash [4]uint64) bool {
+	found := r.testHash(hash)
+	if r.opLog != nil {
+		r.logOp(OpTest, hash, found)
+	}
+	return found
+}
+
+// testAndAdd sets the bits for the pre-generated hashes, returning whether
+// they were all set beforehand, and records the TestAndAdd. The caller must
+// hold the write lock.
+func (r *Bloom) testAndAdd(hash [4]uint64) bool {
+	present := r.testHash(hash)
+	if !present {
+		r.setHash(hash)
+	}
+	r.logOp(OpTestAndAdd, hash, present)
+	return present
+}
+
+// logOp sends a record of the op to the sink, if any.
+func (r *Bloom) logOp(op Op, hash [4]uint64, result bool) {
+	if r.opLog != nil {
+		r.opLog.Record(OpRecord{
+			At:        time.Now(),
+			Op:        op,
+			KeyPrefix: uint32(hash[0] >> 32),
+			Result:    result,
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package ring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSink is an OpSink keeping every record.
+type recordingSink struct {
+	records []OpRecord
+}
+
+// Record implements OpSink.
+func (s *recordingSink) Record(rec OpRecord) {
+	s.records = append(s.records, rec)
+}
+
+// TestWithOpLog ensures single element and batch ops are recorded with their
+// outcome and a prefix of the element's hash, and Frozen views are not.
+func TestWithOpLog(t *testing.T) {
+	sink := &recordingSink{}
+	r, err := Init(1000, 0.01, WithOpLog(sink))
+	require.NoError(t, err)
+
+	r.Add([]byte("a"))
+	r.AddString("a")
+	r.Test([]byte("a"))
+	r.TestString("b")
+	r.TestAndAdd([]byte("c"))
+	r.AddUint64(1)
+	r.TestHash(r.Hash([]byte("c")))
+	r.AddMany([][]byte{[]byte("d"), []byte("a")})
+	r.TestMany([][]byte{[]byte("d")})
+	r.TestManyMask([][]byte{[]byte("e")})
+	r.TestAny([][]byte{[]byte("e"), []byte("d"), []byte("a")})
+	r.TestEvery([][]byte{[]byte("d"), []byte("e"), []byte("a")})
+	r.TestApprox([]byte("d"), 1)
+	require.NoError(t, r.SetBits(r.Locations([]byte("f"))))
+	r.TestBits(r.Locations([]byte("f")))
+	NewMultiTester(r).First([]byte("f"))
+	a, _ := NewAsyncBloom(r, AsyncConfig{QueueSize: 1})
+	require.NoError(t, a.Add([]byte("g")))
+	require.NoError(t, a.Close())
+	a.Test([]byte("g"))
+	f, _ := r.Freeze()
+	f.Test([]byte("g"))
+
+	expected := []struct {
+		op     Op
+		result bool
+	}{
+		{OpAdd, true}, {OpAdd, false}, {OpTest, true}, {OpTest, false},
+		{OpTestAndAdd, false}, {OpAdd, true}, {OpTest, true},
+		{OpAdd, true}, {OpAdd, false}, {OpTest, true}, {OpTest, false},
+		{OpTest, false}, {OpTest, true}, {OpTest, true}, {OpTest, false},
+		{OpTest, true}, {OpSetBits, true}, {OpTestBits, true}, {OpTest, true},
+		{OpAdd, true}, {OpTest, true},
+	}
+	require.Len(t, sink.records, len(expected))
+	for i, e := range expected {
+		require.Equal(t, e.op, sink.records[i].Op, "record %d", i)
+		require.Equal(t, e.result, sink.records[i].Result, "record %d", i)
+		require.False(t, sink.records[i].At.IsZero())
+	}
+	prefix := uint32(generateMultiHash([]byte("a"), 0)[0] >> 32)
+	require.Equal(t, prefix, sink.records[0].KeyPrefix)
+	require.Equal(t, prefix, sink.records[2].KeyPrefix)
+
+	require.Zero(t, sink.records[16].KeyPrefix)
+
+	require.Equal(t, "test-and-add", OpTestAndAdd.String())
+	require.Equal(t, "set-bits", OpSetBits.String())
+	require.Equal(t, "Op(9)", Op(9).String())
+}
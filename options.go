@@ -0,0 +1,316 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+var (
+	errHashFamily = errors.New("error: unknown hash family")
+	errHashing    = errors.New("rings must have the same hash family and seed")
+	errKeySize    = errors.New("error: the key must be 16 bytes")
+	errKeyed      = errors.New("error: a keyed hash family must be set by WithKey")
+	errNoKey      = errors.New("error: the ring is keyed, so it can only be unmarshaled into a ring created WithKey")
+	errKey        = errors.New("error: the ring was keyed with a different key")
+)
+
+// Option configures a ring created by Init or InitByParameters.
+type Option func(r *Bloom) error
+
+// HashFamily selects the hash function used to place data in a ring.
+type HashFamily uint8
+
+const (
+	// HashMurmur3 is the MurmurHash3 based scheme described by
+	// ProbePositions. It is the default.
+	HashMurmur3 HashFamily = iota
+	// HashSipHash is the 128-bit output of SipHash-2-4, keyed with a secret
+	// set by WithKey, in place of the first MurmurHash3 hash of
+	// ProbePositions. Its second hash is derived from the first by the
+	// MurmurHash3 finalizer.
+	HashSipHash
+	// HashWyhash is the 64-bit wyhash (final version 3) of the data, from
+	// which the other three hashes of ProbePositions are derived by the
+	// MurmurHash3 finalizer. It hashes several times faster than
+	// HashMurmur3, but rings using it can only be read by versions of this
+	// package that know it, as the hashing extension is critical.
+	HashWyhash
+)
+
+// String returns the name of the hash family, as reported by Schema.
+func (f HashFamily) String() string {
+	switch f {
+	case HashMurmur3:
+		return "murmur3-x64-128-carry/double"
+	case HashSipHash:
+		return "siphash-2-4-128/double"
+	case HashWyhash:
+		return "wyhash-final3/double"
+	default:
+		return fmt.Sprintf("HashFamily(%d)", uint8(f))
+	}
+}
+
+// valid returns true if the hash family is known.
+func (f HashFamily) valid() bool {
+	return f == HashMurmur3 || f == HashSipHash || f == HashWyhash
+}
+
+// keyed returns true if the hash family is keyed with a secret.
+func (f HashFamily) keyed() bool {
+	return f == HashSipHash
+}
+
+// WithSeed sets the seed of the hash function. Rings with different seeds
+// place the same data at different bits, so they cannot be merged or compared.
+// The seed is not a secret: it is marshaled in clear with the ring, and is too
+// short and the hash too weak to hide where data lies. For that, use WithKey
+// or a SaltedBloom.
+func WithSeed(seed uint32) Option {
+	return func(r *Bloom) error {
+		r.hashing.seed = seed
+		return nil
+	}
+}
+
+// WithRandomSeed sets the seed of the hash function to one drawn from
+// crypto/rand, so that independently created rings do not share their
+// collisions: data that collides in one ring is unlikely to in another. The
+// seed is marshaled with the ring like one set by WithSeed, and rings with
+// different seeds cannot be merged or compared, so rings that must be
+// combined should instead share a seed. NewRing and NewA2 draw one seed for
+// all their rings. It returns an error from the constructor if randomness
+// cannot be read.
+func WithRandomSeed() Option {
+	return func(r *Bloom) error {
+		return r.setRandomSeed(rand.Reader)
+	}
+}
+
+// setRandomSeed sets the seed of the hash function to one drawn from rng.
+func (r *Bloom) setRandomSeed(rng io.Reader) error {
+	var seed [4]byte
+	if _, err := io.ReadFull(rng, seed[:]); err != nil {
+		return err
+	}
+	r.hashing.seed = binary.BigEndian.Uint32(seed[:])
+	return nil
+}
+
+// withHashingOf sets the hashing of the ring to that of other, so that rings
+// made with WithRandomSeed can share the seed drawn for the first.
+func withHashingOf(other *Bloom) Option {
+	return func(r *Bloom) error {
+		r.hashing = other.hashing
+		return nil
+	}
+}
+
+// WithHash sets the hash family of the ring. It returns an error from the
+// constructor if the family is unknown, or keyed and so must be set by
+// WithKey.
+func WithHash(family HashFamily) Option {
+	return func(r *Bloom) error {
+		if !family.valid() {
+			return errHashFamily
+		}
+		if family.keyed() {
+			return errKeyed
+		}
+		r.hashing.family = family
+		r.hashing.key = [2]uint64{}
+		return nil
+	}
+}
+
+// WithKey sets the hash family of the ring to HashSipHash, keyed with the
+// 16-byte secret key. Unlike a seed, which an attacker who reads a marshaled
+// ring learns, the key is never marshaled, so an attacker who knows the
+// algorithm but not the key cannot craft data that sets chosen bits and
+// raises the false positive rate. A marshaled keyed ring carries a check
+// value of the key, and can only be unmarshaled into a ring created with the
+// same key. It returns an error from the constructor if the key is not 16
+// bytes.
+func WithKey(key []byte) Option {
+	return func(r *Bloom) error {
+		if len(key) != 16 {
+			return errKeySize
+		}
+		r.hashing.family = HashSipHash
+		r.hashing.key = [2]uint64{
+			binary.LittleEndian.Uint64(key[:8]),
+			binary.LittleEndian.Uint64(key[8:]),
+		}
+		return nil
+	}
+}
+
+// WithLocking sets whether the ring guards its operations with a mutex, which
+// is the default. A ring without locking is faster but must not be used by
+// more than one goroutine at a time.
+func WithLocking(locking bool) Option {
+	return func(r *Bloom) error {
+		if locking {
+			r.mutex = &sync.RWMutex{}
+		} else {
+			r.mutex = noopLocker{}
+		}
+		return nil
+	}
+}
+
+// locker is the lock guarding a ring.
+type locker interface {
+	Lock()
+	Unlock()
+	RLock()
+	RUnlock()
+}
+
+// noopLocker is the locker of a ring created with locking disabled.
+type noopLocker struct{}
+
+func (noopLocker) Lock()    {}
+func (noopLocker) Unlock()  {}
+func (noopLocker) RLock()   {}
+func (noopLocker) RUnlock() {}
+
+// newLocker returns a locker of the same kind as l.
+func newLocker(l locker) locker {
+	if _, ok := l.(noopLocker); ok {
+		return noopLocker{}
+	}
+	return &sync.RWMutex{}
+}
+
+// hashing is the hash family, seed and key of a ring. The zero value is the
+// default, which is not recorded when the ring is marshaled.
+type hashing struct {
+	family HashFamily
+	seed   uint32
+	key    [2]uint64 // secret of a keyed family, never marshaled
+}
+
+// keyProbe is the data hashed for the key check of a marshaled keyed ring.
+var keyProbe = []byte("elixxir bloomfilter key")
+
+// sum returns the hashes of the data used to derive its bit positions.
+func (h hashing) sum(data []byte) [4]uint64 {
+	switch h.family {
+	case HashSipHash:
+		h1, h2 := sip128(h.key[0]^uint64(h.seed), h.key[1], data)
+		return [4]uint64{h1, h2, fmix(h1 ^ murmur64c1), fmix(h2 ^ murmur64c2)}
+	case HashWyhash:
+		h1 := wyhash(data, uint64(h.seed))
+		return [4]uint64{h1, fmix(h1 ^ wyp[1]), fmix(h1 ^ wyp[2]),
+			fmix(h1 ^ wyp[3])}
+	default:
+		return generateMultiHash(data, h.seed)
+	}
+}
+
+// keyCheck returns the value that a marshaled keyed ring carries to tell
+// whether a receiver has its key, without revealing the key.
+func (h hashing) keyCheck() uint64 {
+	return sip64(h.key[0], h.key[1], keyProbe)
+}
+
+// hashData returns the hashes of the data in the hash family and seed of the
+// ring. CopyFrom and UnmarshalBinary replace these, so they are read under the
+// read lock, but the data is hashed outside it. The caller must not hold the
+// lock; one that does hashes with r.hashing.sum.
+func (r *Bloom) hashData(data []byte) [4]uint64 {
+	return r.currentHashing().sum(data)
+}
+
+// currentHashing returns the hash family and seed of the ring, read under the
+// read lock.
+func (r *Bloom) currentHashing() hashing {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.hashing
+}
+
+// applyOptions applies the options to the ring in order, then rounds its size
+// up to suit its layout. It must be called before the bit array is made.
+func (r *Bloom) applyOptions(opts []Option) error {
+	for _, opt := range opts {
+		if err := opt(r); err != nil {
+			return err
+		}
+	}
+	r.size = r.layout.fit(r.size, r.hash)
+	return nil
+}
+
+// hashingPayloadSize is the size of the payload of the hashing extension, and
+// keyedPayloadSize the size for a keyed family.
+const (
+	hashingPayloadSize = 5
+	keyedPayloadSize   = hashingPayloadSize + 8
+)
+
+// encode returns the payload of the hashing extension: the family followed by
+// the seed, and for a keyed family the key check.
+func (h hashing) encode() []byte {
+	if h.family.keyed() {
+		out := make([]byte, keyedPayloadSize)
+		out[0] = uint8(h.family)
+		binary.BigEndian.PutUint32(out[1:], h.seed)
+		binary.BigEndian.PutUint64(out[hashingPayloadSize:], h.keyCheck())
+		return out
+	}
+	out := make([]byte, hashingPayloadSize)
+	out[0] = uint8(h.family)
+	binary.BigEndian.PutUint32(out[1:], h.seed)
+	return out
+}
+
+// decodeHashing parses the payload of the hashing extension, returning the
+// key check for a keyed family. The key itself is filled in by adoptKey.
+func decodeHashing(data []byte) (hashing, uint64, error) {
+	if len(data) < 1 {
+		return hashing{}, 0, errExtension
+	}
+	h := hashing{family: HashFamily(data[0])}
+	if !h.family.valid() {
+		return hashing{}, 0, errHashFamily
+	}
+	size := hashingPayloadSize
+	if h.family.keyed() {
+		size = keyedPayloadSize
+	}
+	if len(data) != size {
+		return hashing{}, 0, errExtension
+	}
+	h.seed = binary.BigEndian.Uint32(data[1:])
+	var check uint64
+	if h.family.keyed() {
+		check = binary.BigEndian.Uint64(data[hashingPayloadSize:])
+	}
+	return h, check, nil
+}
+
+// adoptKey fills in the key of a decoded keyed ring from the ring, which must
+// have been created with the same key. The caller must hold the lock.
+func (r *Bloom) adoptKey(d *decoded) error {
+	if !d.hashing.family.keyed() {
+		return nil
+	}
+	if r.hashing.family != d.hashing.family {
+		return errNoKey
+	}
+	d.hashing.key = r.hashing.key
+	if d.hashing.keyCheck() != d.keyCheck {
+		return errKey
+	}
+	return nil
+}
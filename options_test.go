@@ -0,0 +1,204 @@
+package ring
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestInit_WithSeed ensures seeded rings place data differently, round trip
+// their seed, and refuse to combine with rings of another seed.
+func TestInit_WithSeed(t *testing.T) {
+	plain, _ := Init(1000, 0.01)
+	seeded, err := Init(1000, 0.01, WithSeed(42))
+	require.NoError(t, err)
+	data := []byte("seeded")
+	require.Equal(t, ProbePositions(42, seeded.GetK(), seeded.GetM(), data),
+		seeded.Locations(data))
+	require.NotEqual(t, plain.Locations(data), seeded.Locations(data))
+
+	seeded.Add(data)
+	require.True(t, seeded.Test(data))
+	require.True(t, seeded.TestHash(seeded.Hash(data)))
+	require.Panics(t, func() { seeded.TestHash(Hash(data)) })
+
+	out, err := seeded.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, uint8(2), out[0])
+	r := new(Bloom)
+	require.NoError(t, r.UnmarshalBinary(out))
+	require.True(t, seeded.Equal(r))
+	require.True(t, r.Test(data))
+
+	// the seed is critical, so it cannot be dropped for older readers
+	_, err = seeded.MarshalForVersion(1)
+	require.Error(t, err)
+
+	require.False(t, plain.Equal(seeded))
+	require.Equal(t, errHashing, plain.Merge(seeded))
+	require.Equal(t, errHashing, plain.MergeMany(seeded))
+	require.Equal(t, errHashing, plain.Intersect(seeded))
+
+	clone := seeded.Clone()
+	require.True(t, clone.Test(data))
+	require.NoError(t, plain.CopyFrom(seeded))
+	require.True(t, plain.Test(data))
+}
+
+// TestInit_WithRandomSeed ensures random seeds differ between rings and round
+// trip, and a failure to read randomness is returned.
+func TestInit_WithRandomSeed(t *testing.T) {
+	a, err := Init(1000, 0.01, WithRandomSeed())
+	require.NoError(t, err)
+	b, _ := Init(1000, 0.01, WithRandomSeed())
+	require.NotEqual(t, a.hashing.seed, b.hashing.seed)
+
+	a.Add([]byte("data"))
+	out, _ := a.MarshalBinary()
+	r := new(Bloom)
+	require.NoError(t, r.UnmarshalBinary(out))
+	require.True(t, r.Equal(a))
+	require.True(t, r.Test([]byte("data")))
+
+	require.NoError(t, r.setRandomSeed(bytes.NewReader([]byte{0, 0, 1, 2})))
+	require.Equal(t, uint32(0x102), r.hashing.seed)
+	require.Error(t, r.setRandomSeed(bytes.NewReader([]byte{1})))
+}
+
+// TestBloom_HashingRace ensures the hash family and seed are not read
+// unlocked while CopyFrom and UnmarshalBinary replace them. It is meaningful
+// under the race detector.
+func TestBloom_HashingRace(t *testing.T) {
+	r, _ := Init(1000, 0.01)
+	seeded, _ := Init(1000, 0.01, WithSeed(5))
+	out, _ := seeded.MarshalBinary()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			r.Add([]byte("data"))
+			r.Test([]byte("data"))
+			r.Hash([]byte("data"))
+			NewMultiTester(r).First([]byte("data"))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			require.NoError(t, r.CopyFrom(seeded))
+			require.NoError(t, r.UnmarshalBinary(out))
+		}
+	}()
+	wg.Wait()
+}
+
+// TestInit_WithHash ensures unknown hash families are rejected.
+func TestInit_WithHash(t *testing.T) {
+	r, err := Init(1000, 0.01, WithHash(HashMurmur3))
+	require.NoError(t, err)
+	plain, _ := Init(1000, 0.01)
+	require.True(t, r.Equal(plain))
+
+	_, err = Init(1000, 0.01, WithHash(HashFamily(200)))
+	require.Equal(t, errHashFamily, err)
+	_, err = InitByParameters(1000, 3, WithHash(HashFamily(200)))
+	require.Equal(t, errHashFamily, err)
+	require.Equal(t, "HashFamily(200)", HashFamily(200).String())
+
+	_, _, err = decodeHashing([]byte{200, 0, 0, 0, 0})
+	require.Equal(t, errHashFamily, err)
+	_, _, err = decodeHashing([]byte{0})
+	require.Error(t, err)
+}
+
+// TestInit_WithHashWyhash ensures wyhash rings hold their data, round trip
+// their family, and refuse to combine with murmur rings.
+func TestInit_WithHashWyhash(t *testing.T) {
+	r, err := Init(10000, 0.01, WithHash(HashWyhash), WithSeed(3))
+	require.NoError(t, err)
+	plain, _ := Init(10000, 0.01)
+	fillRange(r, 0, 10000)
+	fillRange(plain, 0, 10000)
+	buff := make([]byte, 4)
+	for i := 0; i < 10000; i++ {
+		intToByte(buff, i)
+		require.True(t, r.Test(buff), "element %d", i)
+	}
+	// the false positive rate is that of murmur
+	positives := 0
+	for i := 10000; i < 110000; i++ {
+		intToByte(buff, i)
+		if r.Test(buff) {
+			positives++
+		}
+	}
+	require.InDelta(t, 0.01, float64(positives)/100000, 0.003)
+	require.Equal(t, errHashing, r.Merge(plain))
+
+	out, err := r.MarshalBinary()
+	require.NoError(t, err)
+	u := new(Bloom)
+	require.NoError(t, u.UnmarshalBinary(out))
+	require.True(t, u.Equal(r))
+	require.Equal(t, HashWyhash, u.hashing.family)
+}
+
+// TestInit_WithKey ensures keyed rings place data by their key, and can only
+// be unmarshaled into a ring with the same key.
+func TestInit_WithKey(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	r, err := Init(1000, 0.01, WithKey(key))
+	require.NoError(t, err)
+	plain, _ := Init(1000, 0.01)
+	other, _ := Init(1000, 0.01, WithKey([]byte("fedcba9876543210")))
+	data := []byte("keyed")
+	require.NotEqual(t, plain.Locations(data), r.Locations(data))
+	require.NotEqual(t, other.Locations(data), r.Locations(data))
+	r.Add(data)
+	require.True(t, r.Test(data))
+	require.Equal(t, errHashing, r.Merge(other))
+
+	out, err := r.MarshalBinary()
+	require.NoError(t, err)
+	require.NotContains(t, string(out), string(key))
+	keyed, _ := Init(1000, 0.01, WithKey(key))
+	require.NoError(t, keyed.UnmarshalBinary(out))
+	require.True(t, keyed.Equal(r))
+	require.True(t, keyed.Test(data))
+	require.Equal(t, errNoKey, new(Bloom).UnmarshalBinary(out))
+	require.Equal(t, errNoKey, plain.UnmarshalBinary(out))
+	require.Equal(t, errKey, other.UnmarshalBinary(out))
+	require.False(t, other.Test(data))
+
+	// the seed is mixed with the key
+	seeded, _ := Init(1000, 0.01, WithKey(key), WithSeed(1))
+	require.NotEqual(t, seeded.Locations(data), r.Locations(data))
+	unkeyed, err := Init(1000, 0.01, WithKey(key), WithHash(HashMurmur3))
+	require.NoError(t, err)
+	require.True(t, unkeyed.Equal(plain))
+
+	_, err = Init(1000, 0.01, WithKey(key[:15]))
+	require.Equal(t, errKeySize, err)
+	_, err = Init(1000, 0.01, WithHash(HashSipHash))
+	require.Equal(t, errKeyed, err)
+	_, _, err = decodeHashing([]byte{uint8(HashSipHash), 0, 0, 0, 0})
+	require.Equal(t, errExtension, err)
+}
+
+// TestInit_WithLocking ensures a ring without locking works and its clones
+// keep the setting.
+func TestInit_WithLocking(t *testing.T) {
+	r, err := InitByParameters(1000, 3, WithLocking(false))
+	require.NoError(t, err)
+	require.IsType(t, noopLocker{}, r.mutex)
+	r.Add([]byte("data"))
+	require.True(t, r.Test([]byte("data")))
+	require.IsType(t, noopLocker{}, r.Clone().mutex)
+
+	locked, err := Init(1000, 0.01, WithLocking(false), WithLocking(true))
+	require.NoError(t, err)
+	require.IsType(t, &sync.RWMutex{}, locked.mutex)
+}
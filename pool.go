@@ -0,0 +1,96 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"sync"
+	"time"
+)
+
+// Pool recycles the bit arrays of short-lived rings to reduce garbage
+// collection. Rings are taken from it with Get and their bit array returned
+// with Release. Arrays are kept per size, so rings of any parameters may share
+// a Pool. It is safe for concurrent use.
+type Pool struct {
+	mutex sync.Mutex
+	pools map[uint64]*sync.Pool // by bit array length
+}
+
+// NewPool returns an empty Pool.
+func NewPool() *Pool {
+	return &Pool{pools: make(map[uint64]*sync.Pool)}
+}
+
+// Get returns a new ring as Init would, reusing a released bit array of the
+// right size if one is available.
+func (p *Pool) Get(elements int, falsePositive float64, opts ...Option) (*Bloom, error) {
+	if elements <= 0 {
+		return nil, errElements
+	}
+	if falsePositive <= 0 || falsePositive >= 1 {
+		return nil, errFalsePositive
+	}
+
+	r := &Bloom{mutex: &sync.RWMutex{}, pool: p}
+	r.created = time.Now()
+	r.resetAt = r.created
+	r.size, r.hash = optimalParameters(elements, falsePositive)
+	r.capacity = elements
+	r.falsePositive = falsePositive
+	if err := r.applyOptions(opts); err != nil {
+		return nil, err
+	}
+	if bits, ok := p.sizePool(getBuffSize(r.size)).Get().(*[]uint8); ok {
+		// released arrays are cleared before they are pooled
+		r.bits = *bits
+	} else {
+		r.bits = make([]uint8, getBuffSize(r.size))
+	}
+	return r, nil
+}
+
+// put clears the bit array and makes it available to Get.
+func (p *Pool) put(bits []uint8) {
+	for i := range bits {
+		bits[i] = 0
+	}
+	p.sizePool(uint64(len(bits))).Put(&bits)
+}
+
+// sizePool returns the pool of bit arrays of the given length.
+func (p *Pool) sizePool(length uint64) *sync.Pool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	sp, ok := p.pools[length]
+	if !ok {
+		sp = &sync.Pool{}
+		p.pools[length] = sp
+	}
+	return sp
+}
+
+// Release destroys the ring, as Transition(StateDestroyed) would, and returns
+// its bit array to the Pool it was taken from, if any. The bit array is not
+// recycled if a Frozen view shares it. The ring must not be used afterwards;
+// releasing it again does nothing.
+func (r *Bloom) Release() {
+	r.mutex.Lock()
+	if r.state == StateDestroyed {
+		r.mutex.Unlock()
+		return
+	}
+	// whether to recycle is decided under the same lock as the destruction,
+	// so a concurrent Freeze cannot share the bits in between
+	bits := r.bits
+	pool := r.pool
+	recycle := pool != nil && !r.shared
+	// the transition cannot fail, as the ring is not yet destroyed
+	_ = r.transition(StateDestroyed)
+	r.mutex.Unlock()
+
+	if recycle {
+		pool.put(bits)
+	}
+}
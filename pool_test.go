@@ -0,0 +1,85 @@
+package ring
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestPool ensures released bit arrays are cleared and reused, and shared or
+// foreign arrays are not recycled.
+func TestPool(t *testing.T) {
+	p := NewPool()
+	r, err := p.Get(1000, 0.01)
+	require.NoError(t, err)
+	plain, _ := Init(1000, 0.01)
+	require.True(t, plain.Equal(r))
+	require.Equal(t, 1000, r.Capacity())
+
+	r.Add([]byte("data"))
+	bits := &r.bits[0]
+	r.Release()
+	require.Equal(t, StateDestroyed, r.State())
+	r.Release()
+
+	// sync.Pool may drop items at any time, so only check reuse if it happened
+	r2, err := p.Get(1000, 0.01)
+	require.NoError(t, err)
+	require.True(t, r2.IsEmpty())
+	if &r2.bits[0] == bits {
+		require.Zero(t, r2.Digest())
+	}
+
+	f, _ := r2.Freeze()
+	r2.Release()
+	require.False(t, f.Test([]byte("data")))
+	r3, _ := p.Get(1000, 0.01)
+	require.True(t, &f.r.bits[0] != &r3.bits[0])
+
+	_, err = p.Get(0, 0.01)
+	require.Error(t, err)
+	_, err = p.Get(10, 1)
+	require.Error(t, err)
+	_, err = p.Get(10, 0.01, WithHash(HashFamily(9)))
+	require.Error(t, err)
+
+	// rings not taken from a pool are only destroyed
+	plain.Release()
+	require.Equal(t, StateDestroyed, plain.State())
+}
+
+// BenchmarkPool measures creating and releasing a short-lived ring.
+func BenchmarkPool(b *testing.B) {
+	p := NewPool()
+	for i := 0; i < b.N; i++ {
+		r, _ := p.Get(10000, 0.01)
+		r.Add([]byte("data"))
+		r.Release()
+	}
+}
+
+// TestPool_ReleaseFreeze ensures a ring frozen concurrently with its release
+// never has its bits recycled and cleared under the view.
+func TestPool_ReleaseFreeze(t *testing.T) {
+	p := NewPool()
+	for i := 0; i < 200; i++ {
+		r, _ := p.Get(1000, 0.01)
+		r.Add([]byte("data"))
+		var f *Frozen
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			f, _ = r.Freeze()
+		}()
+		go func() {
+			defer wg.Done()
+			r.Release()
+		}()
+		wg.Wait()
+		if f != nil {
+			require.True(t, f.Test([]byte("data")), "iteration %d", i)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var errProvenance = errors.New("error: malformed provenance extension")
+
+// maxMerges and maxContributors are the number of merge digests and
+// contributor IDs kept in the provenance of a ring, so that a ring merged
+// periodically does not grow its header without bound.
+const (
+	maxMerges       = 64
+	maxContributors = 64
+)
+
+// Provenance records where the contents of an aggregated ring came from. It is
+// carried through MarshalBinary and UnmarshalBinary in an extension section,
+// so it is only as trustworthy as the nodes that forwarded the ring.
+type Provenance struct {
+	// Contributors contains the IDs of the nodes that added to the ring,
+	// directly or through a ring merged into it, in the order first seen.
+	// Once there are 64, the oldest is dropped for each new one.
+	Contributors [][]byte
+	// Merges contains the digest of each ring merged into this one at the
+	// time of the merge, oldest first. Only the most recent 64 are kept.
+	Merges []uint64
+}
+
+// AddContributor records id as a contributor to the ring, if it is not one
+// already. A ring with contributors records the provenance of every ring
+// merged into it. Like Add, it panics if the ring may not be modified.
+func (r *Bloom) AddContributor(id []byte) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.mustBeMutable("annotate")
+	r.provenance.addContributor(id)
+}
+
+// Provenance returns a copy of the provenance records of the ring.
+func (r *Bloom) Provenance() Provenance {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	r.mustBeUsable("read")
+	return r.provenance.clone()
+}
+
+// empty returns true if there is nothing recorded.
+func (p *Provenance) empty() bool {
+	return len(p.Contributors) == 0 && len(p.Merges) == 0
+}
+
+// addContributor appends a copy of id if it is not already a contributor,
+// dropping the oldest if there are too many.
+func (p *Provenance) addContributor(id []byte) {
+	for _, c := range p.Contributors {
+		if bytes.Equal(c, id) {
+			return
+		}
+	}
+	if len(p.Contributors) >= maxContributors {
+		copy(p.Contributors, p.Contributors[len(p.Contributors)-maxContributors+1:])
+		p.Contributors = p.Contributors[:maxContributors-1]
+	}
+	p.Contributors = append(p.Contributors, append([]byte{}, id...))
+}
+
+// merge records that a ring with the given provenance and digest was merged
+// into the ring holding p, dropping the oldest digest if there are too many.
+func (p *Provenance) merge(other *Provenance, digest uint64) {
+	for _, c := range other.Contributors {
+		p.addContributor(c)
+	}
+	if len(p.Merges) >= maxMerges {
+		copy(p.Merges, p.Merges[len(p.Merges)-maxMerges+1:])
+		p.Merges = p.Merges[:maxMerges-1]
+	}
+	p.Merges = append(p.Merges, digest)
+}
+
+// clone returns a deep copy of the provenance.
+func (p *Provenance) clone() Provenance {
+	var c Provenance
+	for _, id := range p.Contributors {
+		c.Contributors = append(c.Contributors, append([]byte{}, id...))
+	}
+	c.Merges = append(c.Merges, p.Merges...)
+	return c
+}
+
+// encode returns the payload of the provenance extension: the number of
+// contributors followed by each as a length prefixed ID, then the number of
+// merges followed by each digest, with counts and lengths as uvarints.
+func (p *Provenance) encode() []byte {
+	var buff [binary.MaxVarintLen64]byte
+	var out []byte
+	out = append(out, buff[:binary.PutUvarint(buff[:], uint64(len(p.Contributors)))]...)
+	for _, id := range p.Contributors {
+		out = append(out, buff[:binary.PutUvarint(buff[:], uint64(len(id)))]...)
+		out = append(out, id...)
+	}
+	out = append(out, buff[:binary.PutUvarint(buff[:], uint64(len(p.Merges)))]...)
+	for _, digest := range p.Merges {
+		binary.BigEndian.PutUint64(buff[:8], digest)
+		out = append(out, buff[:8]...)
+	}
+	return out
+}
+
+// decodeProvenance parses the payload of the provenance extension.
+func decodeProvenance(data []byte) (Provenance, error) {
+	var p Provenance
+	count, n := binary.Uvarint(data)
+	// every contributor takes at least a byte
+	if n <= 0 || count > uint64(len(data)-n) {
+		return Provenance{}, errProvenance
+	}
+	data = data[n:]
+	for i := uint64(0); i < count; i++ {
+		length, n := binary.Uvarint(data)
+		if n <= 0 || length > uint64(len(data)-n) {
+			return Provenance{}, errProvenance
+		}
+		data = data[n:]
+		// keep only the most recent distinct IDs, as merge does
+		p.addContributor(data[:length])
+		data = data[length:]
+	}
+
+	count, n = binary.Uvarint(data)
+	if n <= 0 || count > uint64(len(data)-n)/8 {
+		return Provenance{}, errProvenance
+	}
+	data = data[n:]
+	// keep only the most recent digests, as merge does
+	first := uint64(0)
+	if count > maxMerges {
+		first = count - maxMerges
+	}
+	for i := first; i < count; i++ {
+		p.Merges = append(p.Merges, binary.BigEndian.Uint64(data[i*8:]))
+	}
+	return p, nil
+}
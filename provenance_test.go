@@ -0,0 +1,110 @@
+package ring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestBloom_Provenance ensures contributors and merges are recorded and
+// survive marshaling.
+func TestBloom_Provenance(t *testing.T) {
+	a, _ := Init(1000, 0.01)
+	b, _ := Init(1000, 0.01)
+	c, _ := Init(1000, 0.01)
+	a.AddContributor([]byte("gateway-a"))
+	a.AddContributor([]byte("gateway-a"))
+	b.AddContributor([]byte("gateway-b"))
+	b.AddContributor([]byte("gateway-a"))
+	a.Add([]byte("a"))
+	b.Add([]byte("b"))
+	c.Add([]byte("c"))
+
+	require.NoError(t, a.Merge(b))
+	require.NoError(t, a.Merge(c))
+	p := a.Provenance()
+	require.Equal(t, [][]byte{[]byte("gateway-a"), []byte("gateway-b")},
+		p.Contributors)
+	require.Equal(t, []uint64{b.Digest(), c.Digest()}, p.Merges)
+
+	// the result is a copy
+	p.Contributors[0][0] = 'x'
+	require.Equal(t, []byte("gateway-a"), a.Provenance().Contributors[0])
+
+	out, err := a.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, uint8(2), out[0])
+	r := new(Bloom)
+	require.NoError(t, r.UnmarshalBinary(out))
+	require.True(t, a.Equal(r))
+	require.Equal(t, a.Provenance(), r.Provenance())
+	require.True(t, r.Test([]byte("c")))
+
+	require.Equal(t, a.Provenance(), a.Clone().Provenance())
+	a.Reset()
+	require.Equal(t, Provenance{}, a.Provenance())
+}
+
+// TestBloom_ProvenanceNone ensures rings without provenance do not record
+// merges and still marshal in version 1.
+func TestBloom_ProvenanceNone(t *testing.T) {
+	a, _ := Init(1000, 0.01)
+	b, _ := Init(1000, 0.01)
+	require.NoError(t, a.Merge(b))
+	require.Equal(t, Provenance{}, a.Provenance())
+
+	out, err := a.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, uint8(1), out[0])
+	require.Len(t, out, headerSize+a.BufferSize())
+}
+
+// TestProvenance_MergesCapped ensures only the most recent merges are kept,
+// whether recorded by merging or decoded from a longer list.
+func TestProvenance_MergesCapped(t *testing.T) {
+	var p Provenance
+	var all []uint64
+	for i := uint64(0); i < 3*maxMerges; i++ {
+		p.merge(&Provenance{}, i)
+		all = append(all, i)
+	}
+	require.Equal(t, all[len(all)-maxMerges:], p.Merges)
+
+	decoded, err := decodeProvenance((&Provenance{Merges: all}).encode())
+	require.NoError(t, err)
+	require.Equal(t, p.Merges, decoded.Merges)
+}
+
+// TestProvenance_ContributorsCapped ensures only the most recent distinct
+// contributors are kept, whether recorded by merging or decoded from a longer
+// list.
+func TestProvenance_ContributorsCapped(t *testing.T) {
+	var p Provenance
+	var all [][]byte
+	for i := 0; i < 3*maxContributors; i++ {
+		id := []byte{byte(i >> 8), byte(i)}
+		p.merge(&Provenance{Contributors: [][]byte{id, id}}, 0)
+		all = append(all, id)
+	}
+	require.Equal(t, all[len(all)-maxContributors:], p.Contributors)
+
+	decoded, err := decodeProvenance((&Provenance{Contributors: all}).encode())
+	require.NoError(t, err)
+	require.Equal(t, p.Contributors, decoded.Contributors)
+}
+
+// TestDecodeProvenance_Malformed ensures truncated payloads are rejected.
+func TestDecodeProvenance_Malformed(t *testing.T) {
+	p := Provenance{Contributors: [][]byte{[]byte("id")}, Merges: []uint64{1, 2}}
+	payload := p.encode()
+	decoded, err := decodeProvenance(payload)
+	require.NoError(t, err)
+	require.Equal(t, p, decoded)
+
+	for i := 0; i < len(payload); i++ {
+		_, err := decodeProvenance(payload[:i])
+		require.Error(t, err, "length %d", i)
+	}
+	_, err = decodeProvenance([]byte{0xff, 0xff, 0xff, 0xff, 0x0f})
+	require.Error(t, err)
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import "errors"
+
+var (
+	errQuorum     = errors.New("error: quorum must be greater than 0 and at most the number of replicas")
+	errNilReplica = errors.New("error: replicas must not be nil")
+)
+
+// Tester is implemented by filters that report if data may have been added.
+type Tester interface {
+	Test(data []byte) bool
+}
+
+// TestQuorum tests the data against every replica concurrently and returns
+// true once at least quorum replicas report that the data may be present, or
+// false once that is no longer possible. It returns an error if quorum is not
+// in [1, len(replicas)] or a replica is nil.
+func TestQuorum(replicas []Tester, data []byte, quorum int) (bool, error) {
+	if quorum <= 0 || quorum > len(replicas) {
+		return false, errQuorum
+	}
+	for _, replica := range replicas {
+		if replica == nil {
+			return false, errNilReplica
+		}
+	}
+
+	// buffered so that replicas answering after the decision do not block
+	results := make(chan bool, len(replicas))
+	for _, replica := range replicas {
+		go func(replica Tester) {
+			results <- replica.Test(data)
+		}(replica)
+	}
+
+	present, absent := 0, 0
+	for range replicas {
+		if <-results {
+			present++
+		} else {
+			absent++
+		}
+		if present >= quorum {
+			return true, nil
+		}
+		if absent > len(replicas)-quorum {
+			return false, nil
+		}
+	}
+	return false, nil
+}
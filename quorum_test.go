@@ -0,0 +1,45 @@
+package ring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestTestQuorum ensures a stale replica is masked by the quorum.
+func TestTestQuorum(t *testing.T) {
+	data := []byte("message")
+	stale, _ := Init(100, fpRate)
+	replicas := make([]Tester, 3)
+	for i := range replicas {
+		r, _ := Init(100, fpRate)
+		r.Add(data)
+		replicas[i] = r
+	}
+
+	replicas[1] = stale
+	found, err := TestQuorum(replicas, data, 2)
+	require.NoError(t, err)
+	require.True(t, found)
+
+	found, err = TestQuorum(replicas, data, 3)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	found, err = TestQuorum(replicas, []byte("other"), 1)
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+// TestTestQuorum_BadParameters ensures that invalid arguments return an error.
+func TestTestQuorum_BadParameters(t *testing.T) {
+	r, _ := Init(100, fpRate)
+	replicas := []Tester{r, r}
+
+	_, err := TestQuorum(replicas, nil, 0)
+	require.Error(t, err)
+	_, err = TestQuorum(replicas, nil, 3)
+	require.Error(t, err)
+	_, err = TestQuorum([]Tester{r, nil}, nil, 1)
+	require.Error(t, err)
+}
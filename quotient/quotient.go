@@ -0,0 +1,608 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package quotient provides a thread safe quotient filter. It stores a
+// fingerprint of each element in a single contiguous table, which is cache
+// friendly, supports deletion, and can be resized and merged using the stored
+// fingerprints alone, without the original data.
+package quotient
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	ring "gitlab.com/elixxir/bloomfilter"
+)
+
+var (
+	errCapacity      = errors.New("error: capacity must be greater than 0")
+	errFalsePositive = errors.New("error: falsePositive must be greater than 0 and less than 1")
+	errFingerprint   = errors.New("error: q and r must be at least 1, r at most 61, and q+r at most 64")
+	errFull          = errors.New("error: the filter is full")
+	errResize        = errors.New("error: the filter has no remainder bits left to resize")
+	errParameters    = errors.New("filters must have the same fingerprint size")
+	errTable         = errors.New("error: the table metadata is inconsistent")
+)
+
+const (
+	// maxLoad is the fraction of slots Init and Merge size the filter to
+	// fill, beyond which runs grow long and operations slow down.
+	maxLoad = 0.75
+	// headerSize is the number of bytes preceding the table in the output of
+	// MarshalBinary: 1 byte of version, 1 byte each of quotient and remainder
+	// bits, and 8 bytes of entries.
+	headerSize = 11
+)
+
+// The three low bits of a slot are its metadata, and the rest its remainder.
+const (
+	occupied     = 1 << 0 // a run for the quotient of this slot exists
+	continuation = 1 << 1 // the remainder continues the run of the slot before
+	shifted      = 1 << 2 // the remainder is not in its canonical slot
+	metaBits     = 3
+)
+
+// QuotientFilter is a quotient filter. The p-bit fingerprint of each element
+// is split into a quotient of q bits, the slot the fingerprint belongs in, and
+// a remainder of r bits that is stored. Remainders with the same quotient are
+// kept sorted in a run, and overlapping runs are shifted along into clusters,
+// which the metadata bits of each slot allow to be decoded.
+type QuotientFilter struct {
+	q, r    uint     // bits of quotient and remainder
+	entries uint64   // number of fingerprints stored
+	table   []uint64 // 2^q slots of r+3 bits, packed
+	mutex   *sync.RWMutex
+}
+
+// Init initializes and returns a new quotient filter, or an error. Given a
+// capacity, it accurately states if data is not added. Within a falsePositive
+// rate, it will indicate if the data has been added.
+func Init(capacity int, falsePositive float64) (*QuotientFilter, error) {
+	if capacity <= 0 {
+		return nil, errCapacity
+	}
+	if falsePositive <= 0 || falsePositive >= 1 {
+		return nil, errFalsePositive
+	}
+	q := uint(math.Ceil(math.Log2(float64(capacity) / maxLoad)))
+	r := uint(math.Ceil(-math.Log2(falsePositive)))
+	return InitByParameters(q, r)
+}
+
+// InitByParameters initializes and returns a new quotient filter with 2^q
+// slots and r bits of remainder, or an error. Both must be at least 1, r at
+// most 61, and their sum at most 64.
+func InitByParameters(q, r uint) (*QuotientFilter, error) {
+	if q == 0 || r == 0 || r > 64-metaBits || q+r > 64 {
+		return nil, errFingerprint
+	}
+	return &QuotientFilter{
+		q:     q,
+		r:     r,
+		table: make([]uint64, tableWords(q, r)),
+		mutex: &sync.RWMutex{},
+	}, nil
+}
+
+// Add adds the data to the filter. It returns an error if the filter is full.
+// Data with the same fingerprint as data already added is not stored again.
+func (f *QuotientFilter) Add(data []byte) error {
+	h, _ := ring.Sum128(data, 0)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	fp := f.fingerprint(h)
+	return f.insert(fp)
+}
+
+// Test returns a bool if the data is in the filter. True indicates that the
+// data may be in the filter, while false indicates that it is not.
+func (f *QuotientFilter) Test(data []byte) bool {
+	h, _ := ring.Sum128(data, 0)
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	fp := f.fingerprint(h)
+	fq, fr := f.split(fp)
+	if f.get(fq)&occupied == 0 {
+		return false
+	}
+	s := f.findRun(fq)
+	for {
+		rem := f.get(s) >> metaBits
+		if rem == fr {
+			return true
+		}
+		if rem > fr {
+			return false
+		}
+		s = f.incr(s)
+		if f.get(s)&continuation == 0 {
+			return false
+		}
+	}
+}
+
+// Delete removes the data from the filter and returns true, or returns false
+// if the data is not in the filter. Deleting data removes every element with
+// the same fingerprint, and deleting data that was never added, but is
+// reported present as a false positive, removes another element.
+func (f *QuotientFilter) Delete(data []byte) bool {
+	h, _ := ring.Sum128(data, 0)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	fp := f.fingerprint(h)
+	return f.remove(fp)
+}
+
+// Count returns the number of fingerprints in the filter.
+func (f *QuotientFilter) Count() uint64 {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.entries
+}
+
+// Capacity returns the number of slots in the filter.
+func (f *QuotientFilter) Capacity() uint64 {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.size()
+}
+
+// Resize doubles the number of slots in the filter, moving one bit of each
+// fingerprint from the remainder to the quotient. The fingerprints are kept,
+// so the data is not needed, but each halving of the remainder doubles the
+// false positive rate. It returns an error if the remainder has a single bit.
+func (f *QuotientFilter) Resize() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.resize()
+}
+
+// Merge adds the fingerprints of other to the filter, first resizing it as
+// many times as needed to keep it at most three quarters full. The
+// fingerprints of both filters must be the same size. Other is read under its
+// lock before the filter is locked, so a concurrent Add to it is either
+// fully included or not at all.
+func (f *QuotientFilter) Merge(other *QuotientFilter) error {
+	if f == other {
+		return nil
+	}
+	other.mutex.RLock()
+	p := other.q + other.r
+	fps := other.fingerprints()
+	other.mutex.RUnlock()
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.q+f.r != p {
+		return errParameters
+	}
+	for float64(f.entries+uint64(len(fps))) > maxLoad*float64(f.size()) {
+		if err := f.resize(); err != nil {
+			return err
+		}
+	}
+	for _, fp := range fps {
+		if err := f.insert(fp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface. The output
+// is 1 byte of version, 1 byte each of quotient and remainder bits, the
+// number of entries as 8 bytes, then the packed table as 8-byte words.
+// Integers are big endian.
+func (f *QuotientFilter) MarshalBinary() ([]byte, error) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	out := make([]byte, headerSize+8*len(f.table))
+	out[0] = 1
+	out[1] = uint8(f.q)
+	out[2] = uint8(f.r)
+	binary.BigEndian.PutUint64(out[3:11], f.entries)
+	for i, w := range f.table {
+		binary.BigEndian.PutUint64(out[headerSize+8*i:], w)
+	}
+	return out, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (f *QuotientFilter) UnmarshalBinary(data []byte) error {
+	if len(data) < headerSize {
+		return fmt.Errorf("incorrect length: %d", len(data))
+	}
+	if data[0] != 1 {
+		return fmt.Errorf("unexpected version: %d", data[0])
+	}
+	q, r := uint(data[1]), uint(data[2])
+	if q == 0 || r == 0 || r > 64-metaBits || q+r > 64 || q > 40 {
+		return errFingerprint
+	}
+	if uint64(len(data)-headerSize) != 8*tableWords(q, r) {
+		return fmt.Errorf("incorrect length: %d", len(data))
+	}
+	entries := binary.BigEndian.Uint64(data[3:11])
+	if entries > 1<<q {
+		return fmt.Errorf("invalid entries: %d", entries)
+	}
+
+	if f.mutex == nil {
+		f.mutex = new(sync.RWMutex)
+	}
+	decoded := QuotientFilter{q: q, r: r, entries: entries,
+		table: make([]uint64, tableWords(q, r))}
+	for i := range decoded.table {
+		decoded.table[i] = binary.BigEndian.Uint64(data[headerSize+8*i:])
+	}
+	if !decoded.consistent() {
+		return errTable
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.q = q
+	f.r = r
+	f.entries = entries
+	f.table = decoded.table
+	return nil
+}
+
+// consistent returns true if the metadata of the table can be decoded: the
+// entries are the non-empty slots, every occupied quotient has a run, and a
+// cluster starts somewhere, so that no walk of the table goes on forever.
+func (f *QuotientFilter) consistent() bool {
+	var used, occupiedSlots, runs uint64
+	unshifted := false
+	for s := uint64(0); s < f.size(); s++ {
+		e := f.get(s)
+		if e&(occupied|continuation|shifted) == 0 {
+			if e != 0 {
+				// an empty slot has no remainder
+				return false
+			}
+			unshifted = true
+			continue
+		}
+		used++
+		if e&occupied != 0 {
+			occupiedSlots++
+		}
+		if isRunStart(e) {
+			runs++
+		}
+		if e&shifted == 0 {
+			unshifted = true
+		}
+	}
+	return used == f.entries && occupiedSlots == runs && unshifted &&
+		(used == 0 || runs > 0)
+}
+
+// fingerprint returns the q+r bit fingerprint of the hash of some data. The
+// caller must hold the lock, as resizing changes q and r.
+func (f *QuotientFilter) fingerprint(h uint64) uint64 {
+	return h & mask(f.q+f.r)
+}
+
+// split returns the quotient and remainder of the fingerprint.
+func (f *QuotientFilter) split(fp uint64) (uint64, uint64) {
+	return fp >> f.r & mask(f.q), fp & mask(f.r)
+}
+
+// insert adds the fingerprint to the filter. The caller must hold the write
+// lock.
+func (f *QuotientFilter) insert(fp uint64) error {
+	if f.entries >= f.size() {
+		return errFull
+	}
+	fq, fr := f.split(fp)
+	canonical := f.get(fq)
+	entry := fr << metaBits
+
+	if canonical&(occupied|continuation|shifted) == 0 {
+		f.set(fq, entry|occupied)
+		f.entries++
+		return nil
+	}
+	if canonical&occupied == 0 {
+		f.set(fq, canonical|occupied)
+	}
+
+	start := f.findRun(fq)
+	s := start
+	if canonical&occupied != 0 {
+		// find the place of the remainder in the sorted run
+		for {
+			rem := f.get(s) >> metaBits
+			if rem == fr {
+				return nil
+			}
+			if rem > fr {
+				break
+			}
+			s = f.incr(s)
+			if f.get(s)&continuation == 0 {
+				break
+			}
+		}
+		if s == start {
+			// the old start of the run becomes a continuation
+			f.set(start, f.get(start)|continuation)
+		} else {
+			entry |= continuation
+		}
+	}
+	if s != fq {
+		entry |= shifted
+	}
+	f.shiftInto(s, entry)
+	f.entries++
+	return nil
+}
+
+// shiftInto puts the entry in slot s, shifting the entries from s up to the
+// next empty slot along by one. Occupied bits belong to slots, not entries,
+// so stay where they are.
+func (f *QuotientFilter) shiftInto(s uint64, entry uint64) {
+	curr := entry
+	for {
+		prev := f.get(s)
+		empty := prev&(occupied|continuation|shifted) == 0
+		if !empty {
+			prev |= shifted
+			if prev&occupied != 0 {
+				curr |= occupied
+				prev &^= occupied
+			}
+		}
+		f.set(s, curr)
+		curr = prev
+		s = f.incr(s)
+		if empty {
+			return
+		}
+	}
+}
+
+// remove deletes the fingerprint from the filter and returns true, or returns
+// false if it is not in the filter. The caller must hold the write lock.
+func (f *QuotientFilter) remove(fp uint64) bool {
+	fq, fr := f.split(fp)
+	canonical := f.get(fq)
+	if canonical&occupied == 0 || f.entries == 0 {
+		return false
+	}
+
+	s := f.findRun(fq)
+	for {
+		rem := f.get(s) >> metaBits
+		if rem == fr {
+			break
+		}
+		if rem > fr {
+			return false
+		}
+		s = f.incr(s)
+		if f.get(s)&continuation == 0 {
+			return false
+		}
+	}
+
+	kill := f.get(s)
+	runStart := isRunStart(kill)
+	// deleting the only entry of the run clears its occupied bit
+	if runStart && f.get(f.incr(s))&continuation == 0 {
+		f.set(fq, f.get(fq)&^occupied)
+	}
+	f.deleteEntry(s, fq)
+
+	if runStart {
+		next := f.get(s)
+		updated := next
+		if next&continuation != 0 {
+			// the new start of the run is no longer a continuation
+			updated &^= continuation
+		}
+		if s == fq && isRunStart(updated) {
+			// the new start of the run is in its canonical slot
+			updated &^= shifted
+		}
+		if updated != next {
+			f.set(s, updated)
+		}
+	}
+	f.entries--
+	return true
+}
+
+// deleteEntry removes the entry in slot s, belonging to quotient quot, by
+// shifting the rest of its cluster back by one slot.
+func (f *QuotientFilter) deleteEntry(s, quot uint64) {
+	curr := f.get(s)
+	orig := s
+	for sp := f.incr(s); ; sp = f.incr(sp) {
+		next := f.get(sp)
+		currOccupied := curr&occupied != 0
+		if next&(occupied|continuation|shifted) == 0 || isClusterStart(next) ||
+			sp == orig {
+			f.set(s, 0)
+			return
+		}
+		updated := next
+		if isRunStart(next) {
+			// find the quotient of the next run, whose entries may slide
+			// into their canonical slot
+			for {
+				quot = f.incr(quot)
+				if f.get(quot)&occupied != 0 {
+					break
+				}
+			}
+			if currOccupied && quot == s {
+				updated &^= shifted
+			}
+		}
+		if currOccupied {
+			updated |= occupied
+		} else {
+			updated &^= occupied
+		}
+		f.set(s, updated)
+		s = sp
+		curr = next
+	}
+}
+
+// findRun returns the slot at which the run of the quotient fq starts. The
+// quotient must be occupied.
+func (f *QuotientFilter) findRun(fq uint64) uint64 {
+	// walk back to the start of the cluster, which is at most a lap away
+	b := fq
+	for n := uint64(0); f.get(b)&shifted != 0 && n < f.size(); n++ {
+		b = f.decr(b)
+	}
+	// walk forward run by run, and quotient by quotient, until fq
+	s := b
+	for b != fq {
+		for {
+			s = f.incr(s)
+			if f.get(s)&continuation == 0 {
+				break
+			}
+		}
+		for {
+			b = f.incr(b)
+			if f.get(b)&occupied != 0 {
+				break
+			}
+		}
+	}
+	return s
+}
+
+// fingerprints returns every fingerprint in the filter, in no particular
+// order. The caller must hold the read lock.
+func (f *QuotientFilter) fingerprints() []uint64 {
+	out := make([]uint64, 0, f.entries)
+	if f.entries == 0 {
+		return out
+	}
+	// start at the start of a cluster, whose quotient is its slot
+	start := uint64(0)
+	for e := f.get(start); (e&(occupied|continuation|shifted) == 0 ||
+		e&shifted != 0) && start < f.size()-1; e = f.get(start) {
+		start++
+	}
+	quot := start
+	for n := uint64(0); n < f.size(); n++ {
+		s := (start + n) & f.slotMask()
+		e := f.get(s)
+		if e&(occupied|continuation|shifted) == 0 {
+			continue
+		}
+		if n > 0 && e&continuation == 0 {
+			if e&shifted == 0 {
+				quot = s
+			} else {
+				for {
+					quot = f.incr(quot)
+					if f.get(quot)&occupied != 0 {
+						break
+					}
+				}
+			}
+		}
+		out = append(out, quot<<f.r|e>>metaBits)
+	}
+	return out
+}
+
+// resize doubles the number of slots. The caller must hold the write lock.
+func (f *QuotientFilter) resize() error {
+	if f.r == 1 {
+		return errResize
+	}
+	fps := f.fingerprints()
+	f.q++
+	f.r--
+	f.entries = 0
+	f.table = make([]uint64, tableWords(f.q, f.r))
+	for _, fp := range fps {
+		// fingerprints distinct before are still distinct, so this
+		// cannot fill the larger table
+		_ = f.insert(fp)
+	}
+	return nil
+}
+
+// size returns the number of slots.
+func (f *QuotientFilter) size() uint64 {
+	return 1 << f.q
+}
+
+// slotMask returns the mask of a slot index.
+func (f *QuotientFilter) slotMask() uint64 {
+	return mask(f.q)
+}
+
+// incr returns the slot after s.
+func (f *QuotientFilter) incr(s uint64) uint64 {
+	return (s + 1) & f.slotMask()
+}
+
+// decr returns the slot before s.
+func (f *QuotientFilter) decr(s uint64) uint64 {
+	return (s - 1) & f.slotMask()
+}
+
+// get returns the contents of slot s.
+func (f *QuotientFilter) get(s uint64) uint64 {
+	width := f.r + metaBits
+	bit := s * uint64(width)
+	word, off := bit/64, uint(bit%64)
+	v := f.table[word] >> off
+	if off+width > 64 {
+		v |= f.table[word+1] << (64 - off)
+	}
+	return v & mask(width)
+}
+
+// set replaces the contents of slot s with v.
+func (f *QuotientFilter) set(s uint64, v uint64) {
+	width := f.r + metaBits
+	bit := s * uint64(width)
+	word, off := bit/64, uint(bit%64)
+	f.table[word] = f.table[word]&^(mask(width)<<off) | v<<off
+	if off+width > 64 {
+		rest := off + width - 64
+		f.table[word+1] = f.table[word+1]&^mask(rest) | v>>(64-off)
+	}
+}
+
+// isRunStart returns true if the slot contents start a run.
+func isRunStart(e uint64) bool {
+	return e&continuation == 0 && e&(occupied|shifted) != 0
+}
+
+// isClusterStart returns true if the slot contents start a cluster.
+func isClusterStart(e uint64) bool {
+	return e&occupied != 0 && e&(continuation|shifted) == 0
+}
+
+// tableWords returns the number of words holding 2^q slots of r+3 bits.
+func tableWords(q, r uint) uint64 {
+	return ((uint64(1)<<q)*uint64(r+metaBits) + 63) / 64
+}
+
+// mask returns a mask of the low n bits.
+func mask(n uint) uint64 {
+	if n >= 64 {
+		return math.MaxUint64
+	}
+	return 1<<n - 1
+}
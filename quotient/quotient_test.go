@@ -0,0 +1,178 @@
+package quotient
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	ring "gitlab.com/elixxir/bloomfilter"
+)
+
+func key(i int) []byte {
+	buff := make([]byte, 4)
+	binary.BigEndian.PutUint32(buff, uint32(i))
+	return buff
+}
+
+// sorted returns the fingerprints of the filter in increasing order.
+func sorted(f *QuotientFilter) []uint64 {
+	fps := f.fingerprints()
+	sort.Slice(fps, func(i, j int) bool { return fps[i] < fps[j] })
+	return fps
+}
+
+// TestQuotientFilter_Model ensures random inserts and removes of fingerprints
+// in a small, crowded filter match a set of the fingerprints.
+func TestQuotientFilter_Model(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	f, _ := InitByParameters(5, 3)
+	model := map[uint64]bool{}
+	for i := 0; i < 20000; i++ {
+		fp := rng.Uint64() & mask(8)
+		if rng.Intn(2) == 0 && len(model) < 30 {
+			require.NoError(t, f.insert(fp))
+			model[fp] = true
+		} else {
+			require.Equal(t, model[fp], f.remove(fp), "op %d", i)
+			delete(model, fp)
+		}
+		require.Equal(t, uint64(len(model)), f.entries, "op %d", i)
+
+		want := make([]uint64, 0, len(model))
+		for fp := range model {
+			want = append(want, fp)
+		}
+		sort.Slice(want, func(i, j int) bool { return want[i] < want[j] })
+		require.Equal(t, want, sorted(f), "op %d", i)
+	}
+}
+
+// TestQuotientFilter ensures added data is found until it is deleted, and that
+// the false positive rate is within the target.
+func TestQuotientFilter(t *testing.T) {
+	f, err := Init(10000, 0.01)
+	require.NoError(t, err)
+	for i := 0; i < 10000; i++ {
+		require.NoError(t, f.Add(key(i)))
+	}
+	for i := 0; i < 10000; i++ {
+		require.True(t, f.Test(key(i)), "element %d", i)
+	}
+	positives := 0
+	for i := 10000; i < 110000; i++ {
+		if f.Test(key(i)) {
+			positives++
+		}
+	}
+	require.True(t, float64(positives)/100000 < 0.01, "false positives %d",
+		positives)
+
+	// deleting data removes any other data with the same fingerprint
+	deleted := map[uint64]bool{}
+	for i := 0; i < 5000; i++ {
+		h, _ := ring.Sum128(key(i), 0)
+		if !deleted[f.fingerprint(h)] {
+			require.True(t, f.Delete(key(i)), "element %d", i)
+			deleted[f.fingerprint(h)] = true
+		}
+	}
+	for i := 5000; i < 10000; i++ {
+		h, _ := ring.Sum128(key(i), 0)
+		require.Equal(t, !deleted[f.fingerprint(h)], f.Test(key(i)),
+			"element %d", i)
+	}
+}
+
+// TestQuotientFilter_Full ensures a full filter rejects inserts.
+func TestQuotientFilter_Full(t *testing.T) {
+	f, _ := InitByParameters(3, 8)
+	for i := uint64(0); i < 8; i++ {
+		require.NoError(t, f.insert(i<<8|i))
+	}
+	require.Equal(t, errFull, f.insert(1))
+	require.Equal(t, uint64(8), f.Count())
+}
+
+// TestQuotientFilter_ResizeMerge ensures resizing and merging keep every
+// element without the data.
+func TestQuotientFilter_ResizeMerge(t *testing.T) {
+	a, _ := Init(1000, 0.001)
+	b, _ := Init(1000, 0.001)
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, a.Add(key(i)))
+		require.NoError(t, b.Add(key(i+1000)))
+	}
+	slots := a.Capacity()
+	require.NoError(t, a.Resize())
+	require.Equal(t, 2*slots, a.Capacity())
+	for i := 0; i < 1000; i++ {
+		require.True(t, a.Test(key(i)), "element %d", i)
+	}
+
+	require.NoError(t, a.Merge(b))
+	require.NoError(t, a.Merge(a))
+	require.Equal(t, uint64(2000), a.Count())
+	for i := 0; i < 2000; i++ {
+		require.True(t, a.Test(key(i)), "element %d", i)
+	}
+
+	c, _ := InitByParameters(10, 5)
+	require.Equal(t, errParameters, a.Merge(c))
+	d, _ := InitByParameters(10, 1)
+	require.Equal(t, errResize, d.Resize())
+}
+
+// TestQuotientFilter_Marshal ensures the filter round trips and bad data is
+// rejected.
+func TestQuotientFilter_Marshal(t *testing.T) {
+	f, _ := Init(100, 0.01)
+	for i := 0; i < 100; i++ {
+		f.Add(key(i))
+	}
+	out, err := f.MarshalBinary()
+	require.NoError(t, err)
+
+	u := new(QuotientFilter)
+	require.NoError(t, u.UnmarshalBinary(out))
+	require.Equal(t, sorted(f), sorted(u))
+	require.Equal(t, f.Count(), u.Count())
+
+	require.Error(t, u.UnmarshalBinary(out[:len(out)-1]))
+	require.Error(t, u.UnmarshalBinary(out[:headerSize-1]))
+	bad := append([]byte{}, out...)
+	bad[2] = 62
+	require.Error(t, u.UnmarshalBinary(bad))
+	bad[2], bad[0] = out[2], 2
+	require.Error(t, u.UnmarshalBinary(bad))
+	bad = append([]byte{}, out...)
+	bad[10]++
+	require.Equal(t, errTable, u.UnmarshalBinary(bad))
+
+	// every slot shifted, which would never find the start of a cluster
+	full := make([]byte, headerSize+8*tableWords(4, 5))
+	full[0], full[1], full[2] = 1, 4, 5
+	binary.BigEndian.PutUint64(full[3:11], 16)
+	for i := headerSize; i < len(full); i++ {
+		full[i] = 0xff
+	}
+	require.Equal(t, errTable, u.UnmarshalBinary(full))
+
+	// entries without any in the table
+	empty := make([]byte, headerSize+8*tableWords(4, 5))
+	empty[0], empty[1], empty[2] = 1, 4, 5
+	binary.BigEndian.PutUint64(empty[3:11], 1)
+	require.Equal(t, errTable, u.UnmarshalBinary(empty))
+	binary.BigEndian.PutUint64(empty[3:11], 0)
+	require.NoError(t, u.UnmarshalBinary(empty))
+	require.False(t, u.Test(key(0)))
+	require.NoError(t, u.Resize())
+
+	_, err = Init(0, 0.01)
+	require.Equal(t, errCapacity, err)
+	_, err = Init(10, 1)
+	require.Equal(t, errFalsePositive, err)
+	_, err = InitByParameters(0, 4)
+	require.Equal(t, errFingerprint, err)
+}
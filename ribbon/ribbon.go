@@ -0,0 +1,253 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package ribbon provides ribbon filters, which like xor filters are built
+// once from a final set of keys and then only queried. A ribbon filter stores
+// any number of bits per key, so it can meet a false positive rate closely,
+// and needs about 8% more than that, against 23% for an xor filter and 44%
+// for a bloom filter.
+package ribbon
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+var (
+	errBuild         = errors.New("error: could not build the filter")
+	errFalsePositive = errors.New("error: falsePositive must be at least 2^-32 and less than 1")
+)
+
+const (
+	// width is the number of consecutive slots a key may touch.
+	width = 64
+	// overhead is the initial ratio of slots to keys, grown by overheadStep
+	// after each failed attempt.
+	overhead     = 1.07
+	overheadStep = 0.01
+	// maxAttempts is the number of seeds tried before giving up on a build.
+	maxAttempts = 100
+	// headerSize is the number of bytes preceding the solution in the output
+	// of MarshalBinary: 1 byte of version, 1 byte of result bits, 8 bytes of
+	// seed, and 8 bytes of the number of starts.
+	headerSize = 18
+)
+
+// RibbonFilter is a standard ribbon filter with 64-bit wide rows. A key maps
+// to a start slot, a 64-bit coefficient, and a result of resultBits bits, and
+// is in the filter if the XOR of the solution at the slots selected by the
+// coefficient, from the start, is its result. It is immutable, and so safe
+// for any number of concurrent readers.
+type RibbonFilter struct {
+	seed       uint64
+	numStarts  uint64  // number of possible start slots
+	resultBits uint    // bits per slot, 1 to 32
+	solution   []uint8 // numStarts+width-1 slots of resultBits, packed
+}
+
+// BuildRibbonFilter returns a ribbon filter holding the keys with the given
+// false positive rate, or an error if it cannot be built. Duplicate keys are
+// ignored. The keys should be well distributed, for example hashes of the
+// data.
+func BuildRibbonFilter(keys []uint64, falsePositive float64) (*RibbonFilter, error) {
+	if falsePositive <= 0 || falsePositive >= 1 {
+		return nil, errFalsePositive
+	}
+	resultBits := uint(math.Ceil(-math.Log2(falsePositive)))
+	if resultBits > 32 {
+		return nil, errFalsePositive
+	}
+
+	keys = unique(keys)
+	seed := uint64(0x6a09e667f3bcc908)
+	ratio := overhead
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		seed = splitmix(seed)
+		f := &RibbonFilter{
+			seed:       seed,
+			numStarts:  uint64(math.Ceil(float64(len(keys))*ratio)) + 1,
+			resultBits: resultBits,
+		}
+		if f.solve(keys) {
+			return f, nil
+		}
+		ratio += overheadStep
+	}
+	return nil, errBuild
+}
+
+// Test returns a bool if the key is in the filter. True indicates that the key
+// may be in the filter, while false indicates that it is not.
+func (f *RibbonFilter) Test(key uint64) bool {
+	start, coeff, result := f.hash(key)
+	var v uint32
+	for coeff != 0 {
+		j := uint64(bits.TrailingZeros64(coeff))
+		v ^= f.get(start + j)
+		coeff &= coeff - 1
+	}
+	return v == result
+}
+
+// SizeInBytes returns the size of the solution of the filter.
+func (f *RibbonFilter) SizeInBytes() int {
+	return len(f.solution)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface. The output
+// is 1 byte of version, 1 byte of result bits, the seed and number of starts
+// as 8 bytes each, then the solution. Integers are big endian.
+func (f *RibbonFilter) MarshalBinary() ([]byte, error) {
+	out := make([]byte, headerSize+len(f.solution))
+	out[0] = 1
+	out[1] = uint8(f.resultBits)
+	binary.BigEndian.PutUint64(out[2:10], f.seed)
+	binary.BigEndian.PutUint64(out[10:18], f.numStarts)
+	copy(out[headerSize:], f.solution)
+	return out, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (f *RibbonFilter) UnmarshalBinary(data []byte) error {
+	if len(data) < headerSize {
+		return fmt.Errorf("incorrect length: %d", len(data))
+	}
+	if data[0] != 1 {
+		return fmt.Errorf("unexpected version: %d", data[0])
+	}
+	resultBits := uint(data[1])
+	if resultBits == 0 || resultBits > 32 {
+		return fmt.Errorf("invalid result bits: %d", resultBits)
+	}
+	numStarts := binary.BigEndian.Uint64(data[10:18])
+	if numStarts == 0 || numStarts > math.MaxUint64/64/32 ||
+		uint64(len(data)-headerSize) != solutionSize(numStarts, resultBits) {
+		return fmt.Errorf("incorrect length: %d", len(data))
+	}
+	f.seed = binary.BigEndian.Uint64(data[2:10])
+	f.numStarts = numStarts
+	f.resultBits = resultBits
+	f.solution = append([]uint8{}, data[headerSize:]...)
+	return nil
+}
+
+// solve bands the keys into a system of equations and stores its solution,
+// returning false if the equations are inconsistent under the seed.
+func (f *RibbonFilter) solve(keys []uint64) bool {
+	slots := f.numStarts + width - 1
+	coeffs := make([]uint64, slots)
+	results := make([]uint32, slots)
+	for _, key := range keys {
+		start, coeff, result := f.hash(key)
+		for {
+			if coeffs[start] == 0 {
+				coeffs[start] = coeff
+				results[start] = result
+				break
+			}
+			coeff ^= coeffs[start]
+			result ^= results[start]
+			if coeff == 0 {
+				// the key is implied by others, which only fails if
+				// its result differs
+				if result != 0 {
+					return false
+				}
+				break
+			}
+			shift := uint(bits.TrailingZeros64(coeff))
+			start += uint64(shift)
+			coeff >>= shift
+		}
+	}
+
+	// back substitute from the last slot, leaving free slots 0
+	f.solution = make([]uint8, solutionSize(f.numStarts, f.resultBits))
+	for i := int64(slots) - 1; i >= 0; i-- {
+		coeff := coeffs[i]
+		if coeff == 0 {
+			continue
+		}
+		v := results[i]
+		for c := coeff & (coeff - 1); c != 0; c &= c - 1 {
+			v ^= f.get(uint64(i) + uint64(bits.TrailingZeros64(c)))
+		}
+		f.set(uint64(i), v)
+	}
+	return true
+}
+
+// hash returns the start slot, coefficient, and result of the key. The
+// coefficient always has its lowest bit set.
+func (f *RibbonFilter) hash(key uint64) (uint64, uint64, uint32) {
+	h := mix(key + f.seed)
+	start, _ := bits.Mul64(h, f.numStarts)
+	h2 := mix(h ^ 0x9e3779b97f4a7c15)
+	h3 := mix(h2)
+	return start, h2 | 1, uint32(h3) & f.resultMask()
+}
+
+// resultMask returns the mask of the bits of a result.
+func (f *RibbonFilter) resultMask() uint32 {
+	return uint32(1<<f.resultBits - 1)
+}
+
+// get returns the solution at slot i.
+func (f *RibbonFilter) get(i uint64) uint32 {
+	bit := i * uint64(f.resultBits)
+	var buff [8]byte
+	copy(buff[:], f.solution[bit/8:])
+	return uint32(binary.LittleEndian.Uint64(buff[:])>>(bit%8)) & f.resultMask()
+}
+
+// set stores the solution at slot i, which must be 0.
+func (f *RibbonFilter) set(i uint64, v uint32) {
+	bit := i * uint64(f.resultBits)
+	word := uint64(v) << (bit % 8)
+	for j := bit / 8; word != 0; j++ {
+		f.solution[j] |= uint8(word)
+		word >>= 8
+	}
+}
+
+// solutionSize returns the number of bytes of the solution.
+func solutionSize(numStarts uint64, resultBits uint) uint64 {
+	return ((numStarts+width-1)*uint64(resultBits) + 7) / 8
+}
+
+// unique returns the distinct keys, sorted. It does not modify keys.
+func unique(keys []uint64) []uint64 {
+	sorted := append([]uint64{}, keys...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	n := 0
+	for i, key := range sorted {
+		if i == 0 || key != sorted[n-1] {
+			sorted[n] = key
+			n++
+		}
+	}
+	return sorted[:n]
+}
+
+// mix is the 64-bit MurmurHash3 finalizer, spreading the bits of the key.
+func mix(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// splitmix returns the next seed after x.
+func splitmix(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
@@ -0,0 +1,82 @@
+package ribbon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// keys returns n well distributed keys starting from the nth.
+func keys(start, n int) []uint64 {
+	out := make([]uint64, n)
+	for i := range out {
+		out[i] = splitmix(uint64(start + i))
+	}
+	return out
+}
+
+// TestBuildRibbonFilter ensures every key is found, the false positive rate is
+// met, and the filter is smaller than a bloom filter for the same rate.
+func TestBuildRibbonFilter(t *testing.T) {
+	for _, rate := range []float64{0.01, 0.001, 1.0 / 65536} {
+		in := keys(0, 100000)
+		f, err := BuildRibbonFilter(in, rate)
+		require.NoError(t, err)
+		for _, key := range in {
+			require.True(t, f.Test(key))
+		}
+		positives := 0
+		for _, key := range keys(100000, 1000000) {
+			if f.Test(key) {
+				positives++
+			}
+		}
+		require.True(t, float64(positives)/1000000 < 1.5*rate,
+			"rate %v false positives %d", rate, positives)
+
+		// a bloom filter needs -log2(rate)/ln(2) bits per key
+		bitsPerKey := float64(8*f.SizeInBytes()) / float64(len(in))
+		require.True(t, bitsPerKey < 1.15*float64(f.resultBits),
+			"rate %v bits per key %v", rate, bitsPerKey)
+	}
+}
+
+// TestBuildRibbonFilter_Small ensures small, duplicate, and empty key sets
+// build.
+func TestBuildRibbonFilter_Small(t *testing.T) {
+	in := append(keys(0, 10), keys(0, 10)...)
+	f, err := BuildRibbonFilter(in, 0.01)
+	require.NoError(t, err)
+	for _, key := range in {
+		require.True(t, f.Test(key))
+	}
+
+	_, err = BuildRibbonFilter(nil, 0.01)
+	require.NoError(t, err)
+
+	_, err = BuildRibbonFilter(in, 0)
+	require.Equal(t, errFalsePositive, err)
+	_, err = BuildRibbonFilter(in, 1e-10)
+	require.Equal(t, errFalsePositive, err)
+}
+
+// TestRibbonFilter_Marshal ensures the filter round trips and bad data is
+// rejected.
+func TestRibbonFilter_Marshal(t *testing.T) {
+	in := keys(0, 1000)
+	f, _ := BuildRibbonFilter(in, 0.001)
+	out, err := f.MarshalBinary()
+	require.NoError(t, err)
+
+	u := new(RibbonFilter)
+	require.NoError(t, u.UnmarshalBinary(out))
+	require.Equal(t, f, u)
+
+	require.Error(t, u.UnmarshalBinary(out[:len(out)-1]))
+	require.Error(t, u.UnmarshalBinary(out[:headerSize-1]))
+	bad := append([]byte{}, out...)
+	bad[1] = 33
+	require.Error(t, u.UnmarshalBinary(bad))
+	bad[1], bad[0] = 10, 2
+	require.Error(t, u.UnmarshalBinary(bad))
+}
@@ -9,7 +9,11 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"math/bits"
+	"sort"
 	"sync"
+	"time"
+	"unsafe"
 )
 
 var (
@@ -17,20 +21,58 @@ var (
 	errFalsePositive = errors.New("error: falsePositive must be greater than 0 and less than 1")
 	errHash          = errors.New("error: Hash functions must be greater than zero")
 	errBadSize       = errors.New("error: the incoming data is not sized for this buffer")
+	errPadSize       = errors.New("error: the filter does not fit in the padded size")
+	errAlign         = errors.New("error: align must be greater than 0")
+	errParameters    = errors.New("rings must have the same m/k parameters")
+	errBudget        = errors.New("error: maxBytes must be greater than 0")
+	errIndex         = errors.New("error: bit index is not less than the size of the ring")
 )
 
+// headerSize is the number of bytes preceding the bit array in the output of
+// MarshalBinary: 1 byte of version, 8 bytes of size, and 8 bytes of hash.
+const headerSize = 17
+
 // Bloom contains the information for a ring data store.
 type Bloom struct {
-	size  uint64        // number of bits (bit array is size/8+1)
-	bits  []uint8       // main bit array
-	hash  uint64        // number of hash rounds
-	mutex *sync.RWMutex // mutex for locking Add, Test, and Reset operations
+	size  uint64  // number of bits (bit array is size/8+1)
+	bits  []uint8 // main bit array
+	hash  uint64  // number of hash rounds
+	mutex locker  // mutex for locking Add, Test, and Reset operations
+
+	created    time.Time     // time the ring was initialized
+	resetAt    time.Time     // time the ring was last initialized or reset
+	generation uint64        // number of times the ring has been reset
+	samples    []agingSample // fill samples taken since the last reset
+
+	digest uint64 // rolling digest of bits, kept current by every mutation
+	set    uint64 // number of set bits, kept current like the digest
+	state  State  // lifecycle state, checked by every operation
+
+	provenance Provenance // optional record of contributors and merges
+
+	capacity      int     // elements the ring was designed for, 0 if unknown
+	falsePositive float64 // false positive rate the ring was designed for
+
+	heat       [heatBuckets]heatSlot // bits changed per minute, see WriteHeat
+	unrecorded uint64                // bits changed not yet in heat
+
+	hashing hashing  // hash family and seed
+	layout  Layout   // placement of bits in the bit array
+	opLog   OpSink   // receives a record of each operation, if not nil
+	latency *latency // latency histograms, if not nil
+
+	canaries   [][canaryKeySize]byte // keys never added, see WithCanaries
+	saturation []*saturationWatch    // callbacks of WithSaturationCallback
+
+	pool   *Pool // pool the bit array is returned to on Release, if not nil
+	shared bool  // the bit array is shared with a Frozen view
 }
 
 // Init initializes and returns a new ring, or an error. Given a number of
 // elements, it accurately states if data is not added. Within a falsePositive
-// rate, it will indicate if the data has been added.
-func Init(elements int, falsePositive float64) (*Bloom, error) {
+// rate, it will indicate if the data has been added. The options are applied
+// in order.
+func Init(elements int, falsePositive float64, opts ...Option) (*Bloom, error) {
 	if elements <= 0 {
 		return nil, errElements
 	}
@@ -39,21 +81,113 @@ func Init(elements int, falsePositive float64) (*Bloom, error) {
 	}
 
 	r := Bloom{}
-	// number of bits
-	m := (-1 * float64(elements) * math.Log(falsePositive)) / math.Pow(math.Log(2), 2)
-	// number of hash operations
-	k := (m / float64(elements)) * math.Log(2)
-
 	r.mutex = &sync.RWMutex{}
-	r.size = uint64(math.Ceil(m))
-	r.hash = uint64(math.Ceil(k))
+	r.created = time.Now()
+	r.resetAt = r.created
+	r.size, r.hash = optimalParameters(elements, falsePositive)
+	r.capacity = elements
+	r.falsePositive = falsePositive
+	if err := r.applyOptions(opts); err != nil {
+		return nil, err
+	}
 	r.bits = make([]uint8, getBuffSize(r.size))
 	return &r, nil
 }
 
+// MustInit is Init, panicking if the parameters are invalid. It is meant for
+// package-level variables initialized with constant parameters.
+func MustInit(elements int, falsePositive float64, opts ...Option) *Bloom {
+	r, err := Init(elements, falsePositive, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// optimalParameters returns the number of bits and hash operations needed to
+// hold the given number of elements within the falsePositive rate.
+func optimalParameters(elements int, falsePositive float64) (uint64, uint64) {
+	m := optimalBits(float64(elements), falsePositive)
+	return uint64(math.Ceil(m)), optimalRounds(m, float64(elements))
+}
+
+// optimalBits returns the number of bits, m = -n*ln(p)/ln(2)^2, needed to
+// hold n elements within the false positive rate p.
+func optimalBits(n, p float64) float64 {
+	return (-1 * n * math.Log(p)) / math.Pow(math.Log(2), 2)
+}
+
+// optimalRounds returns the number of hash operations, k = ceil(m/n * ln(2)),
+// that minimizes the false positive rate of m bits holding n elements.
+func optimalRounds(m, n float64) uint64 {
+	return uint64(math.Ceil((m / n) * math.Log(2)))
+}
+
+// OptimalM returns the number of bits Init gives a ring for n elements within
+// the false positive rate fp, ceil(-n*ln(fp)/ln(2)^2). It returns 0 if fp is
+// not greater than 0 and less than 1.
+func OptimalM(n uint64, fp float64) uint64 {
+	if fp <= 0 || fp >= 1 {
+		return 0
+	}
+	return uint64(math.Ceil(optimalBits(float64(n), fp)))
+}
+
+// OptimalK returns the number of hash rounds that minimizes the false
+// positive rate of m bits holding n elements, ceil(m/n * ln(2)), and at least
+// 1. Init computes k the same way, but from m before it is rounded up to a
+// whole bit, so OptimalK(OptimalM(n, fp), n) may rarely exceed the k of Init
+// by one.
+func OptimalK(m, n uint64) uint64 {
+	if n == 0 {
+		return 1
+	}
+	if k := optimalRounds(float64(m), float64(n)); k > 1 {
+		return k
+	}
+	return 1
+}
+
+// ExpectedFPRate returns the false positive rate of a ring of m bits and k
+// hash rounds holding n elements, (1 - e^(-kn/m))^k, as TargetFP does. It
+// returns 1 if m is 0.
+func ExpectedFPRate(m, k, n uint64) float64 {
+	if m == 0 {
+		return 1
+	}
+	return math.Pow(expectedFillRatio(m, k, float64(n)), float64(k))
+}
+
+// ParametersForBudget returns the number of bits m and hash rounds k giving
+// the lowest false positive rate for n elements in a bit array of at most
+// maxBytes, as InitByParameters(m, k) allocates it, and that rate. The output
+// of MarshalBinary is longer by the header, at least 17 bytes. Layouts other
+// than LayoutStandard may round m up past the budget. It returns an error if
+// maxBytes or n is 0.
+func ParametersForBudget(maxBytes uint64, n uint64) (m, k uint64, fp float64, err error) {
+	if maxBytes == 0 {
+		return 0, 0, 0, errBudget
+	}
+	if n == 0 {
+		return 0, 0, 0, errElements
+	}
+	m = maxBytes * 8
+	// the rate is minimized at m/n * ln(2), so the best whole k is one of the
+	// integers either side of it
+	k = OptimalK(m, n)
+	fp = ExpectedFPRate(m, k, n)
+	if k > 1 {
+		if lower := ExpectedFPRate(m, k-1, n); lower <= fp {
+			k, fp = k-1, lower
+		}
+	}
+	return m, k, fp, nil
+}
+
 // InitByParameters initializes a bloom filter allowing the user to explicitly set
-// the size of the bit array and the amount of hash functions
-func InitByParameters(size, hashFunctions uint64) (*Bloom, error) {
+// the size of the bit array and the amount of hash functions, applying the
+// options in order
+func InitByParameters(size, hashFunctions uint64, opts ...Option) (*Bloom, error) {
 	if size <= 0 {
 		return nil, errElements
 	}
@@ -64,22 +198,156 @@ func InitByParameters(size, hashFunctions uint64) (*Bloom, error) {
 	r := Bloom{}
 
 	r.mutex = &sync.RWMutex{}
+	r.created = time.Now()
+	r.resetAt = r.created
 	r.size = size
 	r.hash = hashFunctions
+	if err := r.applyOptions(opts); err != nil {
+		return nil, err
+	}
 	r.bits = make([]uint8, getBuffSize(r.size))
 	return &r, nil
 }
 
+// MustInitByParameters is InitByParameters, panicking if the parameters are
+// invalid. It is meant for package-level variables initialized with constant
+// parameters.
+func MustInitByParameters(size, hashFunctions uint64, opts ...Option) *Bloom {
+	r, err := InitByParameters(size, hashFunctions, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
 // Add adds the data to the ring.
 func (r *Bloom) Add(data []byte) {
+	if r.latency != nil {
+		defer r.latency.add.observe(time.Now())
+	}
 	// generate hashes
-	hash := generateMultiHash(data)
+	hash := r.hashData(data)
+	r.mutex.Lock()
+	defer r.writeUnlock()
+	r.mustBeMutable("add to")
+	r.add(hash)
+}
+
+// AddUint64 adds the 8-byte big-endian encoding of v to the ring, without
+// allocating.
+func (r *Bloom) AddUint64(v uint64) {
+	if r.latency != nil {
+		defer r.latency.add.observe(time.Now())
+	}
+	var buff [8]byte
+	binary.BigEndian.PutUint64(buff[:], v)
+	hash := r.hashData(buff[:])
 	r.mutex.Lock()
+	defer r.writeUnlock()
+	r.mustBeMutable("add to")
+	r.add(hash)
+}
+
+// AddString adds the bytes of s to the ring, as Add([]byte(s)) would, without
+// copying them.
+func (r *Bloom) AddString(s string) {
+	if r.latency != nil {
+		defer r.latency.add.observe(time.Now())
+	}
+	hash := r.hashData(stringBytes(s))
+	r.mutex.Lock()
+	defer r.writeUnlock()
+	r.mustBeMutable("add to")
+	r.add(hash)
+}
+
+// AddMany adds every item to the ring. The items are hashed before taking the
+// lock, which is then held once for the whole batch rather than per item.
+func (r *Bloom) AddMany(items [][]byte) {
+	if r.latency != nil {
+		defer r.latency.add.observeN(time.Now(), len(items))
+	}
+	hashes := make([][4]uint64, len(items))
+	for i, item := range items {
+		hashes[i] = r.hashData(item)
+	}
+	r.mutex.Lock()
+	defer r.writeUnlock()
+	r.mustBeMutable("add to")
+	for _, hash := range hashes {
+		r.add(hash)
+	}
+}
+
+// HashHandle is the hash of an element, computed once by Hash and usable with
+// AddHash and TestHash on any number of rings with the same hash family and
+// seed.
+type HashHandle struct {
+	hash    [4]uint64
+	hashing hashing
+}
+
+// Hash hashes the data with the default hash family and seed for use with
+// AddHash and TestHash, so that it can be added to or tested against many
+// rings without hashing it again for each.
+func Hash(data []byte) HashHandle {
+	return hashing{}.handle(data)
+}
+
+// Hash hashes the data as Hash does, with the hash family and seed of the
+// ring.
+func (r *Bloom) Hash(data []byte) HashHandle {
+	return r.currentHashing().handle(data)
+}
+
+// handle returns the handle of the data.
+func (h hashing) handle(data []byte) HashHandle {
+	return HashHandle{hash: h.sum(data), hashing: h}
+}
+
+// AddHash adds the element the handle was computed from to the ring, as Add
+// would. It panics if the handle was computed with a different hash family
+// or seed than the ring's.
+func (r *Bloom) AddHash(h HashHandle) {
+	if r.latency != nil {
+		defer r.latency.add.observe(time.Now())
+	}
+	r.mutex.Lock()
+	defer r.writeUnlock()
+	r.mustBeMutable("add to")
+	r.mustMatch(h)
+	r.add(h.hash)
+}
+
+// TestHash returns a bool if the element the handle was computed from is in
+// the ring, as Test would. It panics if the handle was computed with a
+// different hash family or seed than the ring's.
+func (r *Bloom) TestHash(h HashHandle) bool {
+	if r.latency != nil {
+		defer r.latency.test.observe(time.Now())
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	r.mustBeUsable("test")
+	r.mustMatch(h)
+	return r.test(h.hash)
+}
+
+// mustMatch panics if the handle was computed with a different hash family or
+// seed than the ring's.
+func (r *Bloom) mustMatch(h HashHandle) {
+	if h.hashing != r.hashing {
+		panic(errHashing)
+	}
+}
+
+// setHash sets the bits for the pre-generated hashes. The caller must hold the
+// write lock.
+func (r *Bloom) setHash(hash [4]uint64) {
 	for i := uint64(0); i < r.hash; i++ {
-		index := getRound(hash, i) % r.size
-		r.bits[index/8] |= (1 << (index % 8))
+		index := r.position(hash, i)
+		r.orByte(index/8, 1<<(index%8))
 	}
-	r.mutex.Unlock()
 }
 
 // Returns the size of the bloom filter.
@@ -92,22 +360,320 @@ func (r *Bloom) GetHashOpCount() uint64 {
 	return r.hash
 }
 
+// GetK returns the number of hash functions, k, of the ring.
+func (r *Bloom) GetK() uint64 {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.hash
+}
+
+// GetM returns the number of bits, m, of the ring.
+func (r *Bloom) GetM() uint64 {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.size
+}
+
+// Capacity returns the number of elements the ring was designed to hold. For
+// rings not created by Init, such as by InitByParameters or UnmarshalBinary,
+// it is the number of elements for which m and k are optimal, m*ln2/k.
+func (r *Bloom) Capacity() int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if r.capacity > 0 {
+		return r.capacity
+	}
+	return designedCapacity(r.size, r.hash)
+}
+
+// TargetFP returns the false positive rate the ring was designed to have at
+// capacity. For rings not created by Init, it is the expected rate once
+// Capacity elements have been added.
+func (r *Bloom) TargetFP() float64 {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if r.capacity > 0 {
+		return r.falsePositive
+	}
+	elements := float64(designedCapacity(r.size, r.hash))
+	return math.Pow(expectedFillRatio(r.size, r.hash, elements), float64(r.hash))
+}
+
+// FillRatio returns the fraction of bits in the ring that are set.
+func (r *Bloom) FillRatio() float64 {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	r.mustBeUsable("measure")
+	return float64(countBits(r.bits)) / float64(r.size)
+}
+
+// ApproximateCount returns the estimated number of distinct elements added to
+// the ring, n = -(m/k) * ln(1 - X/m) for X set bits. It returns
+// math.MaxUint64 if every bit is set, as the count can no longer be estimated.
+func (r *Bloom) ApproximateCount() uint64 {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	r.mustBeUsable("measure")
+	set := countBits(r.bits)
+	if set >= r.size {
+		return math.MaxUint64
+	}
+	return uint64(math.Round(estimateElements(r.size, r.hash, set)))
+}
+
+// CurrentFalsePositiveRate returns the false positive rate of the ring given
+// its current fill, (X/m)^k for X set bits. It exceeds the rate the ring was
+// initialized with once more elements than planned have been added.
+func (r *Bloom) CurrentFalsePositiveRate() float64 {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	r.mustBeUsable("measure")
+	fill := float64(countBits(r.bits)) / float64(r.size)
+	return math.Pow(fill, float64(r.hash))
+}
+
+// IsEmpty returns true if no bits in the ring are set.
+func (r *Bloom) IsEmpty() bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	r.mustBeUsable("measure")
+	for _, b := range r.bits {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 // Reset clears the ring.
 func (r *Bloom) Reset() {
 	r.mutex.Lock()
-	r.bits = make([]uint8, r.size/8+1)
-	r.mutex.Unlock()
+	defer r.writeUnlock()
+	r.mustBeMutable("reset")
+	r.bits = make([]uint8, getBuffSize(r.size))
+	r.digest = 0
+	r.set = 0
+	r.resetAt = time.Now()
+	r.generation++
+	r.samples = nil
+	r.provenance = Provenance{}
 }
 
 // Test returns a bool if the data is in the ring. True indicates that the data
 // may be in the ring, while false indicates that the data is not in the ring.
 func (r *Bloom) Test(data []byte) bool {
+	if r.latency != nil {
+		defer r.latency.test.observe(time.Now())
+	}
 	// generate hashes
-	hash := generateMultiHash(data)
+	hash := r.hashData(data)
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	r.mustBeUsable("test")
+	return r.test(hash)
+}
+
+// TestUint64 returns a bool if the 8-byte big-endian encoding of v is in the
+// ring, as Test would, without allocating.
+func (r *Bloom) TestUint64(v uint64) bool {
+	if r.latency != nil {
+		defer r.latency.test.observe(time.Now())
+	}
+	var buff [8]byte
+	binary.BigEndian.PutUint64(buff[:], v)
+	hash := r.hashData(buff[:])
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	r.mustBeUsable("test")
+	return r.test(hash)
+}
+
+// TestString returns a bool if the bytes of s are in the ring, as
+// Test([]byte(s)) would, without copying them.
+func (r *Bloom) TestString(s string) bool {
+	if r.latency != nil {
+		defer r.latency.test.observe(time.Now())
+	}
+	hash := r.hashData(stringBytes(s))
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	r.mustBeUsable("test")
+	return r.test(hash)
+}
+
+// TestMany returns, for each item, whether it may be in the ring as Test
+// would. The items are hashed before taking the lock, which is then held once
+// for the whole batch.
+func (r *Bloom) TestMany(items [][]byte) []bool {
+	if r.latency != nil {
+		defer r.latency.test.observeN(time.Now(), len(items))
+	}
+	hashes := make([][4]uint64, len(items))
+	for i, item := range items {
+		hashes[i] = r.hashData(item)
+	}
+	results := make([]bool, len(items))
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	r.mustBeUsable("test")
+	for i, hash := range hashes {
+		results[i] = r.test(hash)
+	}
+	return results
+}
+
+// TestApprox returns a bool if the data may be in the ring, checking only the
+// bits of the first probes hash rounds. It never reports added data as absent,
+// but reports more false positives than Test, roughly the fill ratio to the
+// power of probes, in exchange for fewer memory accesses. A one probe screen
+// followed by Test on its hits makes most misses cheap. Probes are clamped to
+// between 1 and the number of hash rounds.
+func (r *Bloom) TestApprox(data []byte, probes int) bool {
+	if r.latency != nil {
+		defer r.latency.test.observe(time.Now())
+	}
+	hash := r.hashData(data)
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	r.mustBeUsable("test")
+	n := r.hash
+	if probes < 1 {
+		n = 1
+	} else if uint64(probes) < n {
+		n = uint64(probes)
+	}
+	found := true
+	for i := uint64(0); i < n; i++ {
+		index := r.position(hash, i)
+		if r.bits[index/8]&(1<<(index%8)) == 0 {
+			found = false
+			break
+		}
+	}
+	r.logOp(OpTest, hash, found)
+	return found
+}
+
+// TestAny returns true if any of the items may be in the ring, stopping at
+// the first that may. The lock is taken once and each item is only hashed
+// when it is reached. It returns false if there are no items.
+func (r *Bloom) TestAny(items [][]byte) bool {
+	if r.latency != nil {
+		defer r.latency.test.observe(time.Now())
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	r.mustBeUsable("test")
+	for _, item := range items {
+		if r.test(r.hashing.sum(item)) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestEvery returns true if every item may be in the ring, stopping at the
+// first that is not. The lock is taken once and each item is only hashed when
+// it is reached. It returns true if there are no items.
+func (r *Bloom) TestEvery(items [][]byte) bool {
+	if r.latency != nil {
+		defer r.latency.test.observe(time.Now())
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	r.mustBeUsable("test")
+	for _, item := range items {
+		if !r.test(r.hashing.sum(item)) {
+			return false
+		}
+	}
+	return true
+}
+
+// TestManyMask is TestMany with the results packed into a bitmask, where bit
+// i%64 of word i/64 is set if item i may be in the ring.
+func (r *Bloom) TestManyMask(items [][]byte) []uint64 {
+	if r.latency != nil {
+		defer r.latency.test.observeN(time.Now(), len(items))
+	}
+	hashes := make([][4]uint64, len(items))
+	for i, item := range items {
+		hashes[i] = r.hashData(item)
+	}
+	mask := make([]uint64, (len(items)+63)/64)
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	r.mustBeUsable("test")
+	for i, hash := range hashes {
+		if r.test(hash) {
+			mask[i/64] |= 1 << uint(i%64)
+		}
+	}
+	return mask
+}
+
+// Locations returns the k bit indices that Add sets for the data, in probe
+// order, as described by ProbePositions for LayoutStandard. They may be sent
+// in place of the data to a holder of the ring that sets or tests them with
+// SetBits and TestBits. Like Test, it panics if the ring has been destroyed.
+func (r *Bloom) Locations(data []byte) []uint64 {
+	hash := r.hashData(data)
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	r.mustBeUsable("read")
+	locations := make([]uint64, r.hash)
+	for i := range locations {
+		locations[i] = r.position(hash, uint64(i))
+	}
+	return locations
+}
+
+// SetBits sets the bits at the given indices, such as those computed for an
+// element with Locations, without the ring ever seeing the element. Every index
+// is checked before any bit is set. It returns an error if an index is not
+// less than the size of the ring or a *StateError if it may not be modified.
+func (r *Bloom) SetBits(indices []uint64) error {
+	r.mutex.Lock()
+	defer r.writeUnlock()
+	if err := r.checkMutable("add to"); err != nil {
+		return err
+	}
+	for _, index := range indices {
+		if index >= r.size {
+			return errIndex
+		}
+	}
+	before := r.unrecorded
+	for _, index := range indices {
+		r.orByte(index/8, 1<<(index%8))
+	}
+	r.logOp(OpSetBits, [4]uint64{}, r.unrecorded != before)
+	return nil
+}
+
+// TestBits returns true if the bits at every given index are set, as Test
+// would for the element the indices were computed from. An index that is not
+// less than the size of the ring is never set, so it makes the result false.
+func (r *Bloom) TestBits(indices []uint64) bool {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
+	r.mustBeUsable("test")
+	found := true
+	for _, index := range indices {
+		if index >= r.size || r.bits[index/8]&(1<<(index%8)) == 0 {
+			found = false
+			break
+		}
+	}
+	r.logOp(OpTestBits, [4]uint64{}, found)
+	return found
+}
+
+// testHash returns true if all bits for the pre-generated hashes are set. The
+// caller must hold the read lock.
+func (r *Bloom) testHash(hash [4]uint64) bool {
 	for i := uint64(0); i < uint64(r.hash); i++ {
-		index := getRound(hash, i) % r.size
+		index := r.position(hash, i)
 		// check if index%8-th bit is not active
 		if (r.bits[index/8] & (1 << (index % 8))) == 0 {
 			return false
@@ -116,66 +682,572 @@ func (r *Bloom) Test(data []byte) bool {
 	return true
 }
 
-// Merges the sent Bloom into itself.
+// TestAndAdd adds the data to the ring and returns true if it may have been in
+// the ring beforehand, as Test would have. The data is hashed once and the
+// check and insert happen under a single lock, so concurrent calls with the
+// same data report it as new at most once.
+func (r *Bloom) TestAndAdd(data []byte) bool {
+	if r.latency != nil {
+		defer r.latency.add.observe(time.Now())
+	}
+	hash := r.hashData(data)
+	r.mutex.Lock()
+	defer r.writeUnlock()
+	r.mustBeMutable("add to")
+	return r.testAndAdd(hash)
+}
+
+// Merges the sent Bloom into itself. The sent Bloom is read under its lock for
+// the whole merge, so a concurrent Add to it is either fully included or not
+// at all, and two rings may merge into each other concurrently without
+// deadlocking. If either ring has provenance records, the contributors and
+// digest of the sent Bloom are recorded in the provenance of this one.
 func (r *Bloom) Merge(m *Bloom) error {
+	if r.latency != nil {
+		defer r.latency.merge.observe(time.Now())
+	}
+	if r == m {
+		r.mutex.RLock()
+		defer r.mutex.RUnlock()
+		// merging a ring with itself leaves it unchanged
+		return r.checkMutable("merge into")
+	}
+
+	unlock := lockPair(r, m, true)
+	defer unlock()
+	if err := r.checkMutable("merge into"); err != nil {
+		return err
+	}
+	if err := m.checkUsable("merge from"); err != nil {
+		return err
+	}
 	if r.size != m.size || r.hash != m.hash {
-		return errors.New("rings must have the same m/k parameters")
+		return errParameters
+	}
+	if r.hashing != m.hashing {
+		return errHashing
 	}
+	if r.layout != m.layout {
+		return errLayout
+	}
+	if !r.provenance.empty() || !m.provenance.empty() {
+		r.provenance.merge(&m.provenance, m.digest)
+	}
+	r.orBits(m.bits)
+	return nil
+}
 
-	r.mutex.Lock()
-	m.mutex.RLock()
-	for i := 0; i < len(m.bits); i++ {
-		r.bits[i] |= m.bits[i]
+// MergeMany merges every sent Bloom into the ring in a single pass over its bit
+// array, ORing each byte of all inputs together before writing it, rather than
+// walking the array once per input as repeated Merge calls would. The inputs
+// are all checked before any bits change and are read under their locks for
+// the whole merge. It returns an error if any input has different parameters
+// or a *StateError if the ring may not be modified or an input was destroyed.
+func (r *Bloom) MergeMany(filters ...*Bloom) error {
+	if r.latency != nil {
+		defer r.latency.merge.observe(time.Now())
+	}
+	unlock := lockMany(r, filters)
+	defer unlock()
+	if err := r.checkMutable("merge into"); err != nil {
+		return err
+	}
+	inputs := make([]*Bloom, 0, len(filters))
+	record := !r.provenance.empty()
+	for _, m := range filters {
+		if m == r {
+			// merging a ring with itself leaves it unchanged
+			continue
+		}
+		if err := m.checkUsable("merge from"); err != nil {
+			return err
+		}
+		if r.size != m.size || r.hash != m.hash {
+			return errParameters
+		}
+		if r.hashing != m.hashing {
+			return errHashing
+		}
+		if r.layout != m.layout {
+			return errLayout
+		}
+		inputs = append(inputs, m)
+		record = record || !m.provenance.empty()
+	}
+
+	if record {
+		for _, m := range inputs {
+			r.provenance.merge(&m.provenance, m.digest)
+		}
+	}
+	for i := range r.bits {
+		var v uint8
+		for _, m := range inputs {
+			v |= m.bits[i]
+		}
+		r.orByte(uint64(i), v)
 	}
-	r.mutex.Unlock()
-	m.mutex.RUnlock()
 	return nil
 }
 
+// Intersect clears every bit of the ring that is not set in other, the bitwise
+// AND of the two. The result only approximates the ring of the intersection:
+// every element in both rings is still reported present, but so is any
+// element whose bits were all set in both by different elements, so the false
+// positive rate is at least that of the ring built from the intersection
+// alone. Other is read under its lock for the whole operation. It returns an
+// error if the rings have different parameters or a *StateError if the ring
+// may not be modified.
+func (r *Bloom) Intersect(other *Bloom) error {
+	if r == other {
+		r.mutex.RLock()
+		defer r.mutex.RUnlock()
+		// intersecting a ring with itself leaves it unchanged
+		return r.checkMutable("intersect")
+	}
+
+	unlock := lockPair(r, other, true)
+	defer unlock()
+	if err := r.checkMutable("intersect"); err != nil {
+		return err
+	}
+	if err := other.checkUsable("intersect with"); err != nil {
+		return err
+	}
+	if r.size != other.size || r.hash != other.hash {
+		return errParameters
+	}
+	if r.hashing != other.hashing {
+		return errHashing
+	}
+	if r.layout != other.layout {
+		return errLayout
+	}
+	for i := 0; i < len(other.bits); i++ {
+		r.andByte(uint64(i), other.bits[i])
+	}
+	return nil
+}
+
+// Clone returns a deep copy of the ring. The copy has the same parameters, bits,
+// and age as the ring but starts in StateBuilding, as it has not been used yet.
+func (r *Bloom) Clone() *Bloom {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	r.mustBeUsable("clone")
+	c := &Bloom{mutex: newLocker(r.mutex)}
+	c.copyFrom(r)
+	return c
+}
+
+// CopyFrom replaces the parameters and bits of the ring with those of other,
+// reusing the bit array of the ring if it is large enough. It returns a
+// *StateError if the ring may not be modified or other has been destroyed.
+func (r *Bloom) CopyFrom(other *Bloom) error {
+	if r == other {
+		return nil
+	}
+	unlock := lockPair(r, other, true)
+	defer unlock()
+	if err := r.checkMutable("copy into"); err != nil {
+		return err
+	}
+	if err := other.checkUsable("copy from"); err != nil {
+		return err
+	}
+	r.copyFrom(other)
+	return nil
+}
+
+// copyFrom copies the parameters, bits, age, and provenance of other into the ring,
+// leaving its state unchanged. The caller must hold the write lock of the ring
+// and the read lock of other.
+func (r *Bloom) copyFrom(other *Bloom) {
+	r.size = other.size
+	r.hash = other.hash
+	if cap(r.bits) >= len(other.bits) {
+		r.bits = r.bits[:len(other.bits)]
+	} else {
+		r.bits = make([]uint8, len(other.bits))
+	}
+	copy(r.bits, other.bits)
+	r.digest = other.digest
+	r.set = other.set
+	r.created = other.created
+	r.resetAt = other.resetAt
+	r.generation = other.generation
+	r.samples = append(r.samples[:0], other.samples...)
+	r.provenance = other.provenance.clone()
+	r.capacity = other.capacity
+	r.falsePositive = other.falsePositive
+	r.hashing = other.hashing
+	r.layout = other.layout
+}
+
+// lockPair takes the read lock of other and either the write or read lock of
+// r, which must be different rings, in an order fixed by their addresses so
+// that two calls with the rings in opposite roles cannot deadlock. It returns a
+// function releasing both locks.
+func lockPair(r, other *Bloom, write bool) func() {
+	lock, unlock := r.mutex.RLock, r.mutex.RUnlock
+	if write {
+		lock, unlock = r.mutex.Lock, r.writeUnlock
+	}
+	if uintptr(unsafe.Pointer(r)) < uintptr(unsafe.Pointer(other)) {
+		lock()
+		other.mutex.RLock()
+	} else {
+		other.mutex.RLock()
+		lock()
+	}
+	return func() {
+		unlock()
+		other.mutex.RUnlock()
+	}
+}
+
+// lockMany takes the write lock of r and the read lock of every other ring,
+// each once, in an order fixed by their addresses as lockPair does. Others may
+// contain r and duplicates. It returns a function releasing every lock.
+func lockMany(r *Bloom, others []*Bloom) func() {
+	rings := make([]*Bloom, 0, len(others)+1)
+	rings = append(rings, r)
+	seen := map[*Bloom]bool{r: true}
+	for _, m := range others {
+		if !seen[m] {
+			seen[m] = true
+			rings = append(rings, m)
+		}
+	}
+	sort.Slice(rings, func(i, j int) bool {
+		return uintptr(unsafe.Pointer(rings[i])) < uintptr(unsafe.Pointer(rings[j]))
+	})
+	for _, m := range rings {
+		if m == r {
+			m.mutex.Lock()
+		} else {
+			m.mutex.RLock()
+		}
+	}
+	return func() {
+		for _, m := range rings {
+			if m == r {
+				m.writeUnlock()
+			} else {
+				m.mutex.RUnlock()
+			}
+		}
+	}
+}
+
+// Equal returns true if other has the same parameters, hashing, layout, and
+// bits as the ring.
+// Other properties, such as age, state, and provenance, are not compared.
+func (r *Bloom) Equal(other *Bloom) bool {
+	if r == other {
+		return true
+	}
+	unlock := lockPair(r, other, false)
+	defer unlock()
+	if r.size != other.size || r.hash != other.hash ||
+		r.hashing != other.hashing || r.layout != other.layout ||
+		len(r.bits) != len(other.bits) {
+		return false
+	}
+	for i := range r.bits {
+		if r.bits[i] != other.bits[i] {
+			return false
+		}
+	}
+	return true
+}
+
 // MarshalBinary implements the encoding.BinaryMarshaler interface.
 func (r *Bloom) MarshalBinary() ([]byte, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
-	out := make([]byte, len(r.bits)+17)
+	if err := r.checkUsable("marshal"); err != nil {
+		return nil, err
+	}
+	section := r.extensionSection()
+	return r.marshal(section, bitsOffset(section)+len(r.bits)), nil
+}
+
+// MarshalBinaryPadded is MarshalBinary with the output padded with zeros to
+// exactly size bytes, for transports that require fixed size payloads. It
+// returns an error if the filter does not fit in size bytes.
+func (r *Bloom) MarshalBinaryPadded(size int) ([]byte, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if err := r.checkUsable("marshal"); err != nil {
+		return nil, err
+	}
+	section := r.extensionSection()
+	if size < bitsOffset(section)+len(r.bits) {
+		return nil, errPadSize
+	}
+	return r.marshal(section, size), nil
+}
+
+// MarshalBinaryAligned is MarshalBinary with the output padded with zeros to
+// the next multiple of align bytes. It returns an error if align is not
+// greater than 0.
+func (r *Bloom) MarshalBinaryAligned(align int) ([]byte, error) {
+	if align <= 0 {
+		return nil, errAlign
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if err := r.checkUsable("marshal"); err != nil {
+		return nil, err
+	}
+	section := r.extensionSection()
+	length := bitsOffset(section) + len(r.bits)
+	if rem := length % align; rem != 0 {
+		length += align - rem
+	}
+	return r.marshal(section, length), nil
+}
+
+// AppendBinary appends the output of MarshalBinary to dst and returns the
+// extended buffer, reusing the capacity of dst rather than allocating when it
+// is large enough. It has the signature of encoding.BinaryAppender.
+func (r *Bloom) AppendBinary(dst []byte) ([]byte, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if err := r.checkUsable("marshal"); err != nil {
+		return dst, err
+	}
+	section := r.extensionSection()
+	length := bitsOffset(section) + len(r.bits)
+	start := len(dst)
+	if cap(dst)-start < length {
+		grown := make([]byte, start, start+length)
+		copy(grown, dst)
+		dst = grown
+	}
+	dst = dst[:start+length]
+	r.marshalTo(dst[start:], section)
+	return dst, nil
+}
+
+// marshal returns the header, extension section, and bit array in a buffer of
+// the given length, which must fit them. Version 1 is used if the section is
+// nil. The caller must hold the read lock.
+func (r *Bloom) marshal(section []byte, length int) []byte {
+	out := make([]byte, length)
+	r.marshalTo(out, section)
+	return out
+}
+
+// marshalTo writes the header, extension section, and bit array to the start
+// of out, which must fit them. The caller must hold the read lock.
+func (r *Bloom) marshalTo(out []byte, section []byte) {
 	// store a version for future compatibility
 	out[0] = 1
 	binary.BigEndian.PutUint64(out[1:9], r.size)
 	binary.BigEndian.PutUint64(out[9:17], r.hash)
-	copy(out[17:], r.bits)
-	return out, nil
+	if section != nil {
+		out[0] = 2
+		binary.BigEndian.PutUint32(out[headerSize:], uint32(len(section)))
+		copy(out[headerSize+extensionLengthSize:], section)
+	}
+	copy(out[bitsOffset(section):], r.bits)
 }
 
-// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface. Bytes
+// following the bit array, such as the padding added by MarshalBinaryPadded
+// and MarshalBinaryAligned, are ignored. Extensions of an unknown type are
+// skipped unless they are critical, in which case an error is returned and
+// the ring is left unchanged.
 func (r *Bloom) UnmarshalBinary(data []byte) error {
-	// 17 bytes for version + size + hash and 1 byte at least for bits
-	if len(data) < 17+1 {
-		return fmt.Errorf("incorrect length: %d", len(data))
-	}
-	if data[0] != 1 {
-		return fmt.Errorf("unexpected version: %d", data[0])
+	d, err := decodeBinary(data)
+	if err != nil {
+		return err
 	}
+
 	if r.mutex == nil {
 		r.mutex = new(sync.RWMutex)
 	}
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
-	r.size = binary.BigEndian.Uint64(data[1:9])
-	r.hash = binary.BigEndian.Uint64(data[9:17])
+	if err := r.checkMutable("unmarshal into"); err != nil {
+		return err
+	}
+	if err := r.adoptKey(&d); err != nil {
+		return err
+	}
 	// sanity check against the bits being the wrong size
-	buffSize := getBuffSize(r.size)
-	if len(r.bits) != int(buffSize) {
-		r.bits = make([]uint8, buffSize)
+	bits := r.bits
+	if buffSize := getBuffSize(d.size); len(bits) != int(buffSize) {
+		bits = make([]uint8, buffSize)
+	}
+	copy(bits, data[d.offset:])
+	r.load(d, bits)
+	return nil
+}
+
+// unmarshalWith returns the ring decoded from data by UnmarshalBinary into a
+// ring given the options, which supply the key if the ring is keyed.
+func unmarshalWith(data []byte, opts []Option) (*Bloom, error) {
+	r := &Bloom{mutex: &sync.RWMutex{}}
+	for _, opt := range opts {
+		if err := opt(r); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// UnmarshalInto is UnmarshalBinary storing the bit array in buf rather than
+// allocating one, for consumers with a fixed memory budget. It returns an
+// error if buf is shorter than the bit array. The ring must not be used after
+// buf is reused. Apart from a mutex for a zero Bloom, and the provenance
+// extension if present, it does not allocate.
+func (r *Bloom) UnmarshalInto(buf []byte, data []byte) error {
+	d, err := decodeBinary(data)
+	if err != nil {
+		return err
+	}
+	buffSize := getBuffSize(d.size)
+	if uint64(len(buf)) < buffSize {
+		return errBadSize
+	}
+
+	if r.mutex == nil {
+		r.mutex = new(sync.RWMutex)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err := r.checkMutable("unmarshal into"); err != nil {
+		return err
+	}
+	if err := r.adoptKey(&d); err != nil {
+		return err
+	}
+	bits := buf[:buffSize]
+	// the data may omit trailing bytes, which must not keep old contents
+	if n := len(data) - d.offset; n < len(bits) {
+		for i := n; i < len(bits); i++ {
+			bits[i] = 0
+		}
 	}
-	copy(r.bits, data[17:])
+	copy(bits, data[d.offset:])
+	r.load(d, bits)
+	// buf belongs to the caller, so it must not be recycled by Release
+	r.pool = nil
 	return nil
 }
 
+// decoded is the header and extensions of a marshaled ring.
+type decoded struct {
+	size       uint64
+	hash       uint64
+	offset     int // offset of the bit array
+	provenance Provenance
+	hashing    hashing
+	keyCheck   uint64 // key check of a keyed hash family
+	layout     Layout
+}
+
+// decodeBinary parses everything preceding the bit array of the output of
+// MarshalBinary.
+func decodeBinary(data []byte) (decoded, error) {
+	// 17 bytes for version + size + hash and 1 byte at least for bits
+	if len(data) < headerSize+1 {
+		return decoded{}, fmt.Errorf("incorrect length: %d", len(data))
+	}
+	if data[0] != 1 && data[0] != 2 {
+		return decoded{}, fmt.Errorf("unexpected version: %d", data[0])
+	}
+
+	d := decoded{
+		size:   binary.BigEndian.Uint64(data[1:9]),
+		hash:   binary.BigEndian.Uint64(data[9:17]),
+		offset: headerSize,
+	}
+	if data[0] == 2 {
+		if len(data) < headerSize+extensionLengthSize {
+			return decoded{}, fmt.Errorf("incorrect length: %d", len(data))
+		}
+		length := binary.BigEndian.Uint32(data[headerSize:])
+		d.offset += extensionLengthSize
+		if uint64(length) > uint64(len(data)-d.offset) {
+			return decoded{}, errExtension
+		}
+		exts, err := decodeExtensions(data[d.offset : d.offset+int(length)])
+		if err != nil {
+			return decoded{}, err
+		}
+		d.offset += int(length)
+		for _, ext := range exts {
+			switch {
+			case ext.kind == extensionProvenance:
+				if d.provenance, err = decodeProvenance(ext.payload); err != nil {
+					return decoded{}, err
+				}
+			case ext.kind == extensionHashing:
+				d.hashing, d.keyCheck, err = decodeHashing(ext.payload)
+				if err != nil {
+					return decoded{}, err
+				}
+			case ext.kind == extensionLayout:
+				if d.layout, err = decodeLayout(ext.payload); err != nil {
+					return decoded{}, err
+				}
+			case ext.critical:
+				return decoded{}, unknownExtensionError(ext.kind)
+			}
+		}
+	}
+	// a ring of no bits cannot be tested, and too many rounds cannot be
+	// tested in reasonable time, so neither is used whatever embeds it
+	if d.size == 0 {
+		return decoded{}, fmt.Errorf("invalid size: %d", d.size)
+	}
+	if d.hash == 0 || d.hash > maxValidHash {
+		return decoded{}, fmt.Errorf("invalid hash rounds: %d", d.hash)
+	}
+	if !d.layout.fits(d.size, d.hash) {
+		return decoded{}, fmt.Errorf("size %d does not fit layout %s",
+			d.size, d.layout)
+	}
+	return d, nil
+}
+
+// load replaces the ring with the decoded header and the bit array, which
+// must be sized for it and already filled. The caller must hold the write
+// lock.
+func (r *Bloom) load(d decoded, bits []uint8) {
+	if r.created.IsZero() {
+		r.created = time.Now()
+		r.resetAt = r.created
+	}
+	r.size = d.size
+	r.hash = d.hash
+	// the design parameters are not marshaled
+	r.capacity = 0
+	r.falsePositive = 0
+	r.bits = bits
+	r.digest = computeDigest(r.bits)
+	r.set = countBits(r.bits)
+	r.provenance = d.provenance
+	r.hashing = d.hashing
+	r.layout = d.layout
+}
+
 // MarshalStorage is a marshal function which returns the bit array only,
 // excluding extraneous information of the bloom filter.
 // Included for efficient DB storage purposes
 func (r *Bloom) MarshalStorage() ([]byte, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
+	if err := r.checkUsable("marshal"); err != nil {
+		return nil, err
+	}
 
 	out := make([]byte, len(r.bits))
 	// Exclude version bit
@@ -190,19 +1262,41 @@ func (r *Bloom) MarshalStorage() ([]byte, error) {
 // misconfigurations will not be caught
 // Included for efficient DB storage purpose.
 func (r *Bloom) UnmarshalStorage(data []byte) error {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err := r.checkMutable("unmarshal into"); err != nil {
+		return err
+	}
 
 	if len(data) != len(r.bits) {
 		return errBadSize
 	}
 
 	copy(r.bits[:], data[:])
+	r.digest = computeDigest(r.bits)
+	r.set = countBits(r.bits)
 	return nil
 }
 
+// GetBits copies the bit array into dst, which must be at least BufferSize
+// bytes, and returns the number of bytes copied. Bit p of the filter is bit
+// p%8 of byte p/8. It is MarshalStorage without allocating. It returns
+// errBadSize if dst is too short or a *StateError if the ring was destroyed.
+func (r *Bloom) GetBits(dst []byte) (int, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if err := r.checkUsable("read"); err != nil {
+		return 0, err
+	}
+	if len(dst) < len(r.bits) {
+		return 0, errBadSize
+	}
+	return copy(dst, r.bits), nil
+}
+
 // BufferSize returns the size of the buffer the filter is using, in bytes
 // this is the same size as the outputted buffer from Bloom.MarshalStorage
+// and the length needed by GetBits
 func (r *Bloom) BufferSize() int {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
@@ -215,3 +1309,30 @@ func getBuffSize(size uint64) uint64 {
 	}
 	return size / 8
 }
+
+// designedCapacity returns the number of elements for which a filter with the
+// given parameters is optimal, m*ln2/k, and at least 1.
+func designedCapacity(size, hash uint64) int {
+	n := int(float64(size) * math.Ln2 / float64(hash))
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// countBits returns the number of set bits in the bit array.
+func countBits(b []uint8) uint64 {
+	count := 0
+	for _, v := range b {
+		count += bits.OnesCount8(v)
+	}
+	return uint64(count)
+}
+
+// estimateElements returns the estimated number of elements added to a filter
+// with the given parameters and number of set bits, using the estimator
+// n = -(m/k) * ln(1 - X/m). It is infinite if every bit is set.
+func estimateElements(size, hash, set uint64) float64 {
+	m := float64(size)
+	return -(m / float64(hash)) * math.Log(1-float64(set)/m)
+}
@@ -5,8 +5,10 @@
 package ring
 
 import (
+	"encoding/binary"
 	"fmt"
 	"github.com/stretchr/testify/require"
+	"math"
 	"math/rand"
 	"os"
 	"testing"
@@ -79,6 +81,22 @@ func BenchmarkTest(b *testing.B) {
 	}
 }
 
+// BenchmarkAddUint64 tests adding integers to a Bloom.
+func BenchmarkAddUint64(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		rBench.AddUint64(uint64(i))
+	}
+}
+
+// BenchmarkTestUint64 tests integers in a Bloom.
+func BenchmarkTestUint64(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		rBench.TestUint64(uint64(i))
+	}
+}
+
 // TestBadParameters ensures that errornous parameters return an error.
 func TestBadParameters(t *testing.T) {
 	_, err := Init(100, 1)
@@ -114,6 +132,49 @@ func TestBadParameters(t *testing.T) {
 
 }
 
+// TestOptimalParameters ensures the exported helpers agree with Init and with
+// the rates observed.
+func TestOptimalParameters(t *testing.T) {
+	for _, fp := range []float64{0.1, 0.01, 0.0001} {
+		r, _ := Init(1000, fp)
+		m := OptimalM(1000, fp)
+		require.Equal(t, r.GetM(), m)
+		require.Equal(t, r.GetK(), OptimalK(m, 1000))
+		require.InEpsilon(t, fp, ExpectedFPRate(m, OptimalK(m, 1000), 1000), 0.2)
+		require.InDelta(t, r.TargetFP(), ExpectedFPRate(m, r.GetK(), 1000), fp)
+	}
+	require.Equal(t, uint64(0), OptimalM(100, 1))
+	require.Equal(t, uint64(1), OptimalK(10, 1000))
+	require.Equal(t, uint64(1), OptimalK(10, 0))
+	require.Equal(t, float64(1), ExpectedFPRate(0, 3, 10))
+	require.Equal(t, float64(0), ExpectedFPRate(100, 3, 0))
+}
+
+// TestParametersForBudget ensures the parameters fit the budget and give the
+// lowest rate of any k for the size.
+func TestParametersForBudget(t *testing.T) {
+	for _, budget := range []uint64{1, 100, 1200, 100000} {
+		m, k, fp, err := ParametersForBudget(budget, 1000)
+		require.NoError(t, err)
+		r, err := InitByParameters(m, k)
+		require.NoError(t, err)
+		require.Equal(t, int(budget), r.BufferSize())
+		require.Equal(t, ExpectedFPRate(m, k, 1000), fp)
+		for other := uint64(1); other < 3*k+3; other++ {
+			require.True(t, fp <= ExpectedFPRate(m, other, 1000), "budget %d k %d", budget, other)
+		}
+	}
+
+	// the budget Init would need gives about its rate
+	_, _, fp, _ := ParametersForBudget((OptimalM(1000, 0.01)+7)/8, 1000)
+	require.InEpsilon(t, 0.01, fp, 0.05)
+
+	_, _, _, err := ParametersForBudget(0, 10)
+	require.Equal(t, errBudget, err)
+	_, _, _, err = ParametersForBudget(10, 0)
+	require.Equal(t, errElements, err)
+}
+
 // TestReset ensures the Bloom is cleared on Reset().
 func TestReset(t *testing.T) {
 	buff := make([]byte, 4)
@@ -157,6 +218,276 @@ func TestData(t *testing.T) {
 	}
 }
 
+// BenchmarkAddMany tests adding elements to a Bloom in batches.
+func BenchmarkAddMany(b *testing.B) {
+	items := make([][]byte, 1000)
+	for i := range items {
+		items[i] = make([]byte, 4)
+		intToByte(items[i], i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i += len(items) {
+		rBench.AddMany(items)
+	}
+}
+
+// TestBloom_AddMany ensures AddMany sets the same bits as Add.
+func TestBloom_AddMany(t *testing.T) {
+	r1, _ := Init(10000, fpRate)
+	r2, _ := Init(10000, fpRate)
+	items := make([][]byte, 10000)
+	for i := range items {
+		items[i] = make([]byte, 4)
+		intToByte(items[i], i)
+		r1.Add(items[i])
+	}
+	r2.AddMany(items)
+	r2.AddMany(nil)
+	require.Equal(t, r1.bits, r2.bits)
+	require.Equal(t, r1.Digest(), r2.Digest())
+}
+
+// TestBloom_Uint64 ensures the integer helpers match their byte encoding and
+// do not allocate.
+func TestBloom_Uint64(t *testing.T) {
+	r, _ := Init(1000, fpRate)
+	buff := make([]byte, 8)
+	for i := uint64(0); i < 1000; i++ {
+		v := i * 0x9e3779b97f4a7c15
+		binary.BigEndian.PutUint64(buff, v)
+		require.False(t, r.TestUint64(v))
+		r.AddUint64(v)
+		require.True(t, r.Test(buff))
+		require.True(t, r.TestUint64(v))
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		r.AddUint64(42)
+		r.TestUint64(42)
+	})
+	require.Zero(t, allocs)
+}
+
+// TestBloom_String ensures the string helpers match their bytes and do not
+// allocate.
+func TestBloom_String(t *testing.T) {
+	r, _ := Init(1000, fpRate)
+	for i := 0; i < 1000; i++ {
+		s := fmt.Sprintf("message-%d", i)
+		require.False(t, r.TestString(s))
+		r.AddString(s)
+		require.True(t, r.Test([]byte(s)))
+		require.True(t, r.TestString(s))
+	}
+	r.AddString("")
+	require.True(t, r.Test(nil))
+
+	s := "message-id"
+	allocs := testing.AllocsPerRun(100, func() {
+		r.AddString(s)
+		r.TestString(s)
+	})
+	require.Zero(t, allocs)
+}
+
+// TestBloom_TestMany ensures TestMany and TestManyMask match Test.
+func TestBloom_TestMany(t *testing.T) {
+	r, _ := Init(1000, fpRate)
+	items := make([][]byte, 200)
+	for i := range items {
+		items[i] = make([]byte, 4)
+		intToByte(items[i], i)
+		if i%3 == 0 {
+			r.Add(items[i])
+		}
+	}
+
+	results := r.TestMany(items)
+	mask := r.TestManyMask(items)
+	require.Len(t, results, len(items))
+	require.Len(t, mask, 4)
+	for i, item := range items {
+		require.Equal(t, r.Test(item), results[i])
+		require.Equal(t, results[i], mask[i/64]&(1<<uint(i%64)) != 0)
+	}
+
+	require.Empty(t, r.TestMany(nil))
+	require.Empty(t, r.TestManyMask(nil))
+}
+
+// TestBloom_TestAndAdd ensures TestAndAdd reports prior membership and adds
+// the data.
+func TestBloom_TestAndAdd(t *testing.T) {
+	r, err := Init(1000, fpRate)
+	require.NoError(t, err)
+	buff := make([]byte, 4)
+	for i := 0; i < 1000; i++ {
+		intToByte(buff, i)
+		require.Equal(t, r.Test(buff), r.TestAndAdd(buff))
+		require.True(t, r.Test(buff))
+		require.True(t, r.TestAndAdd(buff))
+	}
+	require.Equal(t, computeDigest(r.bits), r.Digest())
+}
+
+// TestBloom_Clone ensures the clone is a deep copy.
+func TestBloom_Clone(t *testing.T) {
+	r, _ := Init(1000, fpRate)
+	r.Add([]byte("original"))
+	require.NoError(t, r.Transition(StateFrozen))
+
+	c := r.Clone()
+	require.Equal(t, StateBuilding, c.State())
+	require.Equal(t, r.GetSize(), c.GetSize())
+	require.Equal(t, r.GetHashOpCount(), c.GetHashOpCount())
+	require.Equal(t, r.bits, c.bits)
+	require.Equal(t, r.Digest(), c.Digest())
+	require.Equal(t, r.created, c.created)
+	require.True(t, c.Test([]byte("original")))
+
+	c.Add([]byte("clone"))
+	require.True(t, c.Test([]byte("clone")))
+	require.False(t, r.Test([]byte("clone")))
+}
+
+// TestBloom_CopyFrom ensures CopyFrom replaces the ring and reuses its bit
+// array when it is large enough.
+func TestBloom_CopyFrom(t *testing.T) {
+	small, _ := InitByParameters(100, 3)
+	small.Add([]byte("small"))
+	large, _ := InitByParameters(1000, 5)
+	large.Add([]byte("large"))
+
+	dst, _ := InitByParameters(1000, 5)
+	buff := dst.bits
+	require.NoError(t, dst.CopyFrom(small))
+	require.Equal(t, small.GetSize(), dst.GetSize())
+	require.Equal(t, small.GetHashOpCount(), dst.GetHashOpCount())
+	require.Equal(t, small.bits, dst.bits)
+	require.Equal(t, small.Digest(), dst.Digest())
+	require.True(t, dst.Test([]byte("small")))
+	require.Same(t, &buff[0], &dst.bits[0])
+
+	require.NoError(t, dst.CopyFrom(large))
+	require.Equal(t, large.bits, dst.bits)
+	require.False(t, dst.Test([]byte("small")))
+	require.NoError(t, dst.CopyFrom(dst))
+
+	require.NoError(t, small.Transition(StateDestroyed))
+	require.IsType(t, &StateError{}, dst.CopyFrom(small))
+	require.NoError(t, dst.Transition(StateFrozen))
+	require.IsType(t, &StateError{}, dst.CopyFrom(large))
+}
+
+// TestBloom_CopyFromConcurrent ensures copies in opposite directions do not
+// deadlock.
+func TestBloom_CopyFromConcurrent(t *testing.T) {
+	a, _ := Init(1000, fpRate)
+	b, _ := Init(1000, fpRate)
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 1000; i++ {
+			require.NoError(t, a.CopyFrom(b))
+		}
+		close(done)
+	}()
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, b.CopyFrom(a))
+	}
+	<-done
+}
+
+// TestBloom_FillRatio ensures the fill ratio and emptiness follow the bits.
+func TestBloom_FillRatio(t *testing.T) {
+	r, _ := InitByParameters(1000, 1)
+	require.True(t, r.IsEmpty())
+	require.Zero(t, r.FillRatio())
+
+	positions := ProbePositions(0, 1, 1000, []byte("data"))
+	r.Add([]byte("data"))
+	require.False(t, r.IsEmpty())
+	require.Equal(t, 1.0/1000, r.FillRatio())
+	require.True(t, r.bits[positions[0]/8] != 0)
+
+	buff := make([]byte, 4)
+	for i := 0; i < 10000; i++ {
+		intToByte(buff, i)
+		r.Add(buff)
+	}
+	require.True(t, r.FillRatio() > 0.99)
+
+	r.Reset()
+	require.True(t, r.IsEmpty())
+}
+
+// TestBloom_ApproximateCount ensures the estimate is close to the number of
+// distinct elements added.
+func TestBloom_ApproximateCount(t *testing.T) {
+	r, _ := Init(10000, 0.01)
+	require.Zero(t, r.ApproximateCount())
+
+	buff := make([]byte, 4)
+	for i := 0; i < 5000; i++ {
+		intToByte(buff, i)
+		r.Add(buff)
+		r.Add(buff)
+	}
+	require.InDelta(t, 5000, r.ApproximateCount(), 100)
+
+	full, _ := InitByParameters(8, 1)
+	for i := 0; i < 1000; i++ {
+		intToByte(buff, i)
+		full.Add(buff)
+	}
+	require.Equal(t, uint64(math.MaxUint64), full.ApproximateCount())
+}
+
+// TestBloom_CurrentFalsePositiveRate ensures the rate reaches the target at
+// capacity and grows beyond it when overfilled.
+func TestBloom_CurrentFalsePositiveRate(t *testing.T) {
+	r, _ := Init(10000, 0.01)
+	require.Zero(t, r.CurrentFalsePositiveRate())
+
+	buff := make([]byte, 4)
+	for i := 0; i < 10000; i++ {
+		intToByte(buff, i)
+		r.Add(buff)
+	}
+	require.InDelta(t, 0.01, r.CurrentFalsePositiveRate(), 0.002)
+
+	for i := 10000; i < 20000; i++ {
+		intToByte(buff, i)
+		r.Add(buff)
+	}
+	require.True(t, r.CurrentFalsePositiveRate() > 0.1)
+}
+
+// TestBloom_Equal ensures filters are compared by parameters and bits.
+func TestBloom_Equal(t *testing.T) {
+	r1, _ := InitByParameters(1000, 5)
+	r2, _ := InitByParameters(1000, 5)
+	require.True(t, r1.Equal(r2))
+	require.True(t, r1.Equal(r1))
+
+	r1.Add([]byte("data"))
+	require.False(t, r1.Equal(r2))
+	r2.Add([]byte("data"))
+	require.True(t, r1.Equal(r2))
+	require.NoError(t, r2.Transition(StateFrozen))
+	require.True(t, r2.Equal(r1))
+
+	r3, _ := InitByParameters(1000, 4)
+	r3.Add([]byte("data"))
+	require.False(t, r1.Equal(r3))
+	r4, _ := InitByParameters(1001, 5)
+	require.False(t, r4.Equal(r1))
+
+	// a reset filter equals a new one
+	r1.Reset()
+	empty, _ := InitByParameters(1000, 5)
+	require.True(t, r1.Equal(empty))
+}
+
 // TestMerge ensures that a Merge produces the right Bloom.
 func TestMerge(t *testing.T) {
 	var token []byte
@@ -208,6 +539,116 @@ func TestMerge(t *testing.T) {
 	}
 }
 
+// TestMerge_Concurrent ensures merges are safe alongside concurrent Adds to
+// the source and merges in the opposite direction.
+func TestMerge_Concurrent(t *testing.T) {
+	a, _ := Init(10000, fpRate)
+	b, _ := Init(10000, fpRate)
+	require.NoError(t, a.Merge(a))
+
+	done := make(chan struct{})
+	go func() {
+		buff := make([]byte, 4)
+		for i := 0; i < 10000; i++ {
+			intToByte(buff, i)
+			b.Add(buff)
+			if i%100 == 0 {
+				require.NoError(t, b.Merge(a))
+			}
+		}
+		close(done)
+	}()
+	for i := 0; i < 200; i++ {
+		require.NoError(t, a.Merge(b))
+	}
+	<-done
+	require.NoError(t, a.Merge(b))
+	require.True(t, a.Equal(b))
+	require.Equal(t, computeDigest(a.bits), a.Digest())
+
+	require.NoError(t, a.Transition(StateFrozen))
+	require.IsType(t, &StateError{}, a.Merge(a))
+}
+
+// TestMergeMany ensures merging many rings at once matches merging them one
+// at a time, and that nothing changes if an input is incompatible.
+func TestMergeMany(t *testing.T) {
+	inputs := make([]*Bloom, 8)
+	expected, _ := Init(10000, fpRate)
+	buff := make([]byte, 4)
+	for i := range inputs {
+		inputs[i], _ = Init(10000, fpRate)
+		for j := i * 1000; j < (i+1)*1000; j++ {
+			intToByte(buff, j)
+			inputs[i].Add(buff)
+		}
+		require.NoError(t, expected.Merge(inputs[i]))
+	}
+
+	r, _ := Init(10000, fpRate)
+	require.NoError(t, r.MergeMany(append(inputs, r, inputs[0])...))
+	require.True(t, expected.Equal(r))
+	require.Equal(t, computeDigest(r.bits), r.Digest())
+	require.NoError(t, r.MergeMany())
+
+	bad, _ := Init(100, fpRate)
+	before := r.Digest()
+	require.Equal(t, errParameters, r.MergeMany(inputs[0], bad))
+	require.Equal(t, before, r.Digest())
+
+	require.NoError(t, inputs[1].Transition(StateDestroyed))
+	require.IsType(t, &StateError{}, r.MergeMany(inputs[1]))
+}
+
+// BenchmarkMergeMany measures a merge of 100 rings in one call.
+func BenchmarkMergeMany(b *testing.B) {
+	inputs := make([]*Bloom, 100)
+	for i := range inputs {
+		inputs[i], _ = Init(100000, fpRate)
+	}
+	r, _ := Init(100000, fpRate)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.MergeMany(inputs...)
+	}
+}
+
+// TestIntersect ensures elements in both rings remain present, elements in
+// only one are mostly removed, and incompatible rings are rejected.
+func TestIntersect(t *testing.T) {
+	a, _ := Init(10000, fpRate)
+	b, _ := Init(10000, fpRate)
+	buff := make([]byte, 4)
+	for i := 0; i < 6000; i++ {
+		intToByte(buff, i)
+		a.Add(buff)
+	}
+	for i := 4000; i < 10000; i++ {
+		intToByte(buff, i)
+		b.Add(buff)
+	}
+	require.NoError(t, a.Intersect(b))
+	require.Equal(t, computeDigest(a.bits), a.Digest())
+
+	present := 0
+	for i := 0; i < 10000; i++ {
+		intToByte(buff, i)
+		if a.Test(buff) {
+			present++
+		} else {
+			require.False(t, i >= 4000 && i < 6000, "element %d lost", i)
+		}
+	}
+	// the 2000 shared elements and a small number of false positives
+	require.InDelta(t, 2000, present, 100)
+
+	require.NoError(t, a.Intersect(a))
+	c, _ := Init(10000, 0.1)
+	require.Equal(t, errParameters, a.Intersect(c))
+	require.NoError(t, a.Transition(StateFrozen))
+	require.IsType(t, &StateError{}, a.Intersect(b))
+}
+
 // TestMarshalBinary ensures that the Marshal and Unmarshal methods produce
 // duplicate Bloom's.
 func TestMarshalBinary(t *testing.T) {
@@ -258,6 +699,55 @@ func TestMarshalBinary(t *testing.T) {
 	}
 }
 
+// TestBloom_MarshalBinaryPadded ensures padded output has the requested size
+// and is accepted by UnmarshalBinary.
+func TestBloom_MarshalBinaryPadded(t *testing.T) {
+	r, err := InitByParameters(100, 3)
+	require.NoError(t, err)
+	r.Add([]byte("padded"))
+	out, err := r.MarshalBinary()
+	require.NoError(t, err)
+
+	padded, err := r.MarshalBinaryPadded(256)
+	require.NoError(t, err)
+	require.Len(t, padded, 256)
+	require.Equal(t, out, padded[:len(out)])
+
+	r2 := new(Bloom)
+	require.NoError(t, r2.UnmarshalBinary(padded))
+	require.Equal(t, r.bits, r2.bits)
+	require.True(t, r2.Test([]byte("padded")))
+
+	exact, err := r.MarshalBinaryPadded(len(out))
+	require.NoError(t, err)
+	require.Equal(t, out, exact)
+
+	_, err = r.MarshalBinaryPadded(len(out) - 1)
+	require.Error(t, err)
+}
+
+// TestBloom_MarshalBinaryAligned ensures aligned output is a multiple of the
+// alignment and is accepted by UnmarshalBinary.
+func TestBloom_MarshalBinaryAligned(t *testing.T) {
+	r, err := InitByParameters(100, 3)
+	require.NoError(t, err)
+	r.Add([]byte("aligned"))
+
+	for _, align := range []int{1, 8, 30, 64} {
+		out, err := r.MarshalBinaryAligned(align)
+		require.NoError(t, err)
+		require.Zero(t, len(out)%align)
+		require.True(t, len(out)-align < r.BufferSize()+headerSize)
+
+		r2 := new(Bloom)
+		require.NoError(t, r2.UnmarshalBinary(out))
+		require.Equal(t, r.bits, r2.bits)
+	}
+
+	_, err = r.MarshalBinaryAligned(0)
+	require.Error(t, err)
+}
+
 func TestBloom_MarshalStorage(t *testing.T) {
 	// Travis CI has strict memory limits that we hit if too high
 	size := tests / 100
@@ -317,7 +807,7 @@ func TestBloom_StorageSize(t *testing.T) {
 
 	unstored.UnmarshalStorage(marsh)
 
-	require.Equal(t, orig, unstored)
+	require.True(t, orig.Equal(unstored))
 
 }
 
@@ -346,3 +836,273 @@ func intToByte(b []byte, v int) {
 	b[2] = byte(v >> 16)
 	b[3] = byte(v >> 24)
 }
+
+// TestBloom_ParameterAccessors ensures the accessors report the parameters of
+// rings created by Init and derive them for other rings.
+func TestBloom_ParameterAccessors(t *testing.T) {
+	r, err := Init(10000, 0.01)
+	require.NoError(t, err)
+	require.Equal(t, r.GetSize(), r.GetM())
+	require.Equal(t, r.GetHashOpCount(), r.GetK())
+	require.Equal(t, 10000, r.Capacity())
+	require.Equal(t, 0.01, r.TargetFP())
+	require.Equal(t, 10000, r.Clone().Capacity())
+
+	p, err := InitByParameters(r.GetM(), r.GetK())
+	require.NoError(t, err)
+	require.InDelta(t, 10000, p.Capacity(), 1000)
+	// k is rounded up by Init, so the derived ring is slightly more conservative
+	require.InDelta(t, 0.01, p.TargetFP(), 0.005)
+
+	out, _ := r.MarshalBinary()
+	u := new(Bloom)
+	require.NoError(t, u.UnmarshalBinary(out))
+	require.Equal(t, p.Capacity(), u.Capacity())
+	require.Equal(t, p.TargetFP(), u.TargetFP())
+
+	tiny, _ := InitByParameters(1, 8)
+	require.Equal(t, 1, tiny.Capacity())
+}
+
+// TestBloom_Locations ensures Locations matches ProbePositions and the bits
+// set by Add.
+func TestBloom_Locations(t *testing.T) {
+	r, _ := Init(1000, 0.01)
+	data := []byte("location")
+	locations := r.Locations(data)
+	require.Len(t, locations, int(r.GetK()))
+	require.Equal(t, ProbePositions(0, r.GetK(), r.GetM(), data), locations)
+
+	r.Add(data)
+	require.Equal(t, uint64(len(dedupe(locations))), countBits(r.bits))
+	for _, l := range locations {
+		require.NotZero(t, r.bits[l/8]&(1<<(l%8)))
+	}
+}
+
+// TestBloom_SetBits ensures bits set by index are found by Test and TestBits,
+// and out of range indices are rejected.
+func TestBloom_SetBits(t *testing.T) {
+	client, _ := Init(1000, 0.01)
+	server, _ := Init(1000, 0.01)
+	buff := make([]byte, 4)
+	for i := 0; i < 100; i++ {
+		intToByte(buff, i)
+		require.NoError(t, server.SetBits(client.Locations(buff)))
+		require.True(t, server.TestBits(client.Locations(buff)))
+	}
+	for i := 0; i < 100; i++ {
+		intToByte(buff, i)
+		require.True(t, server.Test(buff))
+		client.Add(buff)
+	}
+	require.True(t, client.Equal(server))
+	require.Equal(t, computeDigest(server.bits), server.Digest())
+
+	before := server.Digest()
+	require.Equal(t, errIndex, server.SetBits([]uint64{0, 1, server.GetM()}))
+	require.Equal(t, before, server.Digest())
+	require.False(t, server.TestBits([]uint64{server.GetM()}))
+	require.True(t, server.TestBits(nil))
+
+	require.NoError(t, server.Transition(StateFrozen))
+	require.IsType(t, &StateError{}, server.SetBits([]uint64{0}))
+}
+
+// TestBloom_HashHandle ensures a handle behaves as its data across rings.
+func TestBloom_HashHandle(t *testing.T) {
+	rings := make([]*Bloom, 10)
+	for i := range rings {
+		rings[i], _ = Init(100*(i+1), 0.01)
+	}
+	buff := make([]byte, 4)
+	for i := 0; i < 100; i++ {
+		intToByte(buff, i)
+		h := Hash(buff)
+		rings[i%10].AddHash(h)
+		for j, r := range rings {
+			require.Equal(t, r.Test(buff), r.TestHash(h), "ring %d", j)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		intToByte(buff, i)
+		require.True(t, rings[i%10].Test(buff))
+	}
+}
+
+// BenchmarkTestHash measures testing a pre-hashed element against a ring.
+func BenchmarkTestHash(b *testing.B) {
+	r, _ := Init(100000, 0.01)
+	h := Hash([]byte("notification"))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.TestHash(h)
+	}
+}
+
+// TestBloom_AppendBinary ensures AppendBinary matches MarshalBinary and
+// reuses the buffer when it has capacity.
+func TestBloom_AppendBinary(t *testing.T) {
+	r, _ := Init(1000, 0.01)
+	r.Add([]byte("data"))
+	expected, _ := r.MarshalBinary()
+
+	out, err := r.AppendBinary(nil)
+	require.NoError(t, err)
+	require.Equal(t, expected, out)
+
+	prefix := []byte("frame")
+	out, err = r.AppendBinary(prefix)
+	require.NoError(t, err)
+	require.Equal(t, append([]byte("frame"), expected...), out)
+
+	buff := make([]byte, 0, 2*len(expected))
+	out, err = r.AppendBinary(buff)
+	require.NoError(t, err)
+	require.Equal(t, expected, out)
+	require.Same(t, &buff[:1][0], &out[0])
+
+	allocs := testing.AllocsPerRun(10, func() {
+		r.AppendBinary(buff[:0])
+	})
+	require.Zero(t, allocs)
+}
+
+// TestBloom_GetBits ensures GetBits matches MarshalStorage without
+// allocating.
+func TestBloom_GetBits(t *testing.T) {
+	r, _ := Init(1000, 0.01)
+	r.Add([]byte("data"))
+	stored, _ := r.MarshalStorage()
+
+	dst := make([]byte, r.BufferSize()+8)
+	n, err := r.GetBits(dst)
+	require.NoError(t, err)
+	require.Equal(t, r.BufferSize(), n)
+	require.Equal(t, stored, dst[:n])
+	require.Zero(t, testing.AllocsPerRun(10, func() { r.GetBits(dst) }))
+
+	_, err = r.GetBits(dst[:r.BufferSize()-1])
+	require.Equal(t, errBadSize, err)
+}
+
+// TestBloom_TestAnyEvery ensures TestAny and TestEvery agree with Test.
+func TestBloom_TestAnyEvery(t *testing.T) {
+	r, _ := Init(1000, 0.001)
+	r.Add([]byte("known"))
+	r.Add([]byte("also known"))
+	known := [][]byte{[]byte("known"), []byte("also known")}
+	mixed := [][]byte{[]byte("unknown"), []byte("known")}
+	unknown := [][]byte{[]byte("unknown"), []byte("never added")}
+
+	require.True(t, r.TestAny(known))
+	require.True(t, r.TestAny(mixed))
+	require.False(t, r.TestAny(unknown))
+	require.False(t, r.TestAny(nil))
+
+	require.True(t, r.TestEvery(known))
+	require.False(t, r.TestEvery(mixed))
+	require.False(t, r.TestEvery(unknown))
+	require.True(t, r.TestEvery(nil))
+}
+
+// TestBloom_TestApprox ensures truncated probes never miss added data, agree
+// with Test at full depth, and report more false positives when shallower.
+func TestBloom_TestApprox(t *testing.T) {
+	r, _ := Init(10000, 0.01)
+	buff := make([]byte, 4)
+	for i := 0; i < 10000; i++ {
+		intToByte(buff, i)
+		r.Add(buff)
+		require.True(t, r.TestApprox(buff, 1))
+	}
+
+	shallow, full := 0, 0
+	for i := 10000; i < 20000; i++ {
+		intToByte(buff, i)
+		if r.TestApprox(buff, 1) {
+			shallow++
+		}
+		if r.TestApprox(buff, int(r.GetK())+5) {
+			full++
+		}
+		require.Equal(t, r.Test(buff), r.TestApprox(buff, int(r.GetK())))
+		require.Equal(t, r.TestApprox(buff, 1), r.TestApprox(buff, 0))
+	}
+	require.True(t, shallow > full)
+	// a single probe hits about half of the time at capacity
+	require.InDelta(t, 5000, shallow, 500)
+}
+
+// TestMustInit ensures the Must constructors return rings for valid
+// parameters and panic otherwise.
+func TestMustInit(t *testing.T) {
+	require.Equal(t, 1000, MustInit(1000, 0.01).Capacity())
+	require.Equal(t, uint64(3), MustInitByParameters(100, 3).GetK())
+	require.PanicsWithValue(t, errElements, func() { MustInit(0, 0.01) })
+	require.PanicsWithValue(t, errHash, func() { MustInitByParameters(100, 0) })
+}
+
+// dedupe returns the distinct values of s.
+func dedupe(s []uint64) []uint64 {
+	seen := make(map[uint64]bool)
+	var out []uint64
+	for _, v := range s {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// TestBloom_UnmarshalInto ensures decoding into a caller buffer matches
+// UnmarshalBinary without allocating once the ring exists.
+func TestBloom_UnmarshalInto(t *testing.T) {
+	r, _ := Init(1000, 0.01)
+	buff := make([]byte, 4)
+	for i := 0; i < 100; i++ {
+		intToByte(buff, i)
+		r.Add(buff)
+	}
+	out, _ := r.MarshalBinary()
+
+	mem := make([]byte, r.BufferSize()+16)
+	for i := range mem {
+		mem[i] = 0xff
+	}
+	var u Bloom
+	require.NoError(t, u.UnmarshalInto(mem, out))
+	require.True(t, r.Equal(&u))
+	require.Same(t, &mem[0], &u.bits[0])
+	require.Zero(t, testing.AllocsPerRun(10, func() {
+		u.UnmarshalInto(mem, out)
+	}))
+
+	// trailing bytes omitted by the data are cleared
+	require.NoError(t, u.UnmarshalInto(mem, out[:headerSize+1]))
+	require.Equal(t, countBits(out[headerSize:headerSize+1]), countBits(u.bits))
+	require.Equal(t, computeDigest(u.bits), u.Digest())
+
+	require.Equal(t, errBadSize, u.UnmarshalInto(mem[:r.BufferSize()-1], out))
+	require.Error(t, u.UnmarshalInto(mem, out[:3]))
+}
+
+// TestBloom_UnmarshalBinary_BadHeader ensures a ring of no bits or with too
+// many hash rounds is rejected by every decoder, rather than failing when
+// used.
+func TestBloom_UnmarshalBinary_BadHeader(t *testing.T) {
+	r, _ := Init(100, fpRate)
+	out, _ := r.MarshalBinary()
+	zero := append([]byte{}, out...)
+	binary.BigEndian.PutUint64(zero[1:9], 0)
+	many := append([]byte{}, out...)
+	binary.BigEndian.PutUint64(many[9:17], 1<<40)
+	for i, bad := range [][]byte{zero, many} {
+		require.Error(t, new(Bloom).UnmarshalBinary(bad), "header %d", i)
+		_, err := FromGCS(bad)
+		require.Error(t, err, "header %d", i)
+	}
+	binary.BigEndian.PutUint64(many[9:17], maxValidHash)
+	require.NoError(t, new(Bloom).UnmarshalBinary(many))
+}
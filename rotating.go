@@ -0,0 +1,162 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	errGenerations = errors.New("error: generations must be greater than 0")
+	errInterval    = errors.New("error: interval must be greater than 0")
+)
+
+// Ring is a sliding window of rings, one per generation. Data is added to the
+// current generation, and tested against all of them. Every interval the
+// oldest generation is reset and becomes the current one, so data is
+// remembered for between generations-1 and generations intervals after it
+// was last added. Rotation happens as the Ring is used; no goroutine is
+// started.
+type Ring struct {
+	interval    time.Duration
+	mutex       sync.RWMutex // guards the fields below
+	generations []*Bloom
+	current     int       // index of the current generation
+	rotatedAt   time.Time // start of the current generation
+}
+
+// NewRing initializes and returns a new Ring of generations rings, each
+// rotated out after interval, or an error. Each generation is initialized as
+// Init would for the elements added in one interval, the falsePositive rate
+// and options, so a Test against all of them has a false positive rate of up
+// to generations times falsePositive. With WithRandomSeed, the generations
+// share one seed.
+func NewRing(generations int, interval time.Duration, elements int,
+	falsePositive float64, opts ...Option) (*Ring, error) {
+	if generations <= 0 {
+		return nil, errGenerations
+	}
+	if interval <= 0 {
+		return nil, errInterval
+	}
+	g := &Ring{
+		interval:    interval,
+		generations: make([]*Bloom, generations),
+		rotatedAt:   time.Now(),
+	}
+	for i := range g.generations {
+		r, err := Init(elements, falsePositive, opts...)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			// share any random seed drawn for the first generation
+			opts = append(opts[:len(opts):len(opts)], withHashingOf(r))
+		}
+		g.generations[i] = r
+	}
+	return g, nil
+}
+
+// Add adds the data to the current generation.
+func (g *Ring) Add(data []byte) {
+	g.advance()
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	g.generations[g.current].Add(data)
+}
+
+// Test returns a bool if the data is in any generation. True indicates that
+// the data may have been added within the window, while false indicates that
+// it was not.
+func (g *Ring) Test(data []byte) bool {
+	g.advance()
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	return g.test(g.generations[0].Hash(data))
+}
+
+// TestAndAdd is equivalent to calling Test(data) then Add(data) atomically,
+// so that of concurrent calls with the same data only one returns false. Data
+// found in an older generation is added to the current one, keeping it in
+// the window.
+func (g *Ring) TestAndAdd(data []byte) bool {
+	g.advance()
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	h := g.generations[0].Hash(data)
+	present := g.test(h)
+	g.generations[g.current].AddHash(h)
+	return present
+}
+
+// Rotate starts a new generation now, resetting the oldest one in its place.
+func (g *Ring) Rotate() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.rotate(1)
+	g.rotatedAt = time.Now()
+}
+
+// Generations returns the rings of the generations, from the oldest to the
+// current one. They are shared with the Ring, and are reset as they expire.
+func (g *Ring) Generations() []*Bloom {
+	g.advance()
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	out := make([]*Bloom, len(g.generations))
+	for i := range out {
+		out[i] = g.generations[(g.current+1+i)%len(g.generations)]
+	}
+	return out
+}
+
+// advance rotates out the generations that have expired since the last
+// rotation.
+func (g *Ring) advance() {
+	now := time.Now()
+	g.mutex.RLock()
+	due := now.Sub(g.rotatedAt) >= g.interval
+	g.mutex.RUnlock()
+	if !due {
+		return
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	// another caller may have rotated first
+	elapsed := now.Sub(g.rotatedAt) / g.interval
+	if elapsed <= 0 {
+		return
+	}
+	g.rotate(int64(elapsed))
+	g.rotatedAt = g.rotatedAt.Add(elapsed * g.interval)
+}
+
+// rotate moves the current generation on n times, resetting each generation
+// it moves to. The caller must hold the write lock.
+func (g *Ring) rotate(n int64) {
+	if n > int64(len(g.generations)) {
+		n = int64(len(g.generations))
+	}
+	for ; n > 0; n-- {
+		g.current = (g.current + 1) % len(g.generations)
+		g.generations[g.current].Reset()
+	}
+}
+
+// test returns true if the element of the handle is in any generation, the
+// newest first. The caller must hold the lock.
+func (g *Ring) test(h HashHandle) bool {
+	for i := range g.generations {
+		index := (g.current - i + len(g.generations)) % len(g.generations)
+		if g.generations[index].TestHash(h) {
+			return true
+		}
+	}
+	return false
+}
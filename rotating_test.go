@@ -0,0 +1,93 @@
+package ring
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestRing ensures data is found until its generation is rotated out.
+func TestRing(t *testing.T) {
+	g, err := NewRing(3, time.Hour, 100, fpRate)
+	require.NoError(t, err)
+	g.Add([]byte("first"))
+	require.True(t, g.Test([]byte("first")))
+
+	g.Rotate()
+	g.Add([]byte("second"))
+	g.Rotate()
+	require.True(t, g.Test([]byte("first")))
+	require.True(t, g.Test([]byte("second")))
+
+	g.Rotate()
+	require.False(t, g.Test([]byte("first")))
+	require.True(t, g.Test([]byte("second")))
+	g.Rotate()
+	require.False(t, g.Test([]byte("second")))
+
+	_, err = NewRing(0, time.Hour, 100, fpRate)
+	require.Equal(t, errGenerations, err)
+	_, err = NewRing(3, 0, 100, fpRate)
+	require.Equal(t, errInterval, err)
+	_, err = NewRing(3, time.Hour, 0, fpRate)
+	require.Equal(t, errElements, err)
+}
+
+// TestRing_Expiry ensures generations are rotated out as their intervals
+// elapse, and that the rings are recycled.
+func TestRing_Expiry(t *testing.T) {
+	g, _ := NewRing(3, time.Hour, 100, fpRate)
+	rings := g.Generations()
+	g.Add([]byte("old"))
+
+	g.rotatedAt = g.rotatedAt.Add(-90 * time.Minute)
+	g.Add([]byte("new"))
+	require.True(t, g.Test([]byte("old")))
+	require.Equal(t, rings[1], g.Generations()[0])
+	// the start of the generation stays aligned to the interval
+	require.True(t, time.Since(g.rotatedAt) >= 30*time.Minute)
+
+	g.rotatedAt = g.rotatedAt.Add(-time.Hour)
+	require.True(t, g.Test([]byte("old")))
+	g.rotatedAt = g.rotatedAt.Add(-time.Hour)
+	require.False(t, g.Test([]byte("old")))
+	require.True(t, g.Test([]byte("new")))
+
+	// a long pause expires everything, without rotating more than once round
+	g.rotatedAt = g.rotatedAt.Add(-100 * time.Hour)
+	require.False(t, g.Test([]byte("new")))
+	require.Len(t, g.Generations(), 3)
+	for _, r := range g.Generations() {
+		require.True(t, r.IsEmpty())
+		require.Contains(t, rings, r)
+	}
+}
+
+// TestRing_TestAndAdd ensures data seen within the window is reported once,
+// and refreshed into the current generation.
+func TestRing_TestAndAdd(t *testing.T) {
+	g, _ := NewRing(2, time.Hour, 100, fpRate, WithSeed(3))
+	require.False(t, g.TestAndAdd([]byte("data")))
+	require.True(t, g.TestAndAdd([]byte("data")))
+	g.Rotate()
+	require.True(t, g.TestAndAdd([]byte("data")))
+	g.Rotate()
+	// it was refreshed into the previous generation
+	require.True(t, g.Test([]byte("data")))
+	g.Rotate()
+	require.False(t, g.TestAndAdd([]byte("data")))
+}
+
+// TestRing_RandomSeed ensures the generations share one random seed, so that
+// testing absent data does not panic on mismatched hashing.
+func TestRing_RandomSeed(t *testing.T) {
+	g, err := NewRing(3, time.Hour, 100, 0.01, WithRandomSeed())
+	require.NoError(t, err)
+	g.Add([]byte("present"))
+	require.True(t, g.Test([]byte("present")))
+	require.NotPanics(t, func() { g.Test([]byte("absent")) })
+	for _, r := range g.generations[1:] {
+		require.Equal(t, g.generations[0].hashing, r.hashing)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+)
+
+var errSaltKey = errors.New("error: the key must be at least 16 bytes")
+
+// SaltedBloom is a ring whose elements are first replaced by their
+// HMAC-SHA256 under a key, so that the same element sets different bits in
+// the rings of different keys, such as those of different users. Unlike a
+// seed, the key is never marshaled with the ring, and without it the bits of
+// an element cannot be computed. HMAC is fast, so this does not slow the
+// enumeration of low-entropy members by someone holding the key; see
+// HardenedBloom for that.
+type SaltedBloom struct {
+	r   *Bloom
+	key []byte
+}
+
+// NewSalted returns a SaltedBloom adding to and testing b, which must not be
+// used directly. It returns an error if the key is shorter than 16 bytes.
+func NewSalted(b *Bloom, key []byte) (*SaltedBloom, error) {
+	if len(key) < 16 {
+		return nil, errSaltKey
+	}
+	return &SaltedBloom{r: b, key: append([]byte{}, key...)}, nil
+}
+
+// Add adds the data to the ring.
+func (s *SaltedBloom) Add(data []byte) {
+	s.r.Add(s.mac(data))
+}
+
+// Test returns a bool if the data is in the ring. True indicates that the data
+// may be in the ring, while false indicates that the data is not in the ring.
+func (s *SaltedBloom) Test(data []byte) bool {
+	return s.r.Test(s.mac(data))
+}
+
+// Bloom returns the ring, for marshaling or inspection.
+func (s *SaltedBloom) Bloom() *Bloom {
+	return s.r
+}
+
+// mac returns the HMAC-SHA256 of the data under the key.
+func (s *SaltedBloom) mac(data []byte) []byte {
+	m := hmac.New(sha256.New, s.key)
+	m.Write(data)
+	return m.Sum(nil)
+}
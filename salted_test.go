@@ -0,0 +1,36 @@
+package ring
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestSaltedBloom ensures a salted ring holds its data under its key only.
+func TestSaltedBloom(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	r, _ := Init(100, fpRate)
+	s, err := NewSalted(r, key)
+	require.NoError(t, err)
+	data := []byte("member")
+	s.Add(data)
+	require.True(t, s.Test(data))
+	require.False(t, s.Test([]byte("other")))
+	require.False(t, r.Test(data))
+	require.Equal(t, r, s.Bloom())
+
+	m := hmac.New(sha256.New, key)
+	m.Write(data)
+	require.True(t, r.Test(m.Sum(nil)))
+
+	// the key is copied, so changing it afterwards has no effect
+	key[0] = 'x'
+	require.True(t, s.Test(data))
+	other, _ := NewSalted(r, key)
+	require.False(t, other.Test(data))
+
+	_, err = NewSalted(r, key[:15])
+	require.Equal(t, errSaltKey, err)
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import "math/rand"
+
+// BitSample is the value of a single bit of the ring.
+type BitSample struct {
+	Index uint64
+	Value bool
+}
+
+// SampleBits returns the values of n bits chosen pseudo-randomly from the
+// seed. Rings of the same size sample the same indices for the same seed, so
+// two replicas can compare their samples to spot-check agreement before a
+// full reconciliation. Indices may repeat.
+func (r *Bloom) SampleBits(n int, seed int64) []BitSample {
+	if n <= 0 {
+		return nil
+	}
+	rng := rand.New(rand.NewSource(seed))
+	samples := make([]BitSample, n)
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	r.mustBeUsable("sample")
+	for i := range samples {
+		index := uint64(rng.Int63n(int64(r.size)))
+		samples[i] = BitSample{
+			Index: index,
+			Value: r.bits[index/8]&(1<<(index%8)) != 0,
+		}
+	}
+	return samples
+}
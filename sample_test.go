@@ -0,0 +1,44 @@
+package ring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestBloom_SampleBits ensures replicas sample the same indices and the
+// values match the bits.
+func TestBloom_SampleBits(t *testing.T) {
+	r1, _ := Init(1000, 0.01)
+	r2, _ := Init(1000, 0.01)
+	buff := make([]byte, 4)
+	for i := 0; i < 500; i++ {
+		intToByte(buff, i)
+		r1.Add(buff)
+		r2.Add(buff)
+	}
+
+	s1 := r1.SampleBits(100, 42)
+	require.Len(t, s1, 100)
+	require.Equal(t, s1, r2.SampleBits(100, 42))
+	require.NotEqual(t, s1, r1.SampleBits(100, 43))
+
+	set := 0
+	for _, s := range s1 {
+		require.True(t, s.Index < r1.GetSize())
+		require.Equal(t, r1.bits[s.Index/8]&(1<<(s.Index%8)) != 0, s.Value)
+		if s.Value {
+			set++
+		}
+	}
+	require.True(t, set > 0 && set < 100)
+
+	// a diverged replica disagrees on some samples
+	for i := 500; i < 1000; i++ {
+		intToByte(buff, i)
+		r2.Add(buff)
+	}
+	require.NotEqual(t, s1, r2.SampleBits(100, 42))
+
+	require.Nil(t, r1.SampleBits(0, 42))
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import "errors"
+
+var (
+	errSaturationThreshold = errors.New("error: threshold must be greater than 0 and at most 1")
+	errSaturationCallback  = errors.New("error: the saturation callback must not be nil")
+)
+
+// saturationWatch is a callback registered by WithSaturationCallback.
+type saturationWatch struct {
+	threshold float64
+	fn        func(fillRatio float64)
+	fired     bool // the fill is at or above the threshold
+}
+
+// WithSaturationCallback calls fn with the fill ratio whenever an operation
+// brings the fraction of set bits in the ring from below threshold to at or
+// above it, for example to rotate the ring or raise an alert before its false
+// positive rate climbs. A ring is at its design capacity at a fill of about
+// 1/2. The callback is called again only after the fill has dropped below the
+// threshold, such as by Reset, and crossed it once more. It is called by the
+// goroutine of the operation after the lock of the ring is released, so it
+// may use the ring, but slow callbacks delay the operation. To receive on a
+// channel, pass a callback that sends to it without blocking. The option may
+// be given several times. It returns an error from the constructor if the
+// threshold is not greater than 0 and at most 1, or fn is nil.
+func WithSaturationCallback(threshold float64, fn func(fillRatio float64)) Option {
+	return func(r *Bloom) error {
+		if threshold <= 0 || threshold > 1 {
+			return errSaturationThreshold
+		}
+		if fn == nil {
+			return errSaturationCallback
+		}
+		r.saturation = append(r.saturation,
+			&saturationWatch{threshold: threshold, fn: fn})
+		return nil
+	}
+}
+
+// checkSaturation returns the fill ratio and the callbacks whose threshold it
+// has crossed since the last check, re-arming those it has dropped below. The
+// caller must hold the write lock.
+func (r *Bloom) checkSaturation() (float64, []func(float64)) {
+	if r.size == 0 {
+		return 0, nil
+	}
+	fill := float64(r.set) / float64(r.size)
+	var triggered []func(float64)
+	for _, w := range r.saturation {
+		above := fill >= w.threshold
+		if above && !w.fired {
+			triggered = append(triggered, w.fn)
+		}
+		w.fired = above
+	}
+	return fill, triggered
+}
@@ -0,0 +1,76 @@
+package ring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestBloom_SaturationCallback ensures callbacks fire once as the fill crosses
+// their threshold, and again only after dropping below it.
+func TestBloom_SaturationCallback(t *testing.T) {
+	var half, most []float64
+	var r *Bloom
+	r, err := Init(1000, fpRate,
+		WithSaturationCallback(0.5, func(fill float64) {
+			// the lock has been released
+			half = append(half, fill)
+			require.True(t, r.FillRatio() >= 0.5)
+		}),
+		WithSaturationCallback(0.9, func(fill float64) { most = append(most, fill) }))
+	require.NoError(t, err)
+
+	fillRange(r, 0, 900)
+	require.Empty(t, half)
+	fillRange(r, 900, 2000)
+	require.Len(t, half, 1)
+	require.True(t, half[0] >= 0.5 && half[0] < 0.51, "fill %v", half[0])
+	require.Empty(t, most)
+
+	r.Reset()
+	m, _ := Init(1000, fpRate)
+	fillRange(m, 0, 5000)
+	require.NoError(t, r.Merge(m))
+	require.Len(t, half, 2)
+	require.Len(t, most, 1)
+	require.Equal(t, m.FillRatio(), most[0])
+
+	_, err = Init(10, fpRate, WithSaturationCallback(0, func(float64) {}))
+	require.Equal(t, errSaturationThreshold, err)
+	_, err = Init(10, fpRate, WithSaturationCallback(0.5, nil))
+	require.Equal(t, errSaturationCallback, err)
+}
+
+// TestBloom_SetCount ensures the count of set bits is kept current by every
+// operation that changes the bit array.
+func TestBloom_SetCount(t *testing.T) {
+	r, _ := Init(100000, fpRate, WithLayout(LayoutPartitioned))
+	requireSet := func(r *Bloom) {
+		require.Equal(t, countBits(r.bits), r.set)
+	}
+	fillRange(r, 0, 1000)
+	requireSet(r)
+
+	m, _ := Init(100000, fpRate, WithLayout(LayoutPartitioned))
+	fillRange(m, 500, 2000)
+	require.True(t, len(r.bits) >= parallelMergeBytes)
+	require.NoError(t, r.Merge(m))
+	requireSet(r)
+
+	o, _ := Init(100000, fpRate, WithLayout(LayoutPartitioned))
+	fillRange(o, 0, 700)
+	require.NoError(t, r.Intersect(o))
+	requireSet(r)
+
+	out, _ := m.MarshalBinary()
+	require.NoError(t, r.UnmarshalBinary(out))
+	requireSet(r)
+	stored, _ := o.MarshalStorage()
+	require.NoError(t, r.UnmarshalStorage(stored))
+	requireSet(r)
+	require.NoError(t, r.CopyFrom(m))
+	requireSet(r)
+	requireSet(r.Clone())
+	r.Reset()
+	requireSet(r)
+}
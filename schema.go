@@ -0,0 +1,88 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// schemaProbe is the data hashed for the seed fingerprint of a schema.
+var schemaProbe = []byte("elixxir bloomfilter schema")
+
+// Schema is the machine-readable description of a ring returned, encoded as
+// JSON, by Bloom.Schema. Two rings can exchange marshaled data if their
+// HashFamily, SeedFingerprint, M, K, and Layout are equal and the reader
+// supports the FormatVersion and every critical flag.
+type Schema struct {
+	// FormatVersion is the version MarshalBinary currently produces.
+	FormatVersion uint8 `json:"format_version"`
+	// HashFamily names the hashing scheme, as described by ProbePositions.
+	HashFamily string `json:"hash_family"`
+	// SeedFingerprint is the first probe of a fixed input in hexadecimal,
+	// which differs between hash families and seeds. It is only a check that
+	// two rings hash alike; the seed is easily recovered from it.
+	SeedFingerprint string `json:"seed_fingerprint"`
+	M               uint64 `json:"m"`               // number of bits
+	K               uint64 `json:"k"`               // number of hash rounds
+	Layout          string `json:"layout"`          // placement of bits
+	Bytes           int    `json:"bytes"`           // bytes of bit array
+	MarshaledBytes  int    `json:"marshaled_bytes"` // bytes of MarshalBinary output
+	State           string `json:"state"`           // lifecycle state
+	Flags           []Flag `json:"flags,omitempty"` // extensions carried when marshaled
+}
+
+// Flag describes an extension carried by a marshaled ring.
+type Flag struct {
+	Name     string `json:"name"`
+	Type     uint8  `json:"type"`
+	Critical bool   `json:"critical"`
+}
+
+// extensionNames contains the name of each known extension type.
+var extensionNames = map[uint8]string{
+	extensionProvenance: "provenance",
+	extensionHashing:    "hashing",
+	extensionLayout:     "layout",
+}
+
+// Schema returns the description of the ring as JSON. It returns a
+// *StateError if the ring has been destroyed.
+func (r *Bloom) Schema() ([]byte, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if err := r.checkUsable("describe"); err != nil {
+		return nil, err
+	}
+
+	section := r.extensionSection()
+	s := Schema{
+		FormatVersion: 1,
+		HashFamily:    r.hashing.family.String(),
+		SeedFingerprint: fmt.Sprintf("%016x",
+			getRound(r.hashing.sum(schemaProbe), 0)),
+		M:              r.size,
+		K:              r.hash,
+		Layout:         r.layout.String(),
+		Bytes:          len(r.bits),
+		MarshaledBytes: bitsOffset(section) + len(r.bits),
+		State:          r.state.String(),
+	}
+	if section != nil {
+		s.FormatVersion = 2
+		exts, err := decodeExtensions(section)
+		if err != nil {
+			return nil, err
+		}
+		for _, ext := range exts {
+			s.Flags = append(s.Flags, Flag{
+				Name:     extensionNames[ext.kind],
+				Type:     ext.kind,
+				Critical: ext.critical,
+			})
+		}
+	}
+	return json.Marshal(s)
+}
@@ -0,0 +1,45 @@
+package ring
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestBloom_Schema ensures the schema describes the ring and its extensions.
+func TestBloom_Schema(t *testing.T) {
+	r, err := Init(1000, 0.01)
+	require.NoError(t, err)
+
+	out, err := r.Schema()
+	require.NoError(t, err)
+	var s Schema
+	require.NoError(t, json.Unmarshal(out, &s))
+	marshaled, _ := r.MarshalBinary()
+	require.Equal(t, uint8(1), s.FormatVersion)
+	require.Equal(t, HashMurmur3.String(), s.HashFamily)
+	require.Len(t, s.SeedFingerprint, 16)
+	require.Equal(t, r.GetM(), s.M)
+	require.Equal(t, r.GetK(), s.K)
+	require.Equal(t, r.BufferSize(), s.Bytes)
+	require.Equal(t, len(marshaled), s.MarshaledBytes)
+	require.Equal(t, "building", s.State)
+	require.Empty(t, s.Flags)
+
+	r.AddContributor([]byte("node"))
+	out, err = r.Schema()
+	require.NoError(t, err)
+	var s2 Schema
+	require.NoError(t, json.Unmarshal(out, &s2))
+	marshaled, _ = r.MarshalBinary()
+	require.Equal(t, uint8(2), s2.FormatVersion)
+	require.Equal(t, len(marshaled), s2.MarshaledBytes)
+	require.Equal(t, []Flag{{Name: "provenance", Type: extensionProvenance}},
+		s2.Flags)
+	require.Equal(t, s.SeedFingerprint, s2.SeedFingerprint)
+
+	require.NoError(t, r.Transition(StateDestroyed))
+	_, err = r.Schema()
+	require.IsType(t, &StateError{}, err)
+}
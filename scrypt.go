@@ -0,0 +1,154 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+)
+
+var errScryptParams = errors.New("error: scrypt N must be a power of 2 greater than 1 and at most 2^24, and R and P greater than 0 with R*P below 2^30")
+
+// ScryptParams contains the cost parameters of scrypt: N is the CPU and
+// memory cost, which must be a power of 2, R the block size and P the
+// parallelization. Each hash takes 128*N*R bytes of memory.
+type ScryptParams struct {
+	N, R, P int
+}
+
+// DefaultScryptParams are the parameters recommended for interactive use:
+// 32 MiB and about 100ms per hash on a current CPU.
+var DefaultScryptParams = ScryptParams{N: 1 << 15, R: 8, P: 1}
+
+// valid returns true if scrypt can be run with the parameters.
+func (p ScryptParams) valid() bool {
+	return p.N > 1 && p.N&(p.N-1) == 0 && p.N <= 1<<24 &&
+		p.R > 0 && p.P > 0 && uint64(p.R)*uint64(p.P) < 1<<30
+}
+
+// scrypt returns the keyLen byte key derived from the password and salt by
+// scrypt, as specified by RFC 7914. The parameters must be valid.
+func scrypt(password, salt []byte, params ScryptParams, keyLen int) []byte {
+	blockLen := 128 * params.R
+	b := pbkdf2SHA256(password, salt, 1, params.P*blockLen)
+	x := make([]uint32, 32*params.R)
+	v := make([]uint32, 32*params.R*params.N)
+	y := make([]uint32, 32*params.R)
+	for i := 0; i < params.P; i++ {
+		scryptROMix(b[i*blockLen:(i+1)*blockLen], x, y, v, params.N)
+	}
+	return pbkdf2SHA256(password, b, 1, keyLen)
+}
+
+// scryptROMix mixes the block b in place using the scratch space x, y and v.
+func scryptROMix(b []byte, x, y, v []uint32, n int) {
+	for i := range x {
+		x[i] = binary.LittleEndian.Uint32(b[4*i:])
+	}
+	for i := 0; i < n; i++ {
+		copy(v[i*len(x):], x)
+		scryptBlockMix(x, y)
+	}
+	for i := 0; i < n; i++ {
+		j := int(x[len(x)-16]) & (n - 1)
+		for k, w := range v[j*len(x) : (j+1)*len(x)] {
+			x[k] ^= w
+		}
+		scryptBlockMix(x, y)
+	}
+	for i, w := range x {
+		binary.LittleEndian.PutUint32(b[4*i:], w)
+	}
+}
+
+// scryptBlockMix mixes the 2r 64-byte blocks of b in place, using y as
+// scratch space.
+func scryptBlockMix(b, y []uint32) {
+	var t [16]uint32
+	copy(t[:], b[len(b)-16:])
+	blocks := len(b) / 16
+	for i := 0; i < blocks; i++ {
+		for j := range t {
+			t[j] ^= b[i*16+j]
+		}
+		salsa208(&t)
+		// even blocks go to the first half and odd to the second
+		copy(y[(i/2+i%2*blocks/2)*16:], t[:])
+	}
+	copy(b, y)
+}
+
+// salsa208 applies the Salsa20/8 core to the block in place.
+func salsa208(b *[16]uint32) {
+	x := *b
+	for i := 0; i < 8; i += 2 {
+		x[4] ^= bits.RotateLeft32(x[0]+x[12], 7)
+		x[8] ^= bits.RotateLeft32(x[4]+x[0], 9)
+		x[12] ^= bits.RotateLeft32(x[8]+x[4], 13)
+		x[0] ^= bits.RotateLeft32(x[12]+x[8], 18)
+		x[9] ^= bits.RotateLeft32(x[5]+x[1], 7)
+		x[13] ^= bits.RotateLeft32(x[9]+x[5], 9)
+		x[1] ^= bits.RotateLeft32(x[13]+x[9], 13)
+		x[5] ^= bits.RotateLeft32(x[1]+x[13], 18)
+		x[14] ^= bits.RotateLeft32(x[10]+x[6], 7)
+		x[2] ^= bits.RotateLeft32(x[14]+x[10], 9)
+		x[6] ^= bits.RotateLeft32(x[2]+x[14], 13)
+		x[10] ^= bits.RotateLeft32(x[6]+x[2], 18)
+		x[3] ^= bits.RotateLeft32(x[15]+x[11], 7)
+		x[7] ^= bits.RotateLeft32(x[3]+x[15], 9)
+		x[11] ^= bits.RotateLeft32(x[7]+x[3], 13)
+		x[15] ^= bits.RotateLeft32(x[11]+x[7], 18)
+
+		x[1] ^= bits.RotateLeft32(x[0]+x[3], 7)
+		x[2] ^= bits.RotateLeft32(x[1]+x[0], 9)
+		x[3] ^= bits.RotateLeft32(x[2]+x[1], 13)
+		x[0] ^= bits.RotateLeft32(x[3]+x[2], 18)
+		x[6] ^= bits.RotateLeft32(x[5]+x[4], 7)
+		x[7] ^= bits.RotateLeft32(x[6]+x[5], 9)
+		x[4] ^= bits.RotateLeft32(x[7]+x[6], 13)
+		x[5] ^= bits.RotateLeft32(x[4]+x[7], 18)
+		x[11] ^= bits.RotateLeft32(x[10]+x[9], 7)
+		x[8] ^= bits.RotateLeft32(x[11]+x[10], 9)
+		x[9] ^= bits.RotateLeft32(x[8]+x[11], 13)
+		x[10] ^= bits.RotateLeft32(x[9]+x[8], 18)
+		x[12] ^= bits.RotateLeft32(x[15]+x[14], 7)
+		x[13] ^= bits.RotateLeft32(x[12]+x[15], 9)
+		x[14] ^= bits.RotateLeft32(x[13]+x[12], 13)
+		x[15] ^= bits.RotateLeft32(x[14]+x[13], 18)
+	}
+	for i := range b {
+		b[i] += x[i]
+	}
+}
+
+// pbkdf2SHA256 returns the keyLen byte key derived from the password and salt
+// by PBKDF2 with HMAC-SHA256, as specified by RFC 8018.
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	out := make([]byte, 0, keyLen+sha256.Size)
+	var index [4]byte
+	u := make([]byte, 0, sha256.Size)
+	for block := uint32(1); len(out) < keyLen; block++ {
+		binary.BigEndian.PutUint32(index[:], block)
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(index[:])
+		u = prf.Sum(u[:0])
+		t := append([]byte{}, u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		out = append(out, t...)
+	}
+	return out[:keyLen]
+}
@@ -0,0 +1,30 @@
+package ring
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestScrypt ensures the output matches the test vectors of RFC 7914.
+func TestScrypt(t *testing.T) {
+	out := scrypt(nil, nil, ScryptParams{N: 16, R: 1, P: 1}, 64)
+	require.Equal(t, "77d6576238657b203b19ca42c18a0497f16b4844e3074ae8dfdffa3fede21442"+
+		"fcd0069ded0948f8326a753a0fc81f17e8d3e0fb2e0d3628cf35e20c38d18906",
+		hex.EncodeToString(out))
+
+	out = scrypt([]byte("password"), []byte("NaCl"),
+		ScryptParams{N: 1024, R: 8, P: 16}, 64)
+	require.Equal(t, "fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b373162"+
+		"2eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640",
+		hex.EncodeToString(out))
+}
+
+// TestPBKDF2SHA256 ensures the output matches a published PBKDF2-HMAC-SHA256
+// vector.
+func TestPBKDF2SHA256(t *testing.T) {
+	out := pbkdf2SHA256([]byte("password"), []byte("salt"), 2, 32)
+	require.Equal(t, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43",
+		hex.EncodeToString(out))
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+var (
+	errShareCount     = errors.New("error: threshold must be at least 2 and no more than shares, which must be at most 255")
+	errTooFewShares   = errors.New("error: not enough shares to reconstruct the filter")
+	errShareMalformed = errors.New("error: shares are malformed or do not belong together")
+)
+
+// shareHeaderSize is the number of bytes preceding the secret in a share: 1
+// byte of x coordinate and 1 byte of threshold.
+const shareHeaderSize = 2
+
+// SplitShares marshals the ring and splits the output into n shares using
+// Shamir's secret sharing over GF(2^8), such that any threshold of them
+// reconstruct it with CombineShares while fewer reveal nothing about its
+// contents, only its length. This allows a sensitive filter to be stored
+// across several nodes without any single one learning it. The threshold must
+// be at least 2 and no more than n, which must be at most 255.
+func (r *Bloom) SplitShares(n, threshold int) ([][]byte, error) {
+	data, err := r.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return splitShares(data, n, threshold, rand.Reader)
+}
+
+// CombineShares reconstructs a ring from at least the threshold number of the
+// shares produced by SplitShares. It returns an error if there are too few
+// shares or they do not belong to the same split. Shares that were altered
+// are not detected and produce a corrupted ring. The options are applied
+// before decoding, so a keyed ring can be reconstructed by passing WithKey.
+func CombineShares(shares [][]byte, opts ...Option) (*Bloom, error) {
+	data, err := combineShares(shares)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalWith(data, opts)
+}
+
+// splitShares splits the secret into n shares with the given threshold,
+// drawing the polynomial coefficients from rng. Each share is its x
+// coordinate, the threshold, and the value of each byte's polynomial at x.
+func splitShares(secret []byte, n, threshold int, rng io.Reader) ([][]byte, error) {
+	if threshold < 2 || threshold > n || n > 255 {
+		return nil, errShareCount
+	}
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, shareHeaderSize+len(secret))
+		shares[i][0] = uint8(i + 1)
+		shares[i][1] = uint8(threshold)
+	}
+
+	// coeffs[0] is the secret byte, the rest are random
+	coeffs := make([]byte, threshold)
+	for j, b := range secret {
+		coeffs[0] = b
+		if _, err := io.ReadFull(rng, coeffs[1:]); err != nil {
+			return nil, err
+		}
+		for _, share := range shares {
+			share[shareHeaderSize+j] = gfEvaluate(coeffs, share[0])
+		}
+	}
+	return shares, nil
+}
+
+// combineShares reconstructs the secret from shares by Lagrange interpolation
+// at x = 0, using the first threshold of them.
+func combineShares(shares [][]byte) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, errTooFewShares
+	}
+	if len(shares[0]) < shareHeaderSize {
+		return nil, errShareMalformed
+	}
+	threshold := int(shares[0][1])
+	if threshold < 2 || threshold > 255 {
+		// no split produces this, so the share is not one
+		return nil, errShareMalformed
+	}
+	if len(shares) < threshold {
+		return nil, errTooFewShares
+	}
+	shares = shares[:threshold]
+
+	seen := make(map[uint8]bool, threshold)
+	for _, share := range shares {
+		if len(share) != len(shares[0]) || share[0] == 0 ||
+			int(share[1]) != threshold || seen[share[0]] {
+			return nil, errShareMalformed
+		}
+		seen[share[0]] = true
+	}
+
+	// the Lagrange basis of each share evaluated at 0, prod x_m/(x_m-x_j),
+	// where subtraction in GF(2^8) is XOR
+	basis := make([]byte, threshold)
+	for j, share := range shares {
+		basis[j] = 1
+		for m, other := range shares {
+			if m != j {
+				basis[j] = gfMul(basis[j],
+					gfDiv(other[0], other[0]^share[0]))
+			}
+		}
+	}
+
+	secret := make([]byte, len(shares[0])-shareHeaderSize)
+	for i := range secret {
+		var b byte
+		for j, share := range shares {
+			b ^= gfMul(basis[j], share[shareHeaderSize+i])
+		}
+		secret[i] = b
+	}
+	return secret, nil
+}
+
+// gfExp and gfLog are the exponent and logarithm tables of GF(2^8) with the
+// AES polynomial x^8+x^4+x^3+x+1 and generator 3. gfExp is doubled in length
+// so products of logarithms need no reduction.
+var gfExp, gfLog = gfTables()
+
+// gfTables builds the exponent and logarithm tables.
+func gfTables() (exp [510]byte, log [256]byte) {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		exp[i] = x
+		exp[i+255] = x
+		log[x] = byte(i)
+		// multiply by the generator, x*3 = x*2 ^ x
+		double := x << 1
+		if x&0x80 != 0 {
+			double ^= 0x1b
+		}
+		x ^= double
+	}
+	return exp, log
+}
+
+// gfMul returns a*b in GF(2^8).
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+// gfDiv returns a/b in GF(2^8). b must not be 0.
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+255-int(gfLog[b])]
+}
+
+// gfEvaluate returns the value of the polynomial with the given coefficients,
+// lowest order first, at x using Horner's method.
+func gfEvaluate(coeffs []byte, x byte) byte {
+	var y byte
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		y = gfMul(y, x) ^ coeffs[i]
+	}
+	return y
+}
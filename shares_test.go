@@ -0,0 +1,106 @@
+package ring
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestBloom_SplitShares ensures any threshold of shares reconstruct the ring
+// and fewer are rejected.
+func TestBloom_SplitShares(t *testing.T) {
+	r, err := Init(1000, 0.01)
+	require.NoError(t, err)
+	buff := make([]byte, 4)
+	for i := 0; i < 500; i++ {
+		intToByte(buff, i)
+		r.Add(buff)
+	}
+
+	shares, err := r.SplitShares(5, 3)
+	require.NoError(t, err)
+	require.Len(t, shares, 5)
+
+	subsets := [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}}
+	for _, subset := range subsets {
+		var picked [][]byte
+		for _, i := range subset {
+			picked = append(picked, shares[i])
+		}
+		c, err := CombineShares(picked)
+		require.NoError(t, err, "subset %v", subset)
+		require.True(t, r.Equal(c), "subset %v", subset)
+	}
+
+	_, err = CombineShares(shares[:2])
+	require.Error(t, err)
+	_, err = CombineShares(nil)
+	require.Error(t, err)
+	_, err = CombineShares([][]byte{shares[0], shares[0], shares[1]})
+	require.Error(t, err)
+	_, err = CombineShares([][]byte{shares[0], shares[1][:10], shares[2]})
+	require.Error(t, err)
+	for _, threshold := range []byte{0, 1} {
+		bad := append([]byte{}, shares[0]...)
+		bad[1] = threshold
+		_, err = CombineShares([][]byte{bad, shares[1], shares[2]})
+		require.Equal(t, errShareMalformed, err, "threshold %d", threshold)
+	}
+}
+
+// TestSplitShares_Hiding ensures a share does not contain the secret and that
+// fewer than threshold shares are consistent with any secret.
+func TestSplitShares_Hiding(t *testing.T) {
+	secret := bytes.Repeat([]byte{0xaa}, 64)
+	shares, err := splitShares(secret, 3, 3, bytes.NewReader(
+		bytes.Repeat([]byte{0x5c, 0x17}, 64)))
+	require.NoError(t, err)
+	for _, share := range shares {
+		require.NotEqual(t, secret, share[shareHeaderSize:])
+	}
+
+	// replacing the third share with one of any other split still combines,
+	// to a different secret
+	other, err := splitShares(make([]byte, 64), 3, 3, bytes.NewReader(
+		bytes.Repeat([]byte{0x01, 0x02}, 64)))
+	require.NoError(t, err)
+	mixed, err := combineShares([][]byte{shares[0], shares[1], other[2]})
+	require.NoError(t, err)
+	require.NotEqual(t, secret, mixed)
+}
+
+// TestSplitShares_BadCount ensures invalid share counts are rejected.
+func TestSplitShares_BadCount(t *testing.T) {
+	r, _ := Init(10, 0.01)
+	for _, c := range [][2]int{{3, 1}, {2, 3}, {256, 2}} {
+		_, err := r.SplitShares(c[0], c[1])
+		require.Error(t, err, "n %d threshold %d", c[0], c[1])
+	}
+}
+
+// TestGF ensures division inverts multiplication for every element.
+func TestGF(t *testing.T) {
+	for a := 0; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			require.Equal(t, byte(a), gfDiv(gfMul(byte(a), byte(b)), byte(b)))
+		}
+	}
+	require.Equal(t, byte(0xc1), gfMul(0x57, 0x83))
+}
+
+// TestCombineShares_Keyed ensures a keyed ring is reconstructed given its key.
+func TestCombineShares_Keyed(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	r, _ := Init(1000, 0.01, WithKey(key))
+	r.Add([]byte("a"))
+	shares, err := r.SplitShares(3, 2)
+	require.NoError(t, err)
+
+	_, err = CombineShares(shares)
+	require.Equal(t, errNoKey, err)
+	u, err := CombineShares(shares, WithKey(key))
+	require.NoError(t, err)
+	require.True(t, r.Equal(u))
+	require.True(t, u.Test([]byte("a")))
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+)
+
+var errSaturated = errors.New("error: every bit is set, so the count cannot be estimated")
+
+// Jaccard returns an estimate of the Jaccard similarity of the sets added to
+// the ring and other, the size of their intersection over the size of their
+// union. Each size is estimated from the set bits as ApproximateCount does:
+// the union from the bitwise OR of the rings, and the intersection by
+// inclusion-exclusion, as the sum of the sizes of the sets less the union.
+// The estimate is 1 if both rings are empty. It returns an error if the rings
+// have different parameters or their union has every bit set, or a
+// *StateError if either has been destroyed.
+func (r *Bloom) Jaccard(other *Bloom) (float64, error) {
+	a, b, union, err := r.estimatePair(other)
+	if err != nil {
+		return 0, err
+	}
+	if union == 0 {
+		return 1, nil
+	}
+	return clampIntersection(a, b, union) / union, nil
+}
+
+// EstimateUnion returns an estimate of the number of distinct elements added
+// to either the ring or other, from the set bits of their bitwise OR, as
+// ApproximateCount would give for the ring merging both. It returns an error
+// if the rings have different parameters or their union has every bit set,
+// or a *StateError if either has been destroyed.
+func (r *Bloom) EstimateUnion(other *Bloom) (uint64, error) {
+	_, _, union, err := r.estimatePair(other)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(math.Round(union)), nil
+}
+
+// EstimateIntersection returns an estimate of the number of distinct elements
+// added to both the ring and other, by inclusion-exclusion from the estimates
+// of each and of their union. Unlike the ring Intersect leaves, whose bits
+// can be set by different elements in each ring, it is not biased upwards,
+// but its error grows with the union, so small intersections of large sets
+// are estimated poorly. It returns the errors EstimateUnion does.
+func (r *Bloom) EstimateIntersection(other *Bloom) (uint64, error) {
+	a, b, union, err := r.estimatePair(other)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(math.Round(clampIntersection(a, b, union))), nil
+}
+
+// estimatePair returns the estimated number of elements in the ring, other,
+// and their union. Other is read under its lock for the whole estimate.
+func (r *Bloom) estimatePair(other *Bloom) (float64, float64, float64, error) {
+	if r == other {
+		r.mutex.RLock()
+		defer r.mutex.RUnlock()
+		if err := r.checkUsable("compare"); err != nil {
+			return 0, 0, 0, err
+		}
+		set := countBits(r.bits)
+		if set >= r.size {
+			return 0, 0, 0, errSaturated
+		}
+		n := estimateElements(r.size, r.hash, set)
+		return n, n, n, nil
+	}
+
+	unlock := lockPair(r, other, false)
+	defer unlock()
+	if err := r.checkUsable("compare"); err != nil {
+		return 0, 0, 0, err
+	}
+	if err := other.checkUsable("compare with"); err != nil {
+		return 0, 0, 0, err
+	}
+	if r.size != other.size || r.hash != other.hash {
+		return 0, 0, 0, errParameters
+	}
+	if r.hashing != other.hashing {
+		return 0, 0, 0, errHashing
+	}
+	if r.layout != other.layout {
+		return 0, 0, 0, errLayout
+	}
+
+	var setA, setB, setUnion uint64
+	for i := range r.bits {
+		setA += uint64(bits.OnesCount8(r.bits[i]))
+		setB += uint64(bits.OnesCount8(other.bits[i]))
+		setUnion += uint64(bits.OnesCount8(r.bits[i] | other.bits[i]))
+	}
+	if setUnion >= r.size {
+		return 0, 0, 0, errSaturated
+	}
+	return estimateElements(r.size, r.hash, setA),
+		estimateElements(r.size, r.hash, setB),
+		estimateElements(r.size, r.hash, setUnion), nil
+}
+
+// clampIntersection returns the size of the intersection of sets of a and b
+// elements with a union of union elements, by inclusion-exclusion, within
+// the bounds the sizes allow, as the estimates of each are noisy.
+func clampIntersection(a, b, union float64) float64 {
+	return math.Max(0, math.Min(a+b-union, math.Min(a, b)))
+}
@@ -0,0 +1,95 @@
+package ring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fillRange adds the elements from start up to end to the ring.
+func fillRange(r *Bloom, start, end int) {
+	buff := make([]byte, 4)
+	for i := start; i < end; i++ {
+		intToByte(buff, i)
+		r.Add(buff)
+	}
+}
+
+// TestBloom_Jaccard ensures the similarity of overlapping sets is estimated
+// closely, and identical and disjoint sets are told apart.
+func TestBloom_Jaccard(t *testing.T) {
+	a, _ := Init(10000, fpRate)
+	b, _ := Init(10000, fpRate)
+	fillRange(a, 0, 6000)
+	fillRange(b, 3000, 9000)
+	j, err := a.Jaccard(b)
+	require.NoError(t, err)
+	require.InDelta(t, 3000.0/9000, j, 0.02)
+
+	j, err = a.Jaccard(a)
+	require.NoError(t, err)
+	require.Equal(t, float64(1), j)
+
+	c, _ := Init(10000, fpRate)
+	fillRange(c, 20000, 26000)
+	j, err = a.Jaccard(c)
+	require.NoError(t, err)
+	require.True(t, j < 0.01, "disjoint %v", j)
+
+	empty, _ := Init(10000, fpRate)
+	other, _ := Init(10000, fpRate)
+	j, err = empty.Jaccard(other)
+	require.NoError(t, err)
+	require.Equal(t, float64(1), j)
+}
+
+// TestBloom_JaccardErrors ensures incompatible, saturated and destroyed rings
+// are rejected.
+func TestBloom_JaccardErrors(t *testing.T) {
+	a, _ := Init(100, fpRate)
+	b, _ := Init(200, fpRate)
+	_, err := a.Jaccard(b)
+	require.Equal(t, errParameters, err)
+	c, _ := Init(100, fpRate, WithSeed(1))
+	_, err = a.Jaccard(c)
+	require.Equal(t, errHashing, err)
+
+	full, _ := InitByParameters(8, 1)
+	fillRange(full, 0, 100)
+	_, err = full.Jaccard(full)
+	require.Equal(t, errSaturated, err)
+
+	d, _ := Init(100, fpRate)
+	require.NoError(t, d.Transition(StateDestroyed))
+	_, err = a.Jaccard(d)
+	require.IsType(t, &StateError{}, err)
+}
+
+// TestBloom_EstimateUnion ensures union and intersection sizes are estimated
+// within a few percent of the union.
+func TestBloom_EstimateUnion(t *testing.T) {
+	a, _ := Init(20000, fpRate)
+	b, _ := Init(20000, fpRate)
+	fillRange(a, 0, 8000)
+	fillRange(b, 5000, 12000)
+	union, err := a.EstimateUnion(b)
+	require.NoError(t, err)
+	require.InEpsilon(t, 12000, union, 0.02)
+	intersection, err := a.EstimateIntersection(b)
+	require.NoError(t, err)
+	require.InDelta(t, 3000, intersection, 0.02*12000)
+
+	// the union matches the count of the merged ring
+	require.NoError(t, a.Merge(b))
+	require.InDelta(t, a.ApproximateCount(), union, 1)
+
+	intersection, err = a.EstimateIntersection(a)
+	require.NoError(t, err)
+	require.Equal(t, a.ApproximateCount(), intersection)
+
+	c, _ := Init(100, fpRate)
+	_, err = a.EstimateUnion(c)
+	require.Equal(t, errParameters, err)
+	_, err = a.EstimateIntersection(c)
+	require.Equal(t, errParameters, err)
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"errors"
+	"math"
+)
+
+var (
+	errInsertRate = errors.New("error: InsertRate must not be negative")
+	errSteps      = errors.New("error: Steps must be greater than 0")
+	errRotate     = errors.New("error: RotateEvery must not be negative")
+	errFanIn      = errors.New("error: MergeFanIn must not be negative")
+)
+
+// SimulationPlan describes a workload to be modeled by Simulate.
+type SimulationPlan struct {
+	Elements      int     // capacity each filter is initialized with
+	FalsePositive float64 // target false positive rate each filter is initialized with
+	InsertRate    float64 // elements added to the current filter per step
+	Steps         int     // number of steps to model
+	RotateEvery   int     // steps between each Reset of the filter, 0 never resets
+	MergeFanIn    int     // filters a consumer merges together per step, 0 or 1 never merges
+}
+
+// SimulationStep contains the predicted state of the filters after a step.
+type SimulationStep struct {
+	Step                int     // index of the step, starting at 0
+	Elements            float64 // elements in the current filter
+	FillRatio           float64 // expected fraction of set bits in the current filter
+	FalsePositive       float64 // expected false positive rate of the current filter
+	MergedFillRatio     float64 // expected fraction of set bits after merging
+	MergedFalsePositive float64 // expected false positive rate after merging
+	BandwidthBytes      uint64  // bytes sent to the consumer for the merge
+}
+
+// SimulationResult contains the outcome of a simulation.
+type SimulationResult struct {
+	Size                    uint64           // number of bits in each filter
+	Hash                    uint64           // number of hash rounds in each filter
+	FilterBytes             uint64           // bytes of bit array held by each filter
+	MarshaledBytes          uint64           // bytes produced by MarshalBinary for each filter
+	MemoryBytes             uint64           // bytes of bit array held by the consumer
+	Steps                   []SimulationStep // predicted state after each step
+	PeakFalsePositive       float64          // highest false positive rate of a single filter
+	PeakMergedFalsePositive float64          // highest false positive rate after merging
+	TotalBandwidthBytes     uint64           // bytes sent to the consumer over all steps
+}
+
+// Simulate models the given workload using the same parameter math as Init,
+// predicting the memory, bandwidth, and false positive rates over time. The
+// merged filters are assumed to contain disjoint elements.
+func Simulate(plan SimulationPlan) (SimulationResult, error) {
+	if plan.Elements <= 0 {
+		return SimulationResult{}, errElements
+	}
+	if plan.FalsePositive <= 0 || plan.FalsePositive >= 1 {
+		return SimulationResult{}, errFalsePositive
+	}
+	if plan.InsertRate < 0 {
+		return SimulationResult{}, errInsertRate
+	}
+	if plan.Steps <= 0 {
+		return SimulationResult{}, errSteps
+	}
+	if plan.RotateEvery < 0 {
+		return SimulationResult{}, errRotate
+	}
+	if plan.MergeFanIn < 0 {
+		return SimulationResult{}, errFanIn
+	}
+
+	fanIn := plan.MergeFanIn
+	if fanIn == 0 {
+		fanIn = 1
+	}
+
+	res := SimulationResult{}
+	res.Size, res.Hash = optimalParameters(plan.Elements, plan.FalsePositive)
+	res.FilterBytes = getBuffSize(res.Size)
+	res.MarshaledBytes = res.FilterBytes + headerSize
+	res.MemoryBytes = res.FilterBytes * uint64(fanIn)
+	res.Steps = make([]SimulationStep, plan.Steps)
+
+	for i := 0; i < plan.Steps; i++ {
+		filled := i
+		if plan.RotateEvery > 0 {
+			filled = i % plan.RotateEvery
+		}
+
+		step := SimulationStep{Step: i}
+		step.Elements = plan.InsertRate * float64(filled+1)
+		step.FillRatio = expectedFillRatio(res.Size, res.Hash, step.Elements)
+		step.FalsePositive = math.Pow(step.FillRatio, float64(res.Hash))
+		step.MergedFillRatio = expectedFillRatio(res.Size, res.Hash,
+			step.Elements*float64(fanIn))
+		step.MergedFalsePositive = math.Pow(step.MergedFillRatio, float64(res.Hash))
+		if plan.MergeFanIn > 1 {
+			step.BandwidthBytes = res.MarshaledBytes * uint64(fanIn)
+		}
+
+		res.Steps[i] = step
+		res.TotalBandwidthBytes += step.BandwidthBytes
+		res.PeakFalsePositive = math.Max(res.PeakFalsePositive, step.FalsePositive)
+		res.PeakMergedFalsePositive = math.Max(res.PeakMergedFalsePositive,
+			step.MergedFalsePositive)
+	}
+
+	return res, nil
+}
+
+// expectedFillRatio returns the expected fraction of set bits in a filter with
+// the given parameters after the given number of elements have been added.
+func expectedFillRatio(size, hash uint64, elements float64) float64 {
+	return 1 - math.Exp(-float64(hash)*elements/float64(size))
+}
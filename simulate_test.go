@@ -0,0 +1,109 @@
+package ring
+
+import (
+	"math"
+	"math/bits"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestSimulate ensures that the simulated parameters match Init and that the
+// predicted false positive rate reaches the target at capacity.
+func TestSimulate(t *testing.T) {
+	plan := SimulationPlan{
+		Elements:      10000,
+		FalsePositive: 0.01,
+		InsertRate:    1000,
+		Steps:         10,
+	}
+	res, err := Simulate(plan)
+	require.NoError(t, err)
+
+	r, err := Init(plan.Elements, plan.FalsePositive)
+	require.NoError(t, err)
+	require.Equal(t, r.GetSize(), res.Size)
+	require.Equal(t, r.GetHashOpCount(), res.Hash)
+	require.Equal(t, uint64(r.BufferSize()), res.FilterBytes)
+	out, err := r.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, uint64(len(out)), res.MarshaledBytes)
+
+	require.Len(t, res.Steps, plan.Steps)
+	last := res.Steps[plan.Steps-1]
+	require.Equal(t, float64(plan.Elements), last.Elements)
+	require.InDelta(t, plan.FalsePositive, last.FalsePositive, plan.FalsePositive/10)
+	require.Equal(t, last.FalsePositive, res.PeakFalsePositive)
+	require.Zero(t, res.TotalBandwidthBytes)
+}
+
+// TestSimulate_FillRatio ensures the predicted fill ratio matches a real
+// filter.
+func TestSimulate_FillRatio(t *testing.T) {
+	plan := SimulationPlan{
+		Elements:      5000,
+		FalsePositive: 0.001,
+		InsertRate:    2500,
+		Steps:         2,
+	}
+	res, err := Simulate(plan)
+	require.NoError(t, err)
+
+	r, err := Init(plan.Elements, plan.FalsePositive)
+	require.NoError(t, err)
+	buff := make([]byte, 4)
+	for i := 0; i < plan.Elements; i++ {
+		intToByte(buff, i)
+		r.Add(buff)
+	}
+	set := 0
+	for _, b := range r.bits {
+		set += bits.OnesCount8(b)
+	}
+	actual := float64(set) / float64(r.size)
+	require.InDelta(t, res.Steps[1].FillRatio, actual, 0.01)
+}
+
+// TestSimulate_RotateAndMerge ensures rotation and merge fan-in are modeled.
+func TestSimulate_RotateAndMerge(t *testing.T) {
+	plan := SimulationPlan{
+		Elements:      1000,
+		FalsePositive: 0.01,
+		InsertRate:    250,
+		Steps:         8,
+		RotateEvery:   4,
+		MergeFanIn:    3,
+	}
+	res, err := Simulate(plan)
+	require.NoError(t, err)
+
+	require.Equal(t, res.FilterBytes*3, res.MemoryBytes)
+	require.Equal(t, res.Steps[0].Elements, res.Steps[4].Elements)
+	require.Equal(t, res.Steps[3].FalsePositive, res.PeakFalsePositive)
+	require.True(t, res.PeakMergedFalsePositive > res.PeakFalsePositive)
+	require.Equal(t, res.MarshaledBytes*3, res.Steps[0].BandwidthBytes)
+	require.Equal(t, res.MarshaledBytes*3*8, res.TotalBandwidthBytes)
+	require.False(t, math.IsNaN(res.Steps[7].MergedFalsePositive))
+}
+
+// TestSimulate_BadPlan ensures that invalid plans return an error.
+func TestSimulate_BadPlan(t *testing.T) {
+	valid := SimulationPlan{Elements: 10, FalsePositive: 0.1, Steps: 1}
+	_, err := Simulate(valid)
+	require.NoError(t, err)
+
+	plans := []func(p *SimulationPlan){
+		func(p *SimulationPlan) { p.Elements = 0 },
+		func(p *SimulationPlan) { p.FalsePositive = 1 },
+		func(p *SimulationPlan) { p.InsertRate = -1 },
+		func(p *SimulationPlan) { p.Steps = 0 },
+		func(p *SimulationPlan) { p.RotateEvery = -1 },
+		func(p *SimulationPlan) { p.MergeFanIn = -1 },
+	}
+	for i, modify := range plans {
+		p := valid
+		modify(&p)
+		_, err := Simulate(p)
+		require.Error(t, err, "plan %d", i)
+	}
+}
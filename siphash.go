@@ -0,0 +1,95 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import "math/bits"
+
+// sipState is the running state of a SipHash-2-4 hash.
+type sipState struct {
+	v0, v1, v2, v3 uint64
+}
+
+// newSipState returns the state of a SipHash-2-4 hash keyed with k0 and k1,
+// with the 128-bit output tweak if wide is true.
+func newSipState(k0, k1 uint64, wide bool) sipState {
+	s := sipState{
+		v0: k0 ^ 0x736f6d6570736575,
+		v1: k1 ^ 0x646f72616e646f6d,
+		v2: k0 ^ 0x6c7967656e657261,
+		v3: k1 ^ 0x7465646279746573,
+	}
+	if wide {
+		s.v1 ^= 0xee
+	}
+	return s
+}
+
+// round is a single SipRound.
+func (s *sipState) round() {
+	s.v0 += s.v1
+	s.v1 = bits.RotateLeft64(s.v1, 13)
+	s.v1 ^= s.v0
+	s.v0 = bits.RotateLeft64(s.v0, 32)
+	s.v2 += s.v3
+	s.v3 = bits.RotateLeft64(s.v3, 16)
+	s.v3 ^= s.v2
+	s.v0 += s.v3
+	s.v3 = bits.RotateLeft64(s.v3, 21)
+	s.v3 ^= s.v0
+	s.v2 += s.v1
+	s.v1 = bits.RotateLeft64(s.v1, 17)
+	s.v1 ^= s.v2
+	s.v2 = bits.RotateLeft64(s.v2, 32)
+}
+
+// compress mixes the message word m into the state.
+func (s *sipState) compress(m uint64) {
+	s.v3 ^= m
+	s.round()
+	s.round()
+	s.v0 ^= m
+}
+
+// finalize returns the next 64 bits of output, given the tweak of the
+// finalization it begins.
+func (s *sipState) finalize(tweak uint64) uint64 {
+	s.v2 ^= tweak
+	s.round()
+	s.round()
+	s.round()
+	s.round()
+	return s.v0 ^ s.v1 ^ s.v2 ^ s.v3
+}
+
+// absorb compresses the data, padded with its length as SipHash specifies.
+func (s *sipState) absorb(data []byte) {
+	blocks := len(data) / 8
+	for i := 0; i < blocks; i++ {
+		s.compress(bytesToUint64(data[i*8:]))
+	}
+	last := uint64(len(data)) << 56
+	for i, b := range data[blocks*8:] {
+		last |= uint64(b) << (8 * uint(i))
+	}
+	s.compress(last)
+}
+
+// sip64 returns the 64-bit SipHash-2-4 of the data under the key k0, k1.
+func sip64(k0, k1 uint64, data []byte) uint64 {
+	s := newSipState(k0, k1, false)
+	s.absorb(data)
+	return s.finalize(0xff)
+}
+
+// sip128 returns the two 64-bit halves of the 128-bit SipHash-2-4 of the data
+// under the key k0, k1.
+func sip128(k0, k1 uint64, data []byte) (uint64, uint64) {
+	s := newSipState(k0, k1, true)
+	s.absorb(data)
+	h1 := s.finalize(0xee)
+	s.v1 ^= 0xdd
+	h2 := s.finalize(0)
+	return h1, h2
+}
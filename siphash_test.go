@@ -0,0 +1,35 @@
+package ring
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestSipHash ensures the outputs match the vectors of the SipHash reference
+// implementation, for the key 00 01 .. 0f and messages 00 01 .. of each
+// length.
+func TestSipHash(t *testing.T) {
+	var key [16]byte
+	message := make([]byte, 64)
+	for i := range message {
+		if i < len(key) {
+			key[i] = byte(i)
+		}
+		message[i] = byte(i)
+	}
+	k0 := binary.LittleEndian.Uint64(key[:8])
+	k1 := binary.LittleEndian.Uint64(key[8:])
+
+	require.Equal(t, uint64(0x726fdb47dd0e0e31), sip64(k0, k1, message[:0]))
+	require.Equal(t, uint64(0xa129ca6149be45e5), sip64(k0, k1, message[:15]))
+	require.Equal(t, uint64(0x958a324ceb064572), sip64(k0, k1, message[:63]))
+
+	h1, h2 := sip128(k0, k1, message[:0])
+	out := make([]byte, 16)
+	binary.LittleEndian.PutUint64(out, h1)
+	binary.LittleEndian.PutUint64(out[8:], h2)
+	require.Equal(t, []byte{0xa3, 0x81, 0x7f, 0x04, 0xba, 0x25, 0xa8, 0xe6,
+		0x6d, 0xf6, 0x72, 0x14, 0xc7, 0x55, 0x02, 0x93}, out)
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// Report describes the differences between two marshaled filters.
+type Report struct {
+	VersionA, VersionB uint8  // format versions of the snapshots
+	SizeA, SizeB       uint64 // number of bits of each filter
+	HashA, HashB       uint64 // number of hash rounds of each filter
+
+	// ParametersChanged is true if the size, hash rounds, hash family and
+	// seed, or layout differ, in which case the bit delta is not computed.
+	ParametersChanged bool
+
+	BitsSetA, BitsSetB uint64 // number of set bits in each filter
+	BitsAdded          uint64 // bits set in b but not in a
+	BitsRemoved        uint64 // bits set in a but not in b
+
+	EstimatedA, EstimatedB float64 // estimated elements in each filter
+	EstimatedDelta         float64 // EstimatedB - EstimatedA
+}
+
+// CompareSnapshots decodes two outputs of MarshalBinary and reports how the
+// filter changed from a to b. It returns an error if either snapshot cannot
+// be decoded. The options are applied before decoding, so keyed snapshots can
+// be compared by passing WithKey.
+func CompareSnapshots(a, b []byte, opts ...Option) (Report, error) {
+	ra, err := unmarshalWith(a, opts)
+	if err != nil {
+		return Report{}, fmt.Errorf("snapshot a: %v", err)
+	}
+	rb, err := unmarshalWith(b, opts)
+	if err != nil {
+		return Report{}, fmt.Errorf("snapshot b: %v", err)
+	}
+
+	rep := Report{
+		VersionA: a[0],
+		VersionB: b[0],
+		SizeA:    ra.size,
+		SizeB:    rb.size,
+		HashA:    ra.hash,
+		HashB:    rb.hash,
+		BitsSetA: countBits(ra.bits),
+		BitsSetB: countBits(rb.bits),
+	}
+	rep.ParametersChanged = ra.size != rb.size || ra.hash != rb.hash ||
+		ra.hashing != rb.hashing || ra.layout != rb.layout
+	if !rep.ParametersChanged {
+		for i := range ra.bits {
+			rep.BitsAdded += uint64(bits.OnesCount8(rb.bits[i] &^ ra.bits[i]))
+			rep.BitsRemoved += uint64(bits.OnesCount8(ra.bits[i] &^ rb.bits[i]))
+		}
+	}
+	rep.EstimatedA = estimateElements(ra.size, ra.hash, rep.BitsSetA)
+	rep.EstimatedB = estimateElements(rb.size, rb.hash, rep.BitsSetB)
+	rep.EstimatedDelta = rep.EstimatedB - rep.EstimatedA
+	return rep, nil
+}
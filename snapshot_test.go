@@ -0,0 +1,82 @@
+package ring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestCompareSnapshots ensures the report reflects the changes between two
+// snapshots of the same filter.
+func TestCompareSnapshots(t *testing.T) {
+	r, err := Init(10000, 0.01)
+	require.NoError(t, err)
+	buff := make([]byte, 4)
+	for i := 0; i < 1000; i++ {
+		intToByte(buff, i)
+		r.Add(buff)
+	}
+	a, err := r.MarshalBinary()
+	require.NoError(t, err)
+	for i := 1000; i < 3000; i++ {
+		intToByte(buff, i)
+		r.Add(buff)
+	}
+	b, err := r.MarshalBinary()
+	require.NoError(t, err)
+
+	rep, err := CompareSnapshots(a, b)
+	require.NoError(t, err)
+	require.False(t, rep.ParametersChanged)
+	require.Equal(t, uint8(1), rep.VersionA)
+	require.Equal(t, r.GetSize(), rep.SizeB)
+	require.Equal(t, rep.BitsSetB-rep.BitsSetA, rep.BitsAdded)
+	require.Zero(t, rep.BitsRemoved)
+	require.InDelta(t, 1000, rep.EstimatedA, 50)
+	require.InDelta(t, 3000, rep.EstimatedB, 150)
+	require.InDelta(t, 2000, rep.EstimatedDelta, 150)
+
+	// reversed snapshots remove bits
+	rep, err = CompareSnapshots(b, a)
+	require.NoError(t, err)
+	require.Zero(t, rep.BitsAdded)
+	require.Equal(t, rep.BitsSetA-rep.BitsSetB, rep.BitsRemoved)
+}
+
+// TestCompareSnapshots_Parameters ensures parameter changes are reported and
+// bad snapshots return an error.
+func TestCompareSnapshots_Parameters(t *testing.T) {
+	r1, _ := Init(100, 0.01)
+	r2, _ := Init(200, 0.01)
+	a, _ := r1.MarshalBinary()
+	b, _ := r2.MarshalBinary()
+
+	rep, err := CompareSnapshots(a, b)
+	require.NoError(t, err)
+	require.True(t, rep.ParametersChanged)
+	require.Zero(t, rep.BitsAdded)
+	require.Zero(t, rep.EstimatedDelta)
+
+	_, err = CompareSnapshots(nil, b)
+	require.Error(t, err)
+	_, err = CompareSnapshots(a, nil)
+	require.Error(t, err)
+}
+
+// TestCompareSnapshots_Keyed ensures keyed snapshots are compared given their
+// key.
+func TestCompareSnapshots_Keyed(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	r, _ := Init(1000, 0.01, WithKey(key))
+	a, _ := r.MarshalBinary()
+	r.Add([]byte("a"))
+	b, _ := r.MarshalBinary()
+
+	_, err := CompareSnapshots(a, b)
+	require.Error(t, err)
+	rep, err := CompareSnapshots(a, b, WithKey(key))
+	require.NoError(t, err)
+	require.False(t, rep.ParametersChanged)
+	require.Equal(t, rep.BitsSetB, rep.BitsAdded)
+	require.NotZero(t, rep.BitsAdded)
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"fmt"
+	"math"
+)
+
+// Stats describes a ring for logs and dashboards.
+type Stats struct {
+	Size           uint64  // number of bits, m
+	Hash           uint64  // number of hash rounds, k
+	Bytes          int     // bytes of bit array
+	FillRatio      float64 // fraction of bits set
+	EstimatedCount uint64  // as ApproximateCount
+	State          State   // lifecycle state
+
+	// Latency histograms of the ring's operations, nil unless the ring was
+	// created with WithLatency. They are copies, unaffected by later
+	// operations.
+	AddLatency   *LatencyHistogram
+	TestLatency  *LatencyHistogram
+	MergeLatency *LatencyHistogram
+}
+
+// Stats returns a description of the ring. Unlike the accessors it combines,
+// it does not panic on a destroyed ring, which is described as empty.
+func (r *Bloom) Stats() Stats {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	s := Stats{
+		Size:  r.size,
+		Hash:  r.hash,
+		Bytes: len(r.bits),
+		State: r.state,
+	}
+	if r.latency != nil {
+		s.AddLatency = r.latency.add.snapshot()
+		s.TestLatency = r.latency.test.snapshot()
+		s.MergeLatency = r.latency.merge.snapshot()
+	}
+	if r.state == StateDestroyed {
+		return s
+	}
+	set := countBits(r.bits)
+	s.FillRatio = float64(set) / float64(r.size)
+	if set >= r.size {
+		s.EstimatedCount = math.MaxUint64
+	} else {
+		s.EstimatedCount = uint64(math.Round(estimateElements(r.size, r.hash, set)))
+	}
+	return s
+}
+
+// String implements fmt.Stringer, summarizing Stats.
+func (r *Bloom) String() string {
+	s := r.Stats()
+	return fmt.Sprintf("ring(m=%d k=%d bytes=%d fill=%.4f n~%d %s)",
+		s.Size, s.Hash, s.Bytes, s.FillRatio, s.EstimatedCount, s.State)
+}
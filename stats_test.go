@@ -0,0 +1,35 @@
+package ring
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestBloom_Stats ensures Stats agrees with the individual accessors and
+// String summarizes it.
+func TestBloom_Stats(t *testing.T) {
+	r, _ := Init(1000, 0.01)
+	buff := make([]byte, 4)
+	for i := 0; i < 500; i++ {
+		intToByte(buff, i)
+		r.Add(buff)
+	}
+
+	s := r.Stats()
+	require.Equal(t, Stats{
+		Size:           r.GetM(),
+		Hash:           r.GetK(),
+		Bytes:          r.BufferSize(),
+		FillRatio:      r.FillRatio(),
+		EstimatedCount: r.ApproximateCount(),
+		State:          StateBuilding,
+	}, s)
+	require.Equal(t, fmt.Sprintf("ring(m=%d k=%d bytes=%d fill=%.4f n~%d building)",
+		s.Size, s.Hash, s.Bytes, s.FillRatio, s.EstimatedCount), r.String())
+
+	require.NoError(t, r.Transition(StateDestroyed))
+	require.NotPanics(t, func() { _ = r.String() })
+	require.Equal(t, StateDestroyed, r.Stats().State)
+}
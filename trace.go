@@ -0,0 +1,203 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// traceEntrySize is the size of a marshaled TraceEntry: 1 byte of op, 1 byte
+// of result, 32 bytes of element hash, and 8 bytes of digest.
+const traceEntrySize = 42
+
+// Trace is a sequence of operations on a ring, captured by a Recorder, that
+// Replay reproduces bit for bit. It holds the hashes of the elements rather
+// than the elements themselves, so it can be shared to debug two nodes that
+// claim to have applied the same inserts. Only for a ring created WithKey
+// does this keep the data from whoever holds the trace: unkeyed hashes of
+// guessable elements, such as short IDs, are easily brute-forced.
+type Trace struct {
+	Initial []byte // ring as marshaled when recording started
+	Entries []TraceEntry
+}
+
+// TraceEntry is an operation in a Trace.
+type TraceEntry struct {
+	Op      Op
+	Element [4]uint64 // hashes of the element, in the ring's hash family
+	Result  bool      // outcome of the op, as in OpRecord
+	Digest  uint64    // digest of the ring after the op
+}
+
+// DivergenceError is returned by Replay when an operation does not reproduce
+// the recorded result or digest.
+type DivergenceError struct {
+	Index  int    // index of the entry in Trace.Entries
+	Result bool   // result of the op on replay
+	Digest uint64 // digest of the ring after the op on replay
+}
+
+// Error implements the error interface.
+func (e *DivergenceError) Error() string {
+	return fmt.Sprintf("replay diverged at entry %d: result %t, digest %#x",
+		e.Index, e.Result, e.Digest)
+}
+
+// Recorder applies operations to a ring while recording them into a Trace.
+// Operations on the ring made other than through the Recorder are not
+// recorded, and make the trace unreplayable.
+type Recorder struct {
+	r       *Bloom
+	initial []byte
+	entries []TraceEntry // guarded by the lock of r
+}
+
+// Record starts recording operations on the ring, capturing its current
+// contents as the start of the trace.
+func Record(r *Bloom) (*Recorder, error) {
+	initial, err := r.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{r: r, initial: initial}, nil
+}
+
+// Add adds the data to the ring, as Bloom.Add would, and records it.
+func (rec *Recorder) Add(data []byte) {
+	rec.apply(OpAdd, rec.r.hashData(data))
+}
+
+// Test returns a bool if the data is in the ring, as Bloom.Test would, and
+// records it.
+func (rec *Recorder) Test(data []byte) bool {
+	return rec.apply(OpTest, rec.r.hashData(data))
+}
+
+// TestAndAdd adds the data to the ring and returns true if it may have been in
+// the ring beforehand, as Bloom.TestAndAdd would, and records it.
+func (rec *Recorder) TestAndAdd(data []byte) bool {
+	return rec.apply(OpTestAndAdd, rec.r.hashData(data))
+}
+
+// Trace returns the operations recorded so far.
+func (rec *Recorder) Trace() *Trace {
+	rec.r.mutex.RLock()
+	defer rec.r.mutex.RUnlock()
+	return &Trace{
+		Initial: append([]byte{}, rec.initial...),
+		Entries: append([]TraceEntry{}, rec.entries...),
+	}
+}
+
+// apply applies the op to the ring and records it.
+func (rec *Recorder) apply(op Op, hash [4]uint64) bool {
+	r := rec.r
+	r.mutex.Lock()
+	defer r.writeUnlock()
+	result := r.applyOp(op, hash)
+	rec.entries = append(rec.entries, TraceEntry{
+		Op:      op,
+		Element: hash,
+		Result:  result,
+		Digest:  r.digest,
+	})
+	return result
+}
+
+// Replay returns a ring built by applying the operations of the trace to its
+// initial ring. It returns a *DivergenceError, along with the ring as it was
+// after the diverging op, if an op does not give the recorded result or leave
+// the recorded digest. The options are applied before decoding the initial
+// ring, so a keyed trace can be replayed by passing WithKey.
+func Replay(t *Trace, opts ...Option) (*Bloom, error) {
+	r, err := unmarshalWith(t.Initial, opts)
+	if err != nil {
+		return nil, err
+	}
+	r.mutex.Lock()
+	defer r.writeUnlock()
+	for i, e := range t.Entries {
+		if e.Op > OpTestAndAdd {
+			return nil, fmt.Errorf("unknown op: %s", e.Op)
+		}
+		result := r.applyOp(e.Op, e.Element)
+		if result != e.Result || r.digest != e.Digest {
+			return r, &DivergenceError{Index: i, Result: result, Digest: r.digest}
+		}
+	}
+	return r, nil
+}
+
+// applyOp applies the op for the pre-generated hashes and returns its result.
+// The caller must hold the write lock.
+func (r *Bloom) applyOp(op Op, hash [4]uint64) bool {
+	switch op {
+	case OpAdd:
+		r.mustBeMutable("add to")
+		before := r.unrecorded
+		r.add(hash)
+		return r.unrecorded != before
+	case OpTest:
+		r.mustBeUsable("test")
+		return r.test(hash)
+	case OpTestAndAdd:
+		r.mustBeMutable("add to")
+		return r.testAndAdd(hash)
+	default:
+		panic(fmt.Sprintf("unknown op: %s", op))
+	}
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface. The output
+// is the length of the initial ring as 4 bytes, the initial ring, then each
+// entry.
+func (t *Trace) MarshalBinary() ([]byte, error) {
+	out := make([]byte, 4+len(t.Initial)+traceEntrySize*len(t.Entries))
+	binary.BigEndian.PutUint32(out, uint32(len(t.Initial)))
+	i := 4 + copy(out[4:], t.Initial)
+	for _, e := range t.Entries {
+		out[i] = uint8(e.Op)
+		if e.Result {
+			out[i+1] = 1
+		}
+		for j, h := range e.Element {
+			binary.BigEndian.PutUint64(out[i+2+8*j:], h)
+		}
+		binary.BigEndian.PutUint64(out[i+34:], e.Digest)
+		i += traceEntrySize
+	}
+	return out, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (t *Trace) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return fmt.Errorf("incorrect length: %d", len(data))
+	}
+	initial := uint64(binary.BigEndian.Uint32(data))
+	if uint64(len(data)-4) < initial ||
+		(uint64(len(data)-4)-initial)%traceEntrySize != 0 {
+		return fmt.Errorf("incorrect length: %d", len(data))
+	}
+	t.Initial = append([]byte{}, data[4:4+initial]...)
+	data = data[4+initial:]
+	t.Entries = make([]TraceEntry, len(data)/traceEntrySize)
+	for n := range t.Entries {
+		e := data[n*traceEntrySize:]
+		if e[1] > 1 {
+			return fmt.Errorf("unexpected result: %d", e[1])
+		}
+		t.Entries[n] = TraceEntry{
+			Op:     Op(e[0]),
+			Result: e[1] == 1,
+			Digest: binary.BigEndian.Uint64(e[34:]),
+		}
+		for j := range t.Entries[n].Element {
+			t.Entries[n].Element[j] = binary.BigEndian.Uint64(e[2+8*j:])
+		}
+	}
+	return nil
+}
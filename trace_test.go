@@ -0,0 +1,82 @@
+package ring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestReplay ensures a marshaled trace replays to the recorded ring bit for
+// bit, starting from a non-empty, seeded ring.
+func TestReplay(t *testing.T) {
+	r, _ := Init(1000, 0.01, WithSeed(7))
+	r.Add([]byte("before"))
+	rec, err := Record(r)
+	require.NoError(t, err)
+
+	buff := make([]byte, 4)
+	for i := 0; i < 200; i++ {
+		intToByte(buff, i)
+		switch i % 3 {
+		case 0:
+			rec.Add(buff)
+		case 1:
+			require.Equal(t, r.Test(buff), rec.Test(buff))
+		default:
+			rec.TestAndAdd(buff)
+		}
+	}
+	require.True(t, rec.TestAndAdd([]byte("before")))
+
+	out, err := rec.Trace().MarshalBinary()
+	require.NoError(t, err)
+	var trace Trace
+	require.NoError(t, trace.UnmarshalBinary(out))
+	require.Len(t, trace.Entries, 201)
+
+	replayed, err := Replay(&trace)
+	require.NoError(t, err)
+	require.True(t, r.Equal(replayed))
+	require.Equal(t, r.Digest(), replayed.Digest())
+}
+
+// TestReplay_Divergence ensures Replay reports the first entry that does not
+// reproduce, and that bad traces are rejected.
+func TestReplay_Divergence(t *testing.T) {
+	r, _ := Init(1000, 0.01)
+	rec, _ := Record(r)
+	rec.Add([]byte("a"))
+	rec.Add([]byte("b"))
+	rec.Add([]byte("c"))
+
+	trace := rec.Trace()
+	trace.Entries[1].Digest++
+	_, err := Replay(trace)
+	require.Equal(t, &DivergenceError{Index: 1, Result: true,
+		Digest: trace.Entries[1].Digest - 1}, err)
+
+	trace.Entries[1].Op = 9
+	_, err = Replay(trace)
+	require.Error(t, err)
+
+	out, _ := rec.Trace().MarshalBinary()
+	var bad Trace
+	require.Error(t, bad.UnmarshalBinary(out[:len(out)-1]))
+	require.Error(t, bad.UnmarshalBinary(out[:3]))
+}
+
+// TestReplay_Keyed ensures a trace of a keyed ring is replayed given its key.
+func TestReplay_Keyed(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	r, _ := Init(1000, 0.01, WithKey(key))
+	rec, err := Record(r)
+	require.NoError(t, err)
+	rec.Add([]byte("a"))
+	require.True(t, rec.TestAndAdd([]byte("a")))
+
+	_, err = Replay(rec.Trace())
+	require.Equal(t, errNoKey, err)
+	replayed, err := Replay(rec.Trace(), WithKey(key))
+	require.NoError(t, err)
+	require.True(t, r.Equal(replayed))
+}
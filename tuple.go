@@ -0,0 +1,54 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// AddTuple adds a key made of several parts to the ring. Each part is
+// prefixed with its length before hashing, so different splits of the same
+// bytes, such as ("ab", "c") and ("a", "bc"), are different keys, unlike
+// joining the parts with a separator that may itself appear in a part.
+func (r *Bloom) AddTuple(parts ...[]byte) {
+	if r.latency != nil {
+		defer r.latency.add.observe(time.Now())
+	}
+	hash := r.hashData(tupleBytes(parts))
+	r.mutex.Lock()
+	defer r.writeUnlock()
+	r.mustBeMutable("add to")
+	r.add(hash)
+}
+
+// TestTuple returns a bool if the key made of the parts is in the ring, as
+// added by AddTuple.
+func (r *Bloom) TestTuple(parts ...[]byte) bool {
+	if r.latency != nil {
+		defer r.latency.test.observe(time.Now())
+	}
+	hash := r.hashData(tupleBytes(parts))
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	r.mustBeUsable("test")
+	return r.test(hash)
+}
+
+// tupleBytes returns the framing of the parts: each part preceded by its
+// length as a uvarint.
+func tupleBytes(parts [][]byte) []byte {
+	length := 0
+	for _, part := range parts {
+		length += binary.MaxVarintLen64 + len(part)
+	}
+	out := make([]byte, 0, length)
+	var prefix [binary.MaxVarintLen64]byte
+	for _, part := range parts {
+		out = append(out, prefix[:binary.PutUvarint(prefix[:], uint64(len(part)))]...)
+		out = append(out, part...)
+	}
+	return out
+}
@@ -0,0 +1,23 @@
+package ring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestBloom_AddTuple ensures tuples are found and different splits of the
+// same bytes are different keys.
+func TestBloom_AddTuple(t *testing.T) {
+	r, _ := Init(1000, 0.001)
+	r.AddTuple([]byte("ab"), []byte("c"))
+	require.True(t, r.TestTuple([]byte("ab"), []byte("c")))
+	require.False(t, r.TestTuple([]byte("a"), []byte("bc")))
+	require.False(t, r.TestTuple([]byte("abc")))
+	require.False(t, r.TestTuple([]byte("ab"), []byte("c"), nil))
+	require.False(t, r.Test([]byte("abc")))
+
+	require.NotEqual(t, tupleBytes([][]byte{nil}), tupleBytes(nil))
+	require.Equal(t, []byte{2, 'a', 'b', 1, 'c'},
+		tupleBytes([][]byte{[]byte("ab"), []byte("c")}))
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import "fmt"
+
+// maxValidHash is the largest number of hash rounds Validate accepts. Init
+// needs fewer than 70 for any false positive rate above 1e-20, and testing an
+// element costs a memory access per round, so a larger count is a sign of a
+// corrupt or hostile filter.
+const maxValidHash = 128
+
+// Validate checks the internal invariants of the ring, as a gate before using
+// a ring unmarshaled from an untrusted source: that the size and hash rounds
+// are sane, the bit array has the length the size requires with no bits set
+// past the size, the hash family is known, and the digest matches the bits.
+// It returns an error describing the first violation, or a *StateError if the
+// ring has been destroyed.
+func (r *Bloom) Validate() error {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if err := r.checkUsable("validate"); err != nil {
+		return err
+	}
+	if r.size == 0 {
+		return fmt.Errorf("error: invalid ring: size is 0")
+	}
+	if r.hash == 0 || r.hash > maxValidHash {
+		return fmt.Errorf("error: invalid ring: %d hash rounds is not in [1, %d]",
+			r.hash, maxValidHash)
+	}
+	if uint64(len(r.bits)) != getBuffSize(r.size) {
+		return fmt.Errorf("error: invalid ring: %d bytes of bits for size %d",
+			len(r.bits), r.size)
+	}
+	if rem := r.size % 8; rem != 0 && r.bits[len(r.bits)-1]>>rem != 0 {
+		return fmt.Errorf("error: invalid ring: bits are set past size %d",
+			r.size)
+	}
+	if !r.hashing.family.valid() {
+		return fmt.Errorf("error: invalid ring: unknown hash family %d",
+			r.hashing.family)
+	}
+	if !r.layout.valid() || !r.layout.fits(r.size, r.hash) {
+		return fmt.Errorf("error: invalid ring: size %d does not fit layout %s",
+			r.size, r.layout)
+	}
+	if r.digest != computeDigest(r.bits) {
+		return fmt.Errorf("error: invalid ring: digest does not match bits")
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package ring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestBloom_Validate ensures valid rings pass and each kind of corruption is
+// reported.
+func TestBloom_Validate(t *testing.T) {
+	r, _ := Init(1000, 0.01)
+	r.Add([]byte("data"))
+	require.NoError(t, r.Validate())
+
+	out, _ := r.MarshalBinary()
+	u := new(Bloom)
+	require.NoError(t, u.UnmarshalBinary(out))
+	require.NoError(t, u.Validate())
+
+	corruptions := []func(b *Bloom){
+		func(b *Bloom) { b.size = 0 },
+		func(b *Bloom) { b.hash = 0 },
+		func(b *Bloom) { b.hash = maxValidHash + 1 },
+		func(b *Bloom) { b.bits = b.bits[:len(b.bits)-1] },
+		func(b *Bloom) { b.bits[len(b.bits)-1] |= 0x80 },
+		func(b *Bloom) { b.hashing.family = HashFamily(9) },
+		func(b *Bloom) { b.bits[0] ^= 1 },
+	}
+	for i, corrupt := range corruptions {
+		c := r.Clone()
+		corrupt(c)
+		require.Error(t, c.Validate(), "corruption %d", i)
+	}
+
+	// sizes that fill the last byte have no padding bits to check
+	full, _ := InitByParameters(64, 3)
+	full.SetBits([]uint64{63})
+	require.NoError(t, full.Validate())
+
+	require.NoError(t, r.Transition(StateDestroyed))
+	require.IsType(t, &StateError{}, r.Validate())
+}
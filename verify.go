@@ -0,0 +1,48 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errSampleSize = errors.New("error: sample size must be greater than 0")
+
+// VerificationError is the error for a ring that reports keys of its source
+// of truth as absent, which a correctly restored ring never does.
+type VerificationError struct {
+	Sampled int // number of keys checked
+	Missing int // number of keys reported absent
+}
+
+// Error implements the error interface.
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("error: %d of %d sampled keys are missing from the ring",
+		e.Missing, e.Sampled)
+}
+
+// VerifyAgainst checks that the ring holds a sample of the keys it should,
+// such as after restoring it and before serving membership answers. The source
+// is called with n and must return up to n keys known to have been added, for
+// example a random sample of the authoritative store. It returns a
+// *VerificationError if any key is reported absent, or an error if n is not
+// greater than 0.
+func (r *Bloom) VerifyAgainst(source func(n int) [][]byte, n int) error {
+	if n <= 0 {
+		return errSampleSize
+	}
+	keys := source(n)
+	missing := 0
+	for _, present := range r.TestMany(keys) {
+		if !present {
+			missing++
+		}
+	}
+	if missing > 0 {
+		return &VerificationError{Sampled: len(keys), Missing: missing}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package ring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestBloom_VerifyAgainst ensures a restored ring passes and one missing keys
+// is reported.
+func TestBloom_VerifyAgainst(t *testing.T) {
+	r, _ := Init(1000, 0.001)
+	keys := make([][]byte, 500)
+	for i := range keys {
+		keys[i] = make([]byte, 4)
+		intToByte(keys[i], i)
+		r.Add(keys[i])
+	}
+	source := func(n int) [][]byte {
+		if n > len(keys) {
+			n = len(keys)
+		}
+		return keys[:n]
+	}
+
+	out, _ := r.MarshalBinary()
+	restored := new(Bloom)
+	require.NoError(t, restored.UnmarshalBinary(out))
+	require.NoError(t, restored.VerifyAgainst(source, 1000))
+
+	empty, _ := Init(1000, 0.001)
+	err := empty.VerifyAgainst(source, 100)
+	require.Equal(t, &VerificationError{Sampled: 100, Missing: 100}, err)
+	require.EqualError(t, err,
+		"error: 100 of 100 sampled keys are missing from the ring")
+
+	require.Equal(t, errSampleSize, r.VerifyAgainst(source, 0))
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ring
+
+import "math/bits"
+
+// wyp is the default secret of wyhash.
+var wyp = [4]uint64{
+	0xa0761d6478bd642f, 0xe7037ed1a0b428db,
+	0x8ebc6af09c88c6e3, 0x589965cc75374cc3,
+}
+
+// wymix returns the xor of the halves of the 128-bit product of a and b.
+func wymix(a, b uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	return hi ^ lo
+}
+
+// wyr4 performs little endian conversion from 4 bytes to an unsigned 64-bit
+// int.
+func wyr4(b []byte) uint64 {
+	_ = b[3] // memory safety
+	return uint64(b[0]) | uint64(b[1])<<8 | uint64(b[2])<<16 | uint64(b[3])<<24
+}
+
+// wyhash returns the 64-bit wyhash (final version 3) of the data with the
+// default secret.
+func wyhash(data []byte, seed uint64) uint64 {
+	n := len(data)
+	seed ^= wyp[0]
+	var a, b uint64
+	switch {
+	case n >= 4 && n <= 16:
+		q := (n >> 3) << 2
+		a = wyr4(data)<<32 | wyr4(data[q:])
+		b = wyr4(data[n-4:])<<32 | wyr4(data[n-4-q:])
+	case n > 0 && n < 4:
+		a = uint64(data[0])<<16 | uint64(data[n>>1])<<8 | uint64(data[n-1])
+	case n > 16:
+		p := data
+		if len(p) > 48 {
+			see1, see2 := seed, seed
+			for len(p) > 48 {
+				seed = wymix(bytesToUint64(p)^wyp[1], bytesToUint64(p[8:])^seed)
+				see1 = wymix(bytesToUint64(p[16:])^wyp[2], bytesToUint64(p[24:])^see1)
+				see2 = wymix(bytesToUint64(p[32:])^wyp[3], bytesToUint64(p[40:])^see2)
+				p = p[48:]
+			}
+			seed ^= see1 ^ see2
+		}
+		for len(p) > 16 {
+			seed = wymix(bytesToUint64(p)^wyp[1], bytesToUint64(p[8:])^seed)
+			p = p[16:]
+		}
+		// the last 16 bytes, which may overlap those already mixed
+		a = bytesToUint64(data[n-16:])
+		b = bytesToUint64(data[n-8:])
+	}
+	return wymix(wyp[1]^uint64(n), wymix(a^wyp[1], b^seed))
+}
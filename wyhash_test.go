@@ -0,0 +1,30 @@
+package ring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestWyhash ensures the outputs match the test vectors of the wyhash
+// reference implementation, where each is hashed with its index as the seed.
+func TestWyhash(t *testing.T) {
+	vectors := []struct {
+		data string
+		hash uint64
+	}{
+		{"", 0x42bc986dc5eec4d3},
+		{"a", 0x84508dc903c31551},
+		{"abc", 0x0bc54887cfc9ecb1},
+		{"message digest", 0x6e2ff3298208a67c},
+		{"abcdefghijklmnopqrstuvwxyz", 0x9a64e42e897195b9},
+		{"ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789",
+			0x9199383239c32554},
+		{"12345678901234567890123456789012345678901234567890123456789012345678901234567890",
+			0x7c1ccf6bba30f5a5},
+	}
+	for i, v := range vectors {
+		require.Equal(t, v.hash, wyhash([]byte(v.data), uint64(i)),
+			"vector %d", i)
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright (c) 2019 Tanner Ryan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package xorfilter provides xor filters, which are built once from a final
+// set of keys and then only queried. They use less space than a bloom filter
+// with the same false positive rate, and suit immutable sets such as the
+// elements of a closed epoch.
+package xorfilter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var errBuild = errors.New("error: could not build the filter")
+
+const (
+	// maxAttempts is the number of seeds tried before giving up on a build.
+	// Each attempt succeeds with high probability once duplicates are
+	// removed, so reaching it means something is wrong.
+	maxAttempts = 100
+	// headerSize is the number of bytes preceding the fingerprints in the
+	// output of MarshalBinary: 1 byte of version, 1 byte of fingerprint
+	// width, 8 bytes of seed, and 4 bytes of block length.
+	headerSize = 14
+)
+
+// XorFilter is an xor filter with 8 or 16 bit fingerprints, giving a false
+// positive rate of about 1/256 or 1/65536 in about 9.84 or 19.7 bits per key.
+// A key maps to one slot in each of three blocks, and is in the filter if the
+// XOR of their fingerprints is the fingerprint of the key. It is immutable,
+// and so safe for any number of concurrent readers.
+type XorFilter struct {
+	seed         uint64
+	blockLength  uint32
+	width        uint8   // bits per fingerprint, 8 or 16
+	fingerprints []uint8 // 3 * blockLength fingerprints, big endian
+}
+
+// BuildXorFilter returns an xor filter with 8-bit fingerprints holding the
+// keys, or an error if it cannot be built. Duplicate keys are ignored. The
+// keys should be well distributed, for example hashes of the data.
+func BuildXorFilter(keys []uint64) (*XorFilter, error) {
+	return build(keys, 8)
+}
+
+// BuildXorFilter16 returns an xor filter as BuildXorFilter does, with 16-bit
+// fingerprints.
+func BuildXorFilter16(keys []uint64) (*XorFilter, error) {
+	return build(keys, 16)
+}
+
+// Test returns a bool if the key is in the filter. True indicates that the key
+// may be in the filter, while false indicates that it is not.
+func (f *XorFilter) Test(key uint64) bool {
+	h := mix(key + f.seed)
+	fp := f.fingerprint(h)
+	h0, h1, h2 := f.slots(h)
+	return fp == f.get(h0)^f.get(h1)^f.get(h2)
+}
+
+// SizeInBytes returns the size of the fingerprints of the filter.
+func (f *XorFilter) SizeInBytes() int {
+	return len(f.fingerprints)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface. The output
+// is 1 byte of version, 1 byte of fingerprint width in bits, the seed as 8
+// bytes, the block length as 4 bytes, then the fingerprints. Integers are big
+// endian.
+func (f *XorFilter) MarshalBinary() ([]byte, error) {
+	out := make([]byte, headerSize+len(f.fingerprints))
+	out[0] = 1
+	out[1] = f.width
+	binary.BigEndian.PutUint64(out[2:10], f.seed)
+	binary.BigEndian.PutUint32(out[10:14], f.blockLength)
+	copy(out[headerSize:], f.fingerprints)
+	return out, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (f *XorFilter) UnmarshalBinary(data []byte) error {
+	if len(data) < headerSize {
+		return fmt.Errorf("incorrect length: %d", len(data))
+	}
+	if data[0] != 1 {
+		return fmt.Errorf("unexpected version: %d", data[0])
+	}
+	width := data[1]
+	if width != 8 && width != 16 {
+		return fmt.Errorf("invalid fingerprint width: %d", width)
+	}
+	blockLength := binary.BigEndian.Uint32(data[10:14])
+	if blockLength == 0 ||
+		uint64(len(data)-headerSize) != 3*uint64(blockLength)*uint64(width/8) {
+		return fmt.Errorf("incorrect length: %d", len(data))
+	}
+	f.width = width
+	f.seed = binary.BigEndian.Uint64(data[2:10])
+	f.blockLength = blockLength
+	f.fingerprints = append([]uint8{}, data[headerSize:]...)
+	return nil
+}
+
+// build returns a filter with fingerprints of width bits holding the keys.
+func build(keys []uint64, width uint8) (*XorFilter, error) {
+	keys = unique(keys)
+	capacity := 32 + uint32(1.23*float64(len(keys)))
+	f := &XorFilter{
+		blockLength: capacity / 3,
+		width:       width,
+	}
+	capacity = 3 * f.blockLength
+	f.fingerprints = make([]uint8, capacity*uint32(width/8))
+
+	type set struct {
+		xorMask uint64
+		count   uint32
+	}
+	type keyIndex struct {
+		hash  uint64
+		index uint32
+	}
+	sets := make([]set, capacity)
+	queue := make([]uint32, 0, capacity)
+	stack := make([]keyIndex, 0, len(keys))
+
+	seed := uint64(0x726b2b9d438b9d4d)
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		seed = splitmix(seed)
+		f.seed = seed
+		for i := range sets {
+			sets[i] = set{}
+		}
+		for _, key := range keys {
+			h := mix(key + f.seed)
+			h0, h1, h2 := f.slots(h)
+			for _, i := range [3]uint32{h0, h1, h2} {
+				sets[i].xorMask ^= h
+				sets[i].count++
+			}
+		}
+
+		// peel slots holding a single key until none are left
+		queue = queue[:0]
+		stack = stack[:0]
+		for i := range sets {
+			if sets[i].count == 1 {
+				queue = append(queue, uint32(i))
+			}
+		}
+		for len(queue) > 0 {
+			index := queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+			if sets[index].count != 1 {
+				continue
+			}
+			h := sets[index].xorMask
+			stack = append(stack, keyIndex{hash: h, index: index})
+			h0, h1, h2 := f.slots(h)
+			for _, i := range [3]uint32{h0, h1, h2} {
+				sets[i].xorMask ^= h
+				sets[i].count--
+				if sets[i].count == 1 {
+					queue = append(queue, i)
+				}
+			}
+		}
+		if len(stack) != len(keys) {
+			continue
+		}
+
+		// assign fingerprints in reverse peeling order, so each key's slot
+		// is set after the other two slots of the key are final
+		for i := len(stack) - 1; i >= 0; i-- {
+			h0, h1, h2 := f.slots(stack[i].hash)
+			fp := f.fingerprint(stack[i].hash) ^ f.get(h0) ^ f.get(h1) ^ f.get(h2)
+			// the slot of the key is still 0, so contributes nothing above
+			f.set(stack[i].index, fp)
+		}
+		return f, nil
+	}
+	return nil, errBuild
+}
+
+// slots returns the slot of the hash in each of the three blocks.
+func (f *XorFilter) slots(h uint64) (uint32, uint32, uint32) {
+	return reduce(uint32(h), f.blockLength),
+		reduce(uint32(rotl(h, 21)), f.blockLength) + f.blockLength,
+		reduce(uint32(rotl(h, 42)), f.blockLength) + 2*f.blockLength
+}
+
+// fingerprint returns the fingerprint of the hash.
+func (f *XorFilter) fingerprint(h uint64) uint16 {
+	fp := uint16(h ^ h>>32)
+	if f.width == 8 {
+		return fp & 0xff
+	}
+	return fp
+}
+
+// get returns the fingerprint in slot i.
+func (f *XorFilter) get(i uint32) uint16 {
+	if f.width == 8 {
+		return uint16(f.fingerprints[i])
+	}
+	return binary.BigEndian.Uint16(f.fingerprints[2*i:])
+}
+
+// set stores the fingerprint in slot i.
+func (f *XorFilter) set(i uint32, fp uint16) {
+	if f.width == 8 {
+		f.fingerprints[i] = uint8(fp)
+		return
+	}
+	binary.BigEndian.PutUint16(f.fingerprints[2*i:], fp)
+}
+
+// unique returns the distinct keys, sorted. It does not modify keys.
+func unique(keys []uint64) []uint64 {
+	sorted := append([]uint64{}, keys...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	n := 0
+	for i, key := range sorted {
+		if i == 0 || key != sorted[n-1] {
+			sorted[n] = key
+			n++
+		}
+	}
+	return sorted[:n]
+}
+
+// reduce maps x to [0, n) without division.
+func reduce(x, n uint32) uint32 {
+	return uint32(uint64(x) * uint64(n) >> 32)
+}
+
+// rotl rotates x left by r bits.
+func rotl(x uint64, r uint) uint64 {
+	return x<<r | x>>(64-r)
+}
+
+// mix is the 64-bit MurmurHash3 finalizer, spreading the bits of the key.
+func mix(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// splitmix returns the next seed after x.
+func splitmix(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
@@ -0,0 +1,81 @@
+package xorfilter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// keys returns n well distributed keys starting from the nth.
+func keys(start, n int) []uint64 {
+	out := make([]uint64, n)
+	for i := range out {
+		out[i] = splitmix(uint64(start + i))
+	}
+	return out
+}
+
+// TestBuildXorFilter ensures every key is found and the false positive rate
+// matches the fingerprint width.
+func TestBuildXorFilter(t *testing.T) {
+	for _, c := range []struct {
+		build func([]uint64) (*XorFilter, error)
+		rate  float64
+		bits  float64
+	}{
+		{BuildXorFilter, 1.0 / 256, 8},
+		{BuildXorFilter16, 1.0 / 65536, 16},
+	} {
+		in := keys(0, 100000)
+		f, err := c.build(in)
+		require.NoError(t, err)
+		for _, key := range in {
+			require.True(t, f.Test(key))
+		}
+		positives := 0
+		for _, key := range keys(100000, 1000000) {
+			if f.Test(key) {
+				positives++
+			}
+		}
+		require.InDelta(t, c.rate, float64(positives)/1000000, c.rate/2)
+		require.InDelta(t, 1.23*c.bits,
+			float64(8*f.SizeInBytes())/float64(len(in)), 0.1)
+	}
+}
+
+// TestBuildXorFilter_Duplicates ensures duplicate and absent keys do not stop
+// a build.
+func TestBuildXorFilter_Duplicates(t *testing.T) {
+	in := append(keys(0, 1000), keys(0, 1000)...)
+	f, err := BuildXorFilter(in)
+	require.NoError(t, err)
+	for _, key := range in {
+		require.True(t, f.Test(key))
+	}
+
+	f, err = BuildXorFilter(nil)
+	require.NoError(t, err)
+	require.False(t, f.Test(1))
+}
+
+// TestXorFilter_Marshal ensures the filter round trips and bad data is
+// rejected.
+func TestXorFilter_Marshal(t *testing.T) {
+	in := keys(0, 1000)
+	f, _ := BuildXorFilter16(in)
+	out, err := f.MarshalBinary()
+	require.NoError(t, err)
+
+	u := new(XorFilter)
+	require.NoError(t, u.UnmarshalBinary(out))
+	require.Equal(t, f, u)
+
+	require.Error(t, u.UnmarshalBinary(out[:len(out)-1]))
+	require.Error(t, u.UnmarshalBinary(out[:headerSize-1]))
+	bad := append([]byte{}, out...)
+	bad[1] = 8
+	require.Error(t, u.UnmarshalBinary(bad))
+	bad[1], bad[0] = 16, 2
+	require.Error(t, u.UnmarshalBinary(bad))
+}